@@ -11,6 +11,7 @@ require (
 )
 
 require (
-	github.com/richardlehane/msoleps v1.0.3 // indirect
+	github.com/richardlehane/msoleps v1.0.3
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/text v0.21.0
 )
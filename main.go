@@ -62,6 +62,19 @@ func run(args []string, recursive bool) error {
 	return nil // Indicate success
 }
 
+// supportedExtensions lists the extensions of the files read from directories
+var supportedExtensions = map[string]bool{
+	".doc":  true,
+	".dot":  true,
+	".docx": true,
+	".docm": true,
+	".dotx": true,
+	".dotm": true,
+	".rtf":  true,
+	".odt":  true,
+	".ott":  true,
+}
+
 // processDirectory now accepts a WaitGroup pointer
 func processDirectory(extractor *word_extractor.WordExtractor, dirPath string, recursive bool, wg *sync.WaitGroup) {
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
@@ -74,7 +87,7 @@ func processDirectory(extractor *word_extractor.WordExtractor, dirPath string, r
 			return filepath.SkipDir
 		}
 
-		if !info.IsDir() && (strings.HasSuffix(strings.ToLower(info.Name()), ".docx") || strings.HasSuffix(strings.ToLower(info.Name()), ".doc")) {
+		if !info.IsDir() && supportedExtensions[strings.ToLower(filepath.Ext(info.Name()))] {
 			// Increment counter and launch goroutine for files found during walk
 			wg.Add(1)
 			go processFile(extractor, path, wg) // Pass the absolute path found by Walk
@@ -0,0 +1,148 @@
+package word_extractor
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// codePageSymbol is the code page of symbol fonts, whose characters Word
+// keeps in the private use area from U+F000
+const codePageSymbol = 42
+
+// codePages gives the encoding of each Windows code page that can be decoded
+var codePages = map[int]encoding.Encoding{
+	437:   charmap.CodePage437,
+	850:   charmap.CodePage850,
+	852:   charmap.CodePage852,
+	855:   charmap.CodePage855,
+	858:   charmap.CodePage858,
+	860:   charmap.CodePage860,
+	862:   charmap.CodePage862,
+	863:   charmap.CodePage863,
+	865:   charmap.CodePage865,
+	866:   charmap.CodePage866,
+	874:   charmap.Windows874,
+	932:   japanese.ShiftJIS,
+	936:   simplifiedchinese.GBK,
+	949:   korean.EUCKR,
+	950:   traditionalchinese.Big5,
+	1250:  charmap.Windows1250,
+	1251:  charmap.Windows1251,
+	1252:  charmap.Windows1252,
+	1253:  charmap.Windows1253,
+	1254:  charmap.Windows1254,
+	1255:  charmap.Windows1255,
+	1256:  charmap.Windows1256,
+	1257:  charmap.Windows1257,
+	1258:  charmap.Windows1258,
+	10000: charmap.Macintosh,
+	10007: charmap.MacintoshCyrillic,
+	20866: charmap.KOI8R,
+	21866: charmap.KOI8U,
+	28591: charmap.ISO8859_1,
+	28592: charmap.ISO8859_2,
+	28595: charmap.ISO8859_5,
+	28597: charmap.ISO8859_7,
+	28599: charmap.ISO8859_9,
+	28605: charmap.ISO8859_15,
+	54936: simplifiedchinese.GB18030,
+	65001: unicode.UTF8,
+}
+
+// charsetCodePages gives the code page of each font character set. The ANSI
+// and default character sets are left out, as they use the code page of the
+// document.
+var charsetCodePages = map[int]int{
+	2:   codePageSymbol,
+	77:  10000,
+	128: 932,
+	129: 949,
+	134: 936,
+	136: 950,
+	161: 1253,
+	162: 1254,
+	163: 1258,
+	177: 1255,
+	178: 1256,
+	186: 1257,
+	204: 1251,
+	222: 874,
+	238: 1250,
+	255: 437,
+}
+
+// decodeCodePage decodes text written in a Windows code page. Text in an
+// unknown code page is read as Windows-1252.
+func decodeCodePage(data []byte, codePage int) string {
+	if codePage == codePageSymbol {
+		var text strings.Builder
+		for _, b := range data {
+			text.WriteRune(0xF000 + rune(b))
+		}
+		return text.String()
+	}
+	enc, ok := codePages[codePage]
+	if !ok {
+		enc = charmap.Windows1252
+	}
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(text)
+}
+
+// isDoubleByteCodePage tells whether a code page writes some characters with
+// a lead byte and a trail byte
+func isDoubleByteCodePage(codePage int) bool {
+	switch codePage {
+	case 932, 936, 949, 950:
+		return true
+	}
+	return false
+}
+
+// isLeadByte tells whether a byte of text in a double byte code page starts a
+// character of two bytes
+func isLeadByte(codePage int, b byte) bool {
+	if codePage == 932 {
+		return (b >= 0x81 && b <= 0x9F) || (b >= 0xE0 && b <= 0xFC)
+	}
+	return b >= 0x81 && b <= 0xFE
+}
+
+// decodeDoubleByte decodes text written in a double byte code page one
+// character at a time. It also returns the offset in the data of the
+// character that each UTF-16 unit of the text is from, as the characters
+// may be one or two bytes.
+func decodeDoubleByte(data []byte, codePage int) (string, []int) {
+	decoder := codePages[codePage].NewDecoder()
+	var text strings.Builder
+	offsets := make([]int, 0, len(data))
+	for i := 0; i < len(data); {
+		size := 1
+		if isLeadByte(codePage, data[i]) && i+1 < len(data) {
+			size = 2
+		}
+		decoded, err := decoder.Bytes(data[i : i+size])
+		s := string(decoded)
+		if err != nil || s == "" {
+			s = string(utf8.RuneError)
+		}
+		text.WriteString(s)
+		for range utf16.Encode([]rune(s)) {
+			offsets = append(offsets, i)
+		}
+		i += size
+	}
+	return text.String(), offsets
+}
@@ -0,0 +1,62 @@
+package word_extractor
+
+import (
+	"time"
+)
+
+// Comment is a comment (annotation) made on a range of the document body
+type Comment struct {
+	ID       string
+	Author   string
+	Initials string
+	Date     time.Time
+	// Text is the content of the comment, paragraphs separated by newlines
+	Text string
+	// Replies holds the comments made in reply to this one, in document order
+	Replies []*Comment
+
+	// Offset is the character (rune) offset within Body of the text the
+	// comment is anchored to, and Length its length in characters. Anchor
+	// is that text, which is empty when the comment marks a single point.
+	Offset int
+	Length int
+	Anchor string
+}
+
+// anchor attaches a comment to the body text between two character offsets
+func (c *Comment) anchor(body []rune, start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if start > len(body) {
+		start = len(body)
+	}
+	if end < start {
+		end = start
+	}
+	if end > len(body) {
+		end = len(body)
+	}
+	c.Offset = start
+	c.Length = end - start
+	c.Anchor = string(body[start:end])
+}
+
+// threadComments files each comment under the comment it replies to, given
+// the index of each comment's parent or -1 when it has none. It returns the
+// comments that are not replies.
+func threadComments(comments []*Comment, parents []int) []*Comment {
+	var result []*Comment
+	for i, c := range comments {
+		parent := -1
+		if i < len(parents) {
+			parent = parents[i]
+		}
+		if parent >= 0 && parent < len(comments) && parent != i {
+			comments[parent].Replies = append(comments[parent].Replies, c)
+		} else {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,349 @@
+package word_extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// compoundEntry is a storage or stream of a compound file, with the names of
+// the storages it is in. Names of special streams start with a control
+// character, such as "\x01CompObj", which is kept apart from the name.
+type compoundEntry struct {
+	path    []string
+	name    string
+	control rune
+	storage bool
+	data    []byte
+}
+
+// fullName returns the name of the entry as it is stored
+func (e compoundEntry) fullName() string {
+	if e.control != 0 {
+		return string(e.control) + e.name
+	}
+	return e.name
+}
+
+// openCompoundFile opens the compound file read by a reader. Readers that
+// cannot read at an offset are read forwards only.
+func openCompoundFile(reader io.ReadSeeker) (*mscfb.Reader, error) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := checkCompoundHeader(reader); err != nil {
+		return nil, err
+	}
+	readerAt, ok := reader.(io.ReaderAt)
+	if !ok {
+		readerAt = NewUnbufferedReaderAt(reader)
+	}
+	return mscfb.New(readerAt)
+}
+
+// checkCompoundHeader checks that the counts of sectors in the header of a
+// compound file are no more than the file can hold. They are used to size
+// tables before the sectors are read, so a corrupt count could use up all
+// memory.
+func checkCompoundHeader(reader io.ReadSeeker) error {
+	header := make([]byte, 76)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return corruptError("", 0, "truncated compound file header")
+	}
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	shift := binary.LittleEndian.Uint16(header[30:])
+	if shift != 9 && shift != 12 {
+		return corruptError("", 30, "invalid compound file sector size")
+	}
+	sectors := uint32(size>>shift) + 1
+	for _, offset := range []int{40, 44, 64, 72} {
+		if binary.LittleEndian.Uint32(header[offset:]) > sectors {
+			return corruptError("", offset, "invalid compound file sector count")
+		}
+	}
+	return nil
+}
+
+// readCompoundFile reads the storages and streams of a compound file that are
+// within a storage, or all of them when the storage is empty. Streams are
+// read within the limits.
+func readCompoundFile(limits *limiter, reader io.ReadSeeker, storage ...string) ([]compoundEntry, error) {
+	cfb, err := openCompoundFile(reader)
+	if err != nil {
+		return nil, err
+	}
+	var entries []compoundEntry
+	for entry, err := cfb.Next(); err == nil; entry, err = cfb.Next() {
+		if len(entry.Path) < len(storage) || strings.Join(entry.Path[:len(storage)], "/") != strings.Join(storage, "/") {
+			continue
+		}
+		e := compoundEntry{
+			path:    append([]string(nil), entry.Path...),
+			name:    entry.Name,
+			storage: entry.FileInfo().IsDir(),
+		}
+		if entry.Initial != 0 && !unicode.IsPrint(rune(entry.Initial)) {
+			e.control = rune(entry.Initial)
+		}
+		if !e.storage {
+			if err := limits.checkStream(entry.Name, entry.Size); err != nil {
+				return nil, err
+			}
+			buf := new(bytes.Buffer)
+			if _, err := buf.ReadFrom(cfb); err != nil {
+				return nil, err
+			}
+			e.data = buf.Bytes()
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// hasCompoundStorage tells whether a compound file has a storage of the given
+// name at its root
+func hasCompoundStorage(reader io.ReadSeeker, name string) bool {
+	cfb, err := openCompoundFile(reader)
+	if err != nil {
+		return false
+	}
+	for entry, err := cfb.Next(); err == nil; entry, err = cfb.Next() {
+		if entry.Name == name && len(entry.Path) == 0 && entry.FileInfo().IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// compoundStorage returns the entries within a storage, with their paths made
+// relative to it
+func compoundStorage(entries []compoundEntry, storage []string) []compoundEntry {
+	var within []compoundEntry
+	for _, e := range entries {
+		if len(e.path) < len(storage) || strings.Join(e.path[:len(storage)], "/") != strings.Join(storage, "/") {
+			continue
+		}
+		e.path = e.path[len(storage):]
+		within = append(within, e)
+	}
+	return within
+}
+
+// compoundStream returns the data of a stream at the root of the entries
+func compoundStream(entries []compoundEntry, name string) ([]byte, bool) {
+	for _, e := range entries {
+		if len(e.path) == 0 && !e.storage && e.name == name {
+			return e.data, true
+		}
+	}
+	return nil, false
+}
+
+// compoundSignature starts every compound file
+var compoundSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Compound file constants, for files of version 4 with 4096 byte sectors
+const (
+	compoundSectorSize     = 4096
+	compoundMiniSectorSize = 64
+	compoundMiniCutoff     = 4096
+	compoundFatSector      = 0xFFFFFFFD
+	compoundEndOfChain     = 0xFFFFFFFE
+	compoundFree           = 0xFFFFFFFF
+	compoundNoStream       = 0xFFFFFFFF
+)
+
+// writeCompoundFile writes storages and streams as a compound file. Small
+// streams are kept in the mini stream, as readers expect them there.
+func writeCompoundFile(entries []compoundEntry) ([]byte, error) {
+	// Directory entries are the root, followed by the given entries. Each
+	// storage holds its children as a binary tree sorted by name.
+	children := map[string][]int{}
+	for i, e := range entries {
+		parent := strings.Join(e.path, "/")
+		children[parent] = append(children[parent], i+1)
+	}
+
+	var fat []uint32
+	var sectors [][]byte
+	allocate := func(data []byte, size int) uint32 {
+		if len(data) == 0 {
+			return compoundEndOfChain
+		}
+		start := uint32(len(fat))
+		for offset := 0; offset < len(data); offset += size {
+			sector := make([]byte, size)
+			copy(sector, data[offset:])
+			sectors = append(sectors, sector)
+			fat = append(fat, uint32(len(fat)+1))
+		}
+		fat[len(fat)-1] = compoundEndOfChain
+		return start
+	}
+
+	// Small streams go into the mini stream, chained by the mini FAT
+	starts := make([]uint32, len(entries))
+	var mini []byte
+	var miniFat []uint32
+	for i, e := range entries {
+		if e.storage || len(e.data) == 0 || len(e.data) >= compoundMiniCutoff {
+			continue
+		}
+		starts[i] = uint32(len(miniFat))
+		for offset := 0; offset < len(e.data); offset += compoundMiniSectorSize {
+			sector := make([]byte, compoundMiniSectorSize)
+			copy(sector, e.data[offset:])
+			mini = append(mini, sector...)
+			miniFat = append(miniFat, uint32(len(miniFat)+1))
+		}
+		miniFat[len(miniFat)-1] = compoundEndOfChain
+	}
+	for i, e := range entries {
+		if !e.storage && len(e.data) >= compoundMiniCutoff {
+			starts[i] = allocate(e.data, compoundSectorSize)
+		}
+	}
+	miniStart := allocate(mini, compoundSectorSize)
+	miniFatData := make([]byte, len(miniFat)*4)
+	for i, next := range miniFat {
+		binary.LittleEndian.PutUint32(miniFatData[i*4:], next)
+	}
+	miniFatStart := allocate(miniFatData, compoundSectorSize)
+	miniFatSectors := (len(miniFatData) + compoundSectorSize - 1) / compoundSectorSize
+
+	directory := make([]byte, (len(entries)+1)*128)
+	writeEntry := func(id int, name string, typ byte, start uint32, size int) {
+		entry := directory[id*128 : (id+1)*128]
+		chars := utf16.Encode([]rune(name))
+		if len(chars) > 31 {
+			chars = chars[:31]
+		}
+		for i, c := range chars {
+			binary.LittleEndian.PutUint16(entry[i*2:], c)
+		}
+		binary.LittleEndian.PutUint16(entry[64:], uint16((len(chars)+1)*2))
+		entry[66], entry[67] = typ, 1
+		binary.LittleEndian.PutUint32(entry[68:], compoundNoStream)
+		binary.LittleEndian.PutUint32(entry[72:], compoundNoStream)
+		binary.LittleEndian.PutUint32(entry[76:], compoundNoStream)
+		binary.LittleEndian.PutUint32(entry[116:], start)
+		binary.LittleEndian.PutUint64(entry[120:], uint64(size))
+	}
+	writeEntry(0, "Root Entry", 5, miniStart, len(mini))
+	for i, e := range entries {
+		if e.storage {
+			writeEntry(i+1, e.fullName(), 1, 0, 0)
+		} else {
+			start := starts[i]
+			if len(e.data) == 0 {
+				start = compoundEndOfChain
+			}
+			writeEntry(i+1, e.fullName(), 2, start, len(e.data))
+		}
+	}
+	var tree func(ids []int) uint32
+	tree = func(ids []int) uint32 {
+		if len(ids) == 0 {
+			return compoundNoStream
+		}
+		mid := len(ids) / 2
+		entry := directory[ids[mid]*128:]
+		binary.LittleEndian.PutUint32(entry[68:], tree(ids[:mid]))
+		binary.LittleEndian.PutUint32(entry[72:], tree(ids[mid+1:]))
+		return uint32(ids[mid])
+	}
+	for parent, ids := range children {
+		sort.Slice(ids, func(i, j int) bool {
+			return compoundNameLess(entries[ids[i]-1].fullName(), entries[ids[j]-1].fullName())
+		})
+		id := 0
+		if parent != "" {
+			id = -1
+			for i, e := range entries {
+				if e.storage && strings.Join(append(append([]string(nil), e.path...), e.name), "/") == parent {
+					id = i + 1
+				}
+			}
+			if id < 0 {
+				return nil, fmt.Errorf("%w: compound file entry outside of its storages", ErrCorrupt)
+			}
+		}
+		binary.LittleEndian.PutUint32(directory[id*128+76:], tree(ids))
+	}
+	directoryStart := allocate(directory, compoundSectorSize)
+	directorySectors := len(sectors) - int(directoryStart)
+
+	// The FAT covers every sector, including its own
+	fatSectors := 0
+	for fatSectors*compoundSectorSize/4 < len(sectors)+fatSectors {
+		fatSectors++
+	}
+	if fatSectors > 109 {
+		return nil, fmt.Errorf("%w: compound file too large", ErrTooLarge)
+	}
+	fatStart := len(sectors)
+	for i := 0; i < fatSectors; i++ {
+		fat = append(fat, compoundFatSector)
+	}
+	fatData := make([]byte, fatSectors*compoundSectorSize)
+	for i := range fatData {
+		fatData[i] = 0xFF
+	}
+	for i, next := range fat {
+		binary.LittleEndian.PutUint32(fatData[i*4:], next)
+	}
+
+	header := make([]byte, compoundSectorSize)
+	copy(header, compoundSignature)
+	binary.LittleEndian.PutUint16(header[24:], 0x003E)
+	binary.LittleEndian.PutUint16(header[26:], 4)
+	binary.LittleEndian.PutUint16(header[28:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[30:], 12)
+	binary.LittleEndian.PutUint16(header[32:], 6)
+	binary.LittleEndian.PutUint32(header[40:], uint32(directorySectors))
+	binary.LittleEndian.PutUint32(header[44:], uint32(fatSectors))
+	binary.LittleEndian.PutUint32(header[48:], directoryStart)
+	binary.LittleEndian.PutUint32(header[56:], compoundMiniCutoff)
+	binary.LittleEndian.PutUint32(header[60:], miniFatStart)
+	binary.LittleEndian.PutUint32(header[64:], uint32(miniFatSectors))
+	binary.LittleEndian.PutUint32(header[68:], compoundEndOfChain)
+	for i := 0; i < 109; i++ {
+		sector := uint32(compoundFree)
+		if i < fatSectors {
+			sector = uint32(fatStart + i)
+		}
+		binary.LittleEndian.PutUint32(header[76+i*4:], sector)
+	}
+
+	var file bytes.Buffer
+	file.Write(header)
+	for _, sector := range sectors {
+		file.Write(sector)
+	}
+	file.Write(fatData)
+	return file.Bytes(), nil
+}
+
+// compoundNameLess orders directory entry names the way compound files sort
+// them: shorter names first, then by their upper case characters
+func compoundNameLess(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	if len(ua) != len(ub) {
+		return len(ua) < len(ub)
+	}
+	return strings.ToUpper(a) < strings.ToUpper(b)
+}
@@ -1,6 +1,7 @@
 package word_extractor
 
 import (
+	"sort"
 	"strings"
 )
 
@@ -16,8 +17,101 @@ type Document struct {
 	Annotations     string
 	Textboxes       string
 	HeaderTextboxes string
+
+	// Blocks is the structured form of Body: its paragraphs and tables in
+	// document order. Body is the plain-text rendering of these blocks.
+	Blocks []Block
+
+	// Metadata holds the document properties, such as its title and author
+	Metadata Metadata
+
+	// Format is the file format the document was read from, and HasMacros
+	// tells whether the file holds a macro project
+	Format    Format
+	HasMacros bool
+
+	revisions []Revision
+	comments  []*Comment
+	footnotes []*Note
+	endnotes  []*Note
+
+	fields     []*Field
+	hyperlinks []*Hyperlink
+	images     []*Image
+	objects    []*EmbeddedObject
+}
+
+// Format is a file format of the documents that are read, named by its file
+// extension
+type Format string
+
+const (
+	FormatDoc  Format = "doc"
+	FormatDot  Format = "dot"
+	FormatDocx Format = "docx"
+	FormatDocm Format = "docm"
+	FormatDotx Format = "dotx"
+	FormatDotm Format = "dotm"
+	FormatRtf  Format = "rtf"
+	FormatOdt  Format = "odt"
+	FormatOtt  Format = "ott"
+	// FormatXML is a document Word saved as a single XML file, either a Flat
+	// OPC package or a Word 2003 XML document
+	FormatXML Format = "xml"
+)
+
+// Block is one element of the structured document body. Exactly one of
+// Paragraph or Table is set.
+type Block struct {
+	Paragraph *Paragraph
+	Table     *Table
+}
+
+// Paragraph is a single paragraph of text
+type Paragraph struct {
+	// Text is the paragraph content, without its terminating newline
+	Text string
+	// Offset is the character (rune) offset of the paragraph within Body
+	Offset int
+	// Style is the name of the paragraph style, and Level the outline level
+	// of a heading, from 1 to 9, or zero for body text
+	Style string
+	Level int
+	// List is the list numbering of the paragraph, or nil when it is not a
+	// list item
+	List *ListItem
+}
+
+// Table is a table in the document body, held as rows of cells
+type Table struct {
+	Rows [][]Cell
 }
 
+// Cell is a single table cell. A cell holds its own paragraphs and may
+// contain nested tables.
+type Cell struct {
+	// Text is the rendered content of the cell, paragraphs separated by newlines
+	Text   string
+	Blocks []Block
+	// GridSpan is the number of grid columns the cell covers. Cells merged
+	// horizontally are folded into the first cell of the merge.
+	GridSpan int
+	// VMerge tells whether the cell is part of a vertically merged range
+	VMerge VerticalMerge
+}
+
+// VerticalMerge describes how a cell takes part in a vertical merge
+type VerticalMerge int
+
+const (
+	// NoMerge is a cell that is not vertically merged
+	NoMerge VerticalMerge = iota
+	// MergeRestart is the first cell of a vertically merged range, which holds its content
+	MergeRestart
+	// MergeContinue is a cell that continues the merged range of the cell above it
+	MergeContinue
+)
+
 // Options contains configuration for document content retrieval
 type Options struct {
 	// FilterUnicode if true (the default), converts common Unicode quotes to ASCII
@@ -28,6 +122,12 @@ type Options struct {
 	IncludeHeadersAndFooters bool
 	// IncludeBody if true (the default), includes text box content in document body
 	IncludeBody bool
+	// InlineHyperlinks if true, writes the target of each hyperlink in the body
+	// after its text, as in "text <http://example.com>"
+	InlineHyperlinks bool
+	// ListLabels if true, writes the number or bullet of each list item in
+	// the body before its text, as in "4.2(b) text"
+	ListLabels bool
 }
 
 func NewDocument() *Document {
@@ -48,10 +148,54 @@ func (d *Document) GetBody(opts *Options) string {
 	if opts == nil {
 		opts = defaultOptions()
 	}
+	body := d.Body
+	var inserts []insertion
+	if opts.ListLabels {
+		for _, p := range d.Paragraphs() {
+			if p.List != nil && p.List.Label != "" {
+				inserts = append(inserts, insertion{offset: p.Offset, text: p.List.Label + " "})
+			}
+		}
+	}
+	if opts.InlineHyperlinks {
+		inserts = append(inserts, hyperlinkTargets(d.hyperlinks, len([]rune(body)))...)
+	}
+	body = renderInsertions(body, inserts)
 	if opts.FilterUnicode {
-		return filterText(d.Body)
+		return filterText(body)
+	}
+	return body
+}
+
+// insertion is text written into the body at a character offset when it is
+// rendered
+type insertion struct {
+	offset int
+	text   string
+}
+
+// renderInsertions writes each insertion into the body at its offset.
+// Insertions at the same offset keep their order.
+func renderInsertions(body string, inserts []insertion) string {
+	if len(inserts) == 0 {
+		return body
+	}
+	sort.SliceStable(inserts, func(i, j int) bool {
+		return inserts[i].offset < inserts[j].offset
+	})
+	text := []rune(body)
+	var result strings.Builder
+	last := 0
+	for _, in := range inserts {
+		if in.offset < last || in.offset > len(text) {
+			continue
+		}
+		result.WriteString(string(text[last:in.offset]))
+		result.WriteString(in.text)
+		last = in.offset
 	}
-	return d.Body
+	result.WriteString(string(text[last:]))
+	return result.String()
 }
 
 // GetHeaders returns the headers part of a Word file, optionally including footers
@@ -110,6 +254,90 @@ func (d *Document) GetAnnotations(opts *Options) string {
 	return d.Annotations
 }
 
+// Paragraphs returns every paragraph of the body in document order, including
+// the paragraphs held in table cells
+func (d *Document) Paragraphs() []*Paragraph {
+	var result []*Paragraph
+	walkBlocks(d.Blocks, func(b Block) {
+		if b.Paragraph != nil {
+			result = append(result, b.Paragraph)
+		}
+	})
+	return result
+}
+
+// Tables returns the top-level tables of the body in document order. Nested
+// tables are found in the Blocks of the cells that hold them.
+func (d *Document) Tables() []*Table {
+	var result []*Table
+	for _, b := range d.Blocks {
+		if b.Table != nil {
+			result = append(result, b.Table)
+		}
+	}
+	return result
+}
+
+// Revisions returns the tracked changes found in the body, in document order
+func (d *Document) Revisions() []Revision {
+	return d.revisions
+}
+
+// Comments returns the comments made on the body in document order. Replies
+// are found in the Replies of the comment they answer.
+func (d *Document) Comments() []*Comment {
+	return d.comments
+}
+
+// FootnoteList returns the footnotes in the order they are referenced from
+// the body. GetFootnotes returns their text as a single string.
+func (d *Document) FootnoteList() []*Note {
+	return d.footnotes
+}
+
+// EndnoteList returns the endnotes in the order they are referenced from the
+// body. GetEndnotes returns their text as a single string.
+func (d *Document) EndnoteList() []*Note {
+	return d.endnotes
+}
+
+// Hyperlinks returns the links in the body in document order
+func (d *Document) Hyperlinks() []*Hyperlink {
+	return d.hyperlinks
+}
+
+// Fields returns the fields in the body in the order they begin, a field
+// coming before the fields nested in it
+func (d *Document) Fields() []*Field {
+	return d.fields
+}
+
+// Images returns the pictures in the body in document order
+func (d *Document) Images() []*Image {
+	return d.images
+}
+
+// EmbeddedObjects returns the objects embedded in the document, such as
+// spreadsheets, packaged files and other Word documents
+func (d *Document) EmbeddedObjects() []*EmbeddedObject {
+	return d.objects
+}
+
+// walkBlocks visits blocks depth first, descending into table cells
+func walkBlocks(blocks []Block, visit func(Block)) {
+	for _, b := range blocks {
+		visit(b)
+		if b.Table == nil {
+			continue
+		}
+		for _, row := range b.Table.Rows {
+			for _, cell := range row {
+				walkBlocks(cell.Blocks, visit)
+			}
+		}
+	}
+}
+
 // GetTextboxes returns the textbox content from a Word file
 func (d *Document) GetTextboxes(opts *Options) string {
 	if opts == nil {
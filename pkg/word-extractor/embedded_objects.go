@@ -0,0 +1,174 @@
+package word_extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path"
+	"strings"
+)
+
+// EmbeddedObject is an object embedded in the document, such as a
+// spreadsheet, a PDF file or another Word document
+type EmbeddedObject struct {
+	// ProgID names the application of the object, such as "Excel.Sheet.12"
+	// or "Word.Document.8", or "Package" for a file packaged as an object
+	ProgID string
+	// Name is the file name of the object: the name of its part in .docx
+	// files, the name of its storage in .doc files, or the name of the file
+	// packaged as an object
+	Name string
+	// Data holds the bytes of the object. Packaged files are unwrapped, and
+	// the other objects of .doc files are written as compound files, the
+	// same as they are stored in .docx files.
+	Data []byte
+	// Document is the extracted embedded Word document, when the document
+	// is extracted with the EmbeddedDocuments option
+	Document *Document
+}
+
+// isWordDocument tells whether the object is a Word document or template
+func (o *EmbeddedObject) isWordDocument() bool {
+	if strings.HasPrefix(o.ProgID, "Word.Document") || strings.HasPrefix(o.ProgID, "Word.Template") {
+		return true
+	}
+	switch strings.ToLower(path.Ext(o.Name)) {
+	case ".doc", ".docx", ".docm", ".dot", ".dotx", ".dotm":
+		return true
+	}
+	return false
+}
+
+// compoundObject makes an object of the storages and streams of an OLE
+// object, given with their paths relative to the object storage. file holds
+// the object as a compound file, or is nil when it must be written.
+func compoundObject(name, progID string, entries []compoundEntry, file []byte) (*EmbeddedObject, error) {
+	object := &EmbeddedObject{ProgID: progID, Name: name, Data: file}
+	if compObj, ok := compoundStream(entries, "CompObj"); ok && object.ProgID == "" {
+		object.ProgID = compObjProgID(compObj)
+	}
+
+	// Office documents embed their file in a Package stream, and files
+	// packaged as objects are held in the native data stream
+	if data, ok := compoundStream(entries, "Package"); ok {
+		object.Data = data
+		return object, nil
+	}
+	if native, ok := compoundStream(entries, "Ole10Native"); ok && object.ProgID == "Package" {
+		if fileName, data, ok := packagedFile(native); ok {
+			object.Name, object.Data = fileName, data
+			return object, nil
+		}
+	}
+	if object.Data == nil {
+		data, err := writeCompoundFile(entries)
+		if err != nil {
+			return nil, err
+		}
+		object.Data = data
+	}
+	return object, nil
+}
+
+// readLengthPrefixedString reads a string written after its length, which
+// counts its terminating null
+func readLengthPrefixedString(data []byte, offset int) (string, int, bool) {
+	if offset+4 > len(data) {
+		return "", offset, false
+	}
+	length := int(binary.LittleEndian.Uint32(data[offset:]))
+	offset += 4
+	if length < 0 || length > len(data)-offset {
+		return "", offset, false
+	}
+	text := data[offset : offset+length]
+	return string(bytes.TrimRight(text, "\x00")), offset + length, true
+}
+
+// compObjProgID reads the ProgID of an object from its CompObj stream, which
+// holds the user type and the clipboard format of the object before it
+func compObjProgID(data []byte) string {
+	_, offset, ok := readLengthPrefixedString(data, 28)
+	if !ok || offset+4 > len(data) {
+		return ""
+	}
+
+	// The clipboard format is either a standard format or a name
+	switch marker := binary.LittleEndian.Uint32(data[offset:]); marker {
+	case 0:
+		offset += 4
+	case 0xFFFFFFFF, 0xFFFFFFFE:
+		offset += 8
+	default:
+		if _, offset, ok = readLengthPrefixedString(data, offset); !ok {
+			return ""
+		}
+	}
+	progID, _, _ := readLengthPrefixedString(data, offset)
+	return progID
+}
+
+// packagedFile reads the file packaged as an object from its native data:
+// its label, original path and temporary path, followed by its contents
+func packagedFile(native []byte) (string, []byte, bool) {
+	// The native data is prefixed with its size, then a type
+	offset := 6
+	var strs []string
+	readString := func() bool {
+		end := bytes.IndexByte(native[min(offset, len(native)):], 0)
+		if end < 0 {
+			return false
+		}
+		strs = append(strs, string(native[offset:offset+end]))
+		offset += end + 1
+		return true
+	}
+	if len(native) < offset || !readString() || !readString() {
+		return "", nil, false
+	}
+	offset += 8
+	if offset > len(native) || !readString() || offset+4 > len(native) {
+		return "", nil, false
+	}
+	size := int(binary.LittleEndian.Uint32(native[offset:]))
+	offset += 4
+	if size < 0 || size > len(native)-offset {
+		return "", nil, false
+	}
+	name := strs[0]
+	if name == "" {
+		name = path.Base(strings.ReplaceAll(strs[1], `\`, "/"))
+	}
+	return name, native[offset : offset+size], true
+}
+
+// appendDocument appends the body of an embedded document to the body,
+// after its own text
+func (d *Document) appendDocument(embedded *Document) {
+	offset := len([]rune(d.Body))
+	d.Body += embedded.Body
+	d.Blocks = append(d.Blocks, shiftBlocks(embedded.Blocks, offset)...)
+}
+
+// shiftBlocks copies blocks, moving their paragraphs by an offset within the
+// body
+func shiftBlocks(blocks []Block, offset int) []Block {
+	shifted := make([]Block, len(blocks))
+	for i, block := range blocks {
+		if block.Paragraph != nil {
+			p := *block.Paragraph
+			p.Offset += offset
+			shifted[i].Paragraph = &p
+			continue
+		}
+		table := &Table{Rows: make([][]Cell, len(block.Table.Rows))}
+		for r, row := range block.Table.Rows {
+			table.Rows[r] = make([]Cell, len(row))
+			for c, cell := range row {
+				cell.Blocks = shiftBlocks(cell.Blocks, offset)
+				table.Rows[r][c] = cell
+			}
+		}
+		shifted[i].Table = table
+	}
+	return shifted
+}
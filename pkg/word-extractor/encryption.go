@@ -0,0 +1,496 @@
+package word_extractor
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// Flags of the FIB of encrypted and obfuscated .doc files
+const (
+	fibEncrypted  = 0x0100
+	fibObfuscated = 0x8000
+)
+
+// fibBaseSize is the size of the start of the FIB, which is not encrypted
+const fibBaseSize = 0x44
+
+// rc4BlockSize is the size of the blocks of a .doc file encrypted with RC4,
+// each of which has its own key
+const rc4BlockSize = 512
+
+// Block keys of the hashes of agile encryption
+var (
+	agileVerifierInputBlock = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	agileVerifierValueBlock = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	agileKeyValueBlock      = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+)
+
+// agileMaxSpinCount is the most times the hash of a password is hashed again
+// to derive a key. Word uses 100,000, and a larger count in a document that
+// cannot be trusted would keep the extraction busy.
+const agileMaxSpinCount = 10_000_000
+
+// agilePasswordEncryptor is the URI of the key encryptor of a password
+const agilePasswordEncryptor = "http://schemas.microsoft.com/office/2006/keyEncryptor/password"
+
+// agileHashes gives the hash function of each agile hash algorithm
+var agileHashes = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA384": sha512.New384,
+	"SHA512": sha512.New,
+	"MD5":    md5.New,
+}
+
+// encryptedPackageSegment is the size of the segments of an agile encrypted
+// package, each with its own initialization vector
+const encryptedPackageSegment = 4096
+
+// agileEncryption is the XML descriptor of agile encryption, with the key
+// encryptors of the package key
+type agileEncryption struct {
+	KeyData       agileKey `xml:"keyData"`
+	KeyEncryptors []struct {
+		URI          string   `xml:"uri,attr"`
+		EncryptedKey agileKey `xml:"encryptedKey"`
+	} `xml:"keyEncryptors>keyEncryptor"`
+}
+
+// agileKey describes how a key is derived and what it encrypts
+type agileKey struct {
+	SaltValue                  string `xml:"saltValue,attr"`
+	HashAlgorithm              string `xml:"hashAlgorithm,attr"`
+	BlockSize                  int    `xml:"blockSize,attr"`
+	KeyBits                    int    `xml:"keyBits,attr"`
+	CipherAlgorithm            string `xml:"cipherAlgorithm,attr"`
+	CipherChaining             string `xml:"cipherChaining,attr"`
+	SpinCount                  int    `xml:"spinCount,attr"`
+	EncryptedVerifierHashInput string `xml:"encryptedVerifierHashInput,attr"`
+	EncryptedVerifierHashValue string `xml:"encryptedVerifierHashValue,attr"`
+	EncryptedKeyValue          string `xml:"encryptedKeyValue,attr"`
+}
+
+// encryptedPackage returns the encryption info and the encrypted package of
+// a password protected Word package, which is saved as a compound file. Only
+// those two streams are read, and only when both are at the root. Files that
+// are not compound files are not encrypted packages.
+func encryptedPackage(limits *limiter, reader io.ReadSeeker) (info, pkg []byte, ok bool, err error) {
+	cfb, err := openCompoundFile(reader)
+	if err != nil {
+		return nil, nil, false, nil
+	}
+	var infoEntry, pkgEntry *mscfb.File
+	for entry, err := cfb.Next(); err == nil; entry, err = cfb.Next() {
+		if len(entry.Path) != 0 || entry.FileInfo().IsDir() {
+			continue
+		}
+		switch entry.Name {
+		case "EncryptionInfo":
+			infoEntry = entry
+		case "EncryptedPackage":
+			pkgEntry = entry
+		}
+	}
+	if infoEntry == nil || pkgEntry == nil {
+		return nil, nil, false, nil
+	}
+	if info, err = readCompoundStream(limits, infoEntry); err != nil {
+		return nil, nil, false, err
+	}
+	if pkg, err = readCompoundStream(limits, pkgEntry); err != nil {
+		return nil, nil, false, err
+	}
+	return info, pkg, true, nil
+}
+
+// readCompoundStream reads a stream of a compound file within the limits
+func readCompoundStream(limits *limiter, entry *mscfb.File) ([]byte, error) {
+	if err := limits.checkStream(entry.Name, entry.Size); err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(entry); err != nil {
+		return nil, partError(entry.Name, -1, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decryptPackage decrypts an encrypted Word package with a password, using
+// agile or standard encryption as given by the version of its encryption
+// info. The package starts with its size.
+func decryptPackage(limits *limiter, info, pkg []byte, password string) ([]byte, error) {
+	if password == "" || len(info) < 8 || len(pkg) < 8 {
+		return nil, ErrEncrypted
+	}
+	major, minor := binary.LittleEndian.Uint16(info), binary.LittleEndian.Uint16(info[2:])
+	var data []byte
+	var err error
+	switch {
+	case major == 4 && minor == 4:
+		data, err = decryptAgilePackage(limits, info[8:], pkg[8:], password)
+	case major >= 2 && major <= 4 && minor == 2:
+		data, err = decryptStandardPackage(info, pkg[8:], password)
+	default:
+		return nil, ErrEncrypted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size := binary.LittleEndian.Uint64(pkg); size < uint64(len(data)) {
+		data = data[:size]
+	}
+	return data, nil
+}
+
+// decryptAgilePackage decrypts a package with agile encryption. The package
+// key is encrypted with a key derived from the password, and the package is
+// encrypted in segments.
+func decryptAgilePackage(limits *limiter, descriptor, pkg []byte, password string) ([]byte, error) {
+	var encryption agileEncryption
+	if err := xml.NewDecoder(bytes.NewReader(descriptor)).Decode(&encryption); err != nil {
+		return nil, err
+	}
+	var key *agileKey
+	for i := range encryption.KeyEncryptors {
+		if encryption.KeyEncryptors[i].URI == agilePasswordEncryptor {
+			key = &encryption.KeyEncryptors[i].EncryptedKey
+		}
+	}
+	if key == nil || !key.supported() || !encryption.KeyData.supported() || key.SpinCount > agileMaxSpinCount {
+		return nil, ErrEncrypted
+	}
+	for _, k := range []*agileKey{key, &encryption.KeyData} {
+		if err := k.checkSizes(); err != nil {
+			return nil, err
+		}
+	}
+
+	newHash := agileHashes[key.HashAlgorithm]
+	salt, _ := base64.StdEncoding.DecodeString(key.SaltValue)
+	h := newHash()
+	h.Write(salt)
+	h.Write(passwordBytes(password))
+	sum := h.Sum(nil)
+	for i := 0; i < key.SpinCount; i++ {
+		// The key of a large spin count takes a while to derive
+		if i%4096 == 0 {
+			if err := limits.check(); err != nil {
+				return nil, err
+			}
+		}
+		h.Reset()
+		h.Write(binary.LittleEndian.AppendUint32(nil, uint32(i)))
+		h.Write(sum)
+		sum = h.Sum(nil)
+	}
+
+	decrypt := func(block []byte, encoded string) ([]byte, error) {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		return decryptCBC(agileHash(newHash, key.KeyBits/8, sum, block), fixedSize(salt, key.BlockSize), data)
+	}
+	input, err := decrypt(agileVerifierInputBlock, key.EncryptedVerifierHashInput)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decrypt(agileVerifierValueBlock, key.EncryptedVerifierHashValue)
+	if err != nil {
+		return nil, err
+	}
+	verifier := agileHash(newHash, 0, input[:min(len(salt), len(input))])
+	if len(value) < len(verifier) || subtle.ConstantTimeCompare(verifier, value[:len(verifier)]) != 1 {
+		return nil, ErrWrongPassword
+	}
+	secret, err := decrypt(agileKeyValueBlock, key.EncryptedKeyValue)
+	if err != nil {
+		return nil, err
+	}
+
+	keyData := encryption.KeyData
+	secret = secret[:min(keyData.KeyBits/8, len(secret))]
+	keySalt, _ := base64.StdEncoding.DecodeString(keyData.SaltValue)
+	dataHash := agileHashes[keyData.HashAlgorithm]
+	var out []byte
+	for segment := 0; segment*encryptedPackageSegment < len(pkg); segment++ {
+		start := segment * encryptedPackageSegment
+		end := min(start+encryptedPackageSegment, len(pkg))
+		end -= (end - start) % aes.BlockSize
+		iv := agileHash(dataHash, keyData.BlockSize, keySalt, binary.LittleEndian.AppendUint32(nil, uint32(segment)))
+		data, err := decryptCBC(secret, iv, pkg[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// supported tells whether a key uses a cipher and hash that can be decrypted
+func (k *agileKey) supported() bool {
+	return k.CipherAlgorithm == "AES" && k.CipherChaining == "ChainingModeCBC" && agileHashes[k.HashAlgorithm] != nil
+}
+
+// checkSizes checks the key and block sizes of a key, which the keys and
+// initialization vectors are cut to
+func (k *agileKey) checkSizes() error {
+	if !validAESKeyBits(k.KeyBits) {
+		return corruptError("EncryptionInfo", -1, "invalid key size %d", k.KeyBits)
+	}
+	if k.BlockSize != aes.BlockSize {
+		return corruptError("EncryptionInfo", -1, "invalid block size %d", k.BlockSize)
+	}
+	return nil
+}
+
+// validAESKeyBits tells whether a key size in bits is one of AES
+func validAESKeyBits(keyBits int) bool {
+	return keyBits == 128 || keyBits == 192 || keyBits == 256
+}
+
+// agileHash hashes the concatenation of some data, and sizes it to the
+// given size, unless the size is zero
+func agileHash(newHash func() hash.Hash, size int, data ...[]byte) []byte {
+	h := newHash()
+	for _, d := range data {
+		h.Write(d)
+	}
+	if size == 0 {
+		return h.Sum(nil)
+	}
+	return fixedSize(h.Sum(nil), size)
+}
+
+// fixedSize truncates a key to a size, or pads it with 0x36 bytes
+func fixedSize(key []byte, size int) []byte {
+	if len(key) >= size {
+		return key[:size]
+	}
+	return append(append([]byte(nil), key...), bytes.Repeat([]byte{0x36}, size-len(key))...)
+}
+
+// decryptStandardPackage decrypts a package with standard encryption, which
+// uses AES in ECB mode with a key derived from the password
+func decryptStandardPackage(info, pkg []byte, password string) ([]byte, error) {
+	if len(info) < 12 || binary.LittleEndian.Uint32(info[4:])&0x20 == 0 {
+		return nil, ErrEncrypted
+	}
+	headerSize := int(binary.LittleEndian.Uint32(info[8:]))
+	verifier := 12 + headerSize
+	if headerSize < 20 || len(info) < verifier+72 {
+		return nil, ErrEncrypted
+	}
+	keyBits := int(binary.LittleEndian.Uint32(info[12+16:]))
+	if !validAESKeyBits(keyBits) {
+		return nil, corruptError("EncryptionInfo", 12+16, "invalid key size %d", keyBits)
+	}
+	salt := info[verifier+4 : verifier+20]
+
+	h := sha1.Sum(append(append([]byte(nil), salt...), passwordBytes(password)...))
+	for i := 0; i < 50000; i++ {
+		h = sha1.Sum(append(binary.LittleEndian.AppendUint32(nil, uint32(i)), h[:]...))
+	}
+	final := sha1.Sum(binary.LittleEndian.AppendUint32(h[:], 0))
+	derive := func(fill byte) [20]byte {
+		buf := bytes.Repeat([]byte{fill}, 64)
+		for i, b := range final {
+			buf[i] ^= b
+		}
+		return sha1.Sum(buf)
+	}
+	x1, x2 := derive(0x36), derive(0x5c)
+	key := append(x1[:], x2[:]...)[:keyBits/8]
+
+	// The encrypted verifier is followed by the size of its hash, and the
+	// encrypted hash
+	check, err := decryptECB(key, info[verifier+20:verifier+36])
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(check)
+	hashed, err := decryptECB(key, info[verifier+40:verifier+72])
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(sum[:], hashed[:20]) != 1 {
+		return nil, ErrWrongPassword
+	}
+	return decryptECB(key, pkg[:len(pkg)-len(pkg)%aes.BlockSize])
+}
+
+// decryptCBC decrypts data with AES in CBC mode
+func decryptCBC(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("%w: invalid encrypted data", ErrCorrupt)
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	return out, nil
+}
+
+// decryptECB decrypts data with AES in ECB mode
+func decryptECB(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: invalid encrypted data", ErrCorrupt)
+	}
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += aes.BlockSize {
+		block.Decrypt(out[i:], data[i:])
+	}
+	return out, nil
+}
+
+// decryptWordStreams decrypts the streams of a .doc file encrypted with RC4,
+// or RC4 with CryptoAPI. The encryption header is at the start of the table
+// stream, and it and the FibBase are not encrypted.
+func decryptWordStreams(buffer, tableBuffer, dataStream []byte, tableStream, password string) ([]byte, []byte, []byte, error) {
+	if len(buffer) < fibBaseSize {
+		return nil, nil, nil, corruptError("WordDocument", 0, "invalid FIB")
+	}
+	flags := binary.LittleEndian.Uint16(buffer[0x0A:])
+	headerSize := int(binary.LittleEndian.Uint32(buffer[0x0E:]))
+	if password == "" || flags&fibObfuscated != 0 || headerSize > len(tableBuffer) {
+		return nil, nil, nil, ErrEncrypted
+	}
+	blockKey, err := rc4BlockKey(tableBuffer[:headerSize], password)
+	switch {
+	case errors.Is(err, ErrEncrypted), errors.Is(err, ErrWrongPassword):
+		return nil, nil, nil, err
+	case err != nil:
+		return nil, nil, nil, partError(tableStream, -1, err)
+	}
+
+	decrypted := decryptRC4(buffer, blockKey)
+	copy(decrypted, buffer[:fibBaseSize])
+	table := decryptRC4(tableBuffer, blockKey)
+	copy(table, tableBuffer[:headerSize])
+	if dataStream != nil {
+		dataStream = decryptRC4(dataStream, blockKey)
+	}
+	return decrypted, table, dataStream, nil
+}
+
+// rc4BlockKey checks a password against the RC4 encryption header of a .doc
+// file, and returns the function giving the key of each block
+func rc4BlockKey(header []byte, password string) (func(block uint32) []byte, error) {
+	if len(header) < 4 {
+		return nil, ErrEncrypted
+	}
+	major, minor := binary.LittleEndian.Uint16(header), binary.LittleEndian.Uint16(header[2:])
+	var blockKey func(uint32) []byte
+	var verifier, verifierHash []byte
+	var newHash func() hash.Hash
+	switch {
+	case major == 1 && minor == 1:
+		if len(header) < 52 {
+			return nil, ErrEncrypted
+		}
+		salt := header[4:20]
+		verifier, verifierHash, newHash = header[20:36], header[36:52], md5.New
+		h0 := md5.Sum(passwordBytes(password))
+		var buf []byte
+		for i := 0; i < 16; i++ {
+			buf = append(append(buf, h0[:5]...), salt...)
+		}
+		h1 := md5.Sum(buf)
+		blockKey = func(block uint32) []byte {
+			h := md5.Sum(binary.LittleEndian.AppendUint32(append([]byte(nil), h1[:5]...), block))
+			return h[:]
+		}
+
+	case major >= 2 && major <= 4 && minor == 2:
+		// RC4 with CryptoAPI has a header like that of standard encryption
+		if len(header) < 12 {
+			return nil, ErrEncrypted
+		}
+		size := int(binary.LittleEndian.Uint32(header[8:]))
+		v := 12 + size
+		if size < 20 || len(header) < v+60 {
+			return nil, ErrEncrypted
+		}
+		keyBits := int(binary.LittleEndian.Uint32(header[12+16:]))
+		if keyBits == 0 {
+			keyBits = 40
+		}
+		// RC4 keys are 40 to 128 bits long, in steps of 8 bits
+		if keyBits%8 != 0 || keyBits < 40 || keyBits > 128 {
+			return nil, fmt.Errorf("%w: invalid key size %d", ErrCorrupt, keyBits)
+		}
+		salt := header[v+4 : v+20]
+		verifier, verifierHash, newHash = header[v+20:v+36], header[v+40:v+60], sha1.New
+		h0 := sha1.Sum(append(append([]byte(nil), salt...), passwordBytes(password)...))
+		blockKey = func(block uint32) []byte {
+			h := sha1.Sum(binary.LittleEndian.AppendUint32(append([]byte(nil), h0[:]...), block))
+			if keyBits == 40 {
+				// 40 bit keys are padded to 128 bits
+				return append(append([]byte(nil), h[:5]...), make([]byte, 11)...)
+			}
+			return h[:keyBits/8]
+		}
+
+	default:
+		return nil, ErrEncrypted
+	}
+
+	// The verifier and its hash are encrypted in turn with the key of the
+	// first block
+	c, err := rc4.NewCipher(blockKey(0))
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]byte, len(verifier)+len(verifierHash))
+	c.XORKeyStream(decrypted, append(append([]byte(nil), verifier...), verifierHash...))
+	h := newHash()
+	h.Write(decrypted[:len(verifier)])
+	if subtle.ConstantTimeCompare(h.Sum(nil), decrypted[len(verifier):]) != 1 {
+		return nil, ErrWrongPassword
+	}
+	return blockKey, nil
+}
+
+// decryptRC4 decrypts data encrypted with RC4 in blocks, each with its own
+// key
+func decryptRC4(data []byte, blockKey func(block uint32) []byte) []byte {
+	out := make([]byte, len(data))
+	for start := 0; start < len(data); start += rc4BlockSize {
+		end := min(start+rc4BlockSize, len(data))
+		c, _ := rc4.NewCipher(blockKey(uint32(start / rc4BlockSize)))
+		c.XORKeyStream(out[start:end], data[start:end])
+	}
+	return out
+}
+
+// passwordBytes returns a password as UTF-16LE, as it is hashed
+func passwordBytes(password string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(password)) {
+		out = binary.LittleEndian.AppendUint16(out, u)
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package word_extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// Errors returned by the extractors. They can be told apart with errors.Is,
+// and most are wrapped in an *ExtractError that tells where in the document
+// they occurred.
+var (
+	// ErrUnsupportedFormat is returned for files that are not documents of a
+	// format that can be read
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	// ErrCorrupt is returned for documents that are truncated or have
+	// structures that cannot be read
+	ErrCorrupt = errors.New("corrupt document")
+	// ErrEncrypted is returned for a password protected document when no
+	// password is given, or when its encryption is not supported
+	ErrEncrypted = errors.New("document is encrypted")
+	// ErrWrongPassword is returned when the password given does not decrypt
+	// a password protected document
+	ErrWrongPassword = errors.New("wrong password for encrypted document")
+	// ErrTooLarge is returned for documents that are too large to be read
+	ErrTooLarge = errors.New("document too large")
+)
+
+// Causes of corrupt documents that are checked for
+var (
+	errMissingStream = fmt.Errorf("%w: missing stream", ErrCorrupt)
+	errMissingPart   = fmt.Errorf("%w: missing part", ErrCorrupt)
+)
+
+// ExtractError is an error that occurred while reading a part of a document:
+// a stream of a compound file, or a part of a package.
+type ExtractError struct {
+	// Part is the name of the stream or part, such as "WordDocument" or
+	// "word/document.xml", or empty for the file as a whole
+	Part string
+	// Offset is the byte offset within the part, or -1 when it is not known
+	Offset int64
+	// Err is the cause of the error, which wraps one of the Err errors of
+	// this package
+	Err error
+}
+
+func (e *ExtractError) Error() string {
+	switch {
+	case e.Part == "":
+		return e.Err.Error()
+	case e.Offset < 0:
+		return e.Part + ": " + e.Err.Error()
+	}
+	return fmt.Sprintf("%s at offset %d: %v", e.Part, e.Offset, e.Err)
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// corruptError returns an ErrCorrupt error for a structure that cannot be
+// read at an offset of a part
+func corruptError(part string, offset int, format string, args ...interface{}) error {
+	return &ExtractError{Part: part, Offset: int64(offset), Err: fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))}
+}
+
+// partError gives the part and offset where an error occurred. Errors that
+// are not from this package, from reading a file or from a context, such as
+// XML syntax errors, are from corrupt documents. A nil error stays nil.
+func partError(part string, offset int64, err error) error {
+	var extractErr *ExtractError
+	var pathErr *fs.PathError
+	if err == nil || errors.As(err, &extractErr) || errors.As(err, &pathErr) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if !isExtractError(err) {
+		err = fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	return &ExtractError{Part: part, Offset: offset, Err: err}
+}
+
+// isExtractError tells whether an error wraps one of the Err errors of this
+// package
+func isExtractError(err error) bool {
+	for _, target := range []error{ErrUnsupportedFormat, ErrCorrupt, ErrEncrypted, ErrWrongPassword, ErrTooLarge} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
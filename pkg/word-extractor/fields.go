@@ -0,0 +1,205 @@
+package word_extractor
+
+import (
+	"sort"
+	"strings"
+)
+
+// Field is a field in the document body, such as a hyperlink, a mail merge
+// field or a table of contents
+type Field struct {
+	// Type is the first word of the instruction in upper case, such as
+	// HYPERLINK, MERGEFIELD, DATE, TOC, REF or FORMTEXT
+	Type string
+	// Instruction is the field code, without any fields nested in it
+	Instruction string
+	// Arguments are the words of the instruction after the type that are
+	// not switches, with quotes removed
+	Arguments []string
+	// Switches are the switches of the instruction in order, such as \l or \*
+	Switches []FieldSwitch
+	// Result is the text Word last displayed for the field
+	Result string
+	// Offset is the character (rune) offset of the result within Body, and
+	// Length its length in characters
+	Offset int
+	Length int
+	// Depth is the number of fields this field is nested in
+	Depth int
+
+	// begin orders fields by where they start in the story
+	begin int
+}
+
+// FieldSwitch is a switch in a field instruction, such as \* MERGEFORMAT
+type FieldSwitch struct {
+	Name string
+	// Value is the argument of the switch, which is empty for a switch that
+	// takes none
+	Value string
+}
+
+// Switch returns the value of the first switch with the given name, such as
+// `\l`, and whether the field has it
+func (f *Field) Switch(name string) (string, bool) {
+	for _, s := range f.Switches {
+		if s.Name == name {
+			return s.Value, true
+		}
+	}
+	return "", false
+}
+
+// fieldFlags lists the switches of common fields that take no argument. The
+// general switches \*, \# and \@ always take one, and any other switch takes
+// the word after it unless that is a switch too.
+var fieldFlags = map[string][]string{
+	"DATE":           {`\h`, `\l`, `\s`},
+	"HYPERLINK":      {`\h`, `\m`, `\n`},
+	"INCLUDEPICTURE": {`\d`},
+	"MERGEFIELD":     {`\m`, `\v`},
+	"NOTEREF":        {`\f`, `\h`, `\p`},
+	"PAGEREF":        {`\h`, `\p`},
+	"REF":            {`\f`, `\h`, `\n`, `\p`, `\r`, `\t`, `\w`},
+	"SEQ":            {`\c`, `\h`, `\n`},
+	"TIME":           {`\h`, `\l`, `\s`},
+	"TOC":            {`\h`, `\u`, `\w`, `\x`, `\z`},
+}
+
+// parseField reads a field instruction into a Field
+func parseField(instruction string) *Field {
+	field := &Field{Instruction: strings.TrimSpace(instruction)}
+	words := splitFieldInstruction(instruction)
+	if len(words) == 0 {
+		return field
+	}
+	field.Type = strings.ToUpper(words[0].text)
+
+	isFlag := func(name string) bool {
+		if name == `\!` {
+			return true
+		}
+		for _, flag := range fieldFlags[field.Type] {
+			if strings.EqualFold(flag, name) {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word.text, `\`) || word.quoted {
+			field.Arguments = append(field.Arguments, word.text)
+			continue
+		}
+		sw := FieldSwitch{Name: word.text}
+		general := word.text == `\*` || word.text == `\#` || word.text == `\@`
+		if i+1 < len(words) && !isFlag(word.text) && (general || !strings.HasPrefix(words[i+1].text, `\`) || words[i+1].quoted) {
+			sw.Value = words[i+1].text
+			i++
+		}
+		field.Switches = append(field.Switches, sw)
+	}
+	return field
+}
+
+// fieldWord is a word of a field instruction
+type fieldWord struct {
+	text   string
+	quoted bool
+}
+
+// splitFieldInstruction splits a field instruction into its words. Quoted
+// arguments are kept together, without their quotes, and a backslash escapes
+// the character after it inside quotes.
+func splitFieldInstruction(instruction string) []fieldWord {
+	var words []fieldWord
+	var word strings.Builder
+	inWord, quoted, wasQuoted := false, false, false
+	runes := []rune(instruction)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quoted && r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+			i++
+			word.WriteRune(runes[i])
+		case r == '"':
+			quoted = !quoted
+			inWord, wasQuoted = true, true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == 0xA0):
+			if inWord {
+				words = append(words, fieldWord{text: word.String(), quoted: wasQuoted})
+				word.Reset()
+				inWord, wasQuoted = false, false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, fieldWord{text: word.String(), quoted: wasQuoted})
+	}
+	return words
+}
+
+// fieldSpan is a field found in a run of .doc text, given as the indexes of
+// its begin, separator and end marks. separator is -1 when the field has no
+// result.
+type fieldSpan struct {
+	begin     int
+	separator int
+	end       int
+	depth     int
+}
+
+// resultStart returns the index of the first mark before the field result
+func (f fieldSpan) resultStart() int {
+	if f.separator >= 0 {
+		return f.separator
+	}
+	return f.end
+}
+
+// fieldInstruction returns the instruction of a .doc field as text. Fields
+// nested in the instruction are left out, along with control characters such
+// as the anchor of field data.
+func fieldInstruction(text []rune, field fieldSpan) string {
+	var result strings.Builder
+	depth := 0
+	for _, r := range text[field.begin+1 : field.resultStart()] {
+		switch {
+		case r == 0x13:
+			depth++
+		case r == 0x15:
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && (r >= 0x20 || r == '\t'):
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+// setFieldResults fills in the result of each field from the rendered body
+func setFieldResults(fields []*Field, body []rune) {
+	for _, field := range fields {
+		start, end := field.Offset, field.Offset+field.Length
+		if start > len(body) {
+			start = len(body)
+		}
+		if end > len(body) {
+			end = len(body)
+		}
+		field.Result = string(body[start:end])
+	}
+}
+
+// sortFields puts fields in the order they begin, so that a field comes
+// before the fields nested in it
+func sortFields(fields []*Field) {
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].begin < fields[j].begin
+	})
+}
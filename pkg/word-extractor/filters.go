@@ -70,7 +70,7 @@ var fieldRegex = regexp.MustCompile(`(?:\x13[^\x13\x14\x15]*\x14?([^\x13\x14\x15
 func bufferToUCS2String(buffer []byte) (string, error) {
 	// Ensure the buffer length is even, as UCS-2 characters are 2 bytes each
 	if len(buffer)%2 != 0 {
-		return "", fmt.Errorf("buffer length must be even, got %d", len(buffer))
+		return "", fmt.Errorf("%w: UCS-2 text of odd length %d", ErrCorrupt, len(buffer))
 	}
 
 	// Read uint16 values from the buffer
@@ -151,6 +151,61 @@ func cleanText(text string) string {
 	return text
 }
 
+// cleanChar maps a single character the way cleanText does, returning an
+// empty string for the characters cleanText drops
+func cleanChar(r rune) string {
+	switch r {
+	case 0x07:
+		return "\t"
+	case 0x0A, 0x0B, 0x0C, 0x0D:
+		return "\n"
+	case 0x1F:
+		return ""
+	}
+	if r <= 0x08 {
+		return ""
+	}
+	return string(r)
+}
+
+// visibleFieldChars works out which characters survive field processing. Field
+// begin, separator and end marks are hidden, as is each field's instruction,
+// leaving only the field results. Unbalanced marks are left visible, just as
+// fieldRegex leaves them in place. The balanced fields are returned too, in
+// the order they end.
+func visibleFieldChars(text []rune) ([]bool, []fieldSpan) {
+	visible := make([]bool, len(text))
+	for i := range visible {
+		visible[i] = true
+	}
+
+	var stack []fieldSpan
+	var fields []fieldSpan
+	for i, r := range text {
+		switch r {
+		case 0x13:
+			stack = append(stack, fieldSpan{begin: i, separator: -1, depth: len(stack)})
+		case 0x14:
+			if len(stack) > 0 && stack[len(stack)-1].separator < 0 {
+				stack[len(stack)-1].separator = i
+			}
+		case 0x15:
+			if len(stack) == 0 {
+				continue
+			}
+			field := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			field.end = i
+			for j := field.begin; j <= field.resultStart(); j++ {
+				visible[j] = false
+			}
+			visible[i] = false
+			fields = append(fields, field)
+		}
+	}
+	return visible, fields
+}
+
 // Helper function to check if text contains non-whitespace characters
 func containsNonWhitespace(s string) bool {
 	for _, r := range s {
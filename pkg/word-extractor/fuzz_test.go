@@ -0,0 +1,162 @@
+package word_extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// The fuzz targets are seeded with the documents of tests/data, and with
+// small documents of the formats that have none there. Inputs that once made
+// them fail are kept in testdata/fuzz, and are run as regression tests by go
+// test.
+
+// fuzzLimits keeps each input of the fuzz targets small and quick
+var fuzzLimits = Limits{
+	MaxInputSize:        4 << 20,
+	MaxPartSize:         16 << 20,
+	MaxTotalSize:        64 << 20,
+	MaxCompressionRatio: 100,
+	MaxXMLDepth:         256,
+	MaxXMLTokens:        1 << 20,
+	MaxNesting:          1024,
+	MaxOutputChars:      1 << 20,
+	Timeout:             5 * time.Second,
+}
+
+// addTestDocuments adds the files of tests/data to the seed corpus
+func addTestDocuments(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("..", "..", "tests", "data", "*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+// fuzzRTF, fuzzFlatOPC and fuzzWordML are small documents with paragraphs,
+// tables, notes and tracked changes
+const fuzzRTF = `{\rtf1\ansi\ansicpg1252{\fonttbl{\f0 Arial;}}{\*\revtbl{Unknown;}{Jane Doe;}}` +
+	`{\header Header\par}\pard Hello {\b bold}{\revised\revauth1 new} text{\footnote Note\par}\par` +
+	`\trowd\cellx1000\cellx2000 A1\cell B1\cell\row{\field{\*\fldinst HYPERLINK "http://example.com/"}{\fldrslt Link}}\par}`
+
+const fuzzFlatOPC = `<?xml version="1.0" encoding="UTF-8"?>
+<pkg:package xmlns:pkg="http://schemas.microsoft.com/office/2006/xmlPackage">
+<pkg:part pkg:name="/_rels/.rels" pkg:contentType="application/vnd.openxmlformats-package.relationships+xml"><pkg:xmlData><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/></Relationships></pkg:xmlData></pkg:part>
+<pkg:part pkg:name="/word/document.xml" pkg:contentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"><pkg:xmlData><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+	`<w:p><w:r><w:t>Hello</w:t></w:r><w:ins w:author="Jane Doe"><w:r><w:t> new</w:t></w:r></w:ins></w:p>` +
+	`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>A1</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>B1</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+	`</w:body></w:document></pkg:xmlData></pkg:part>
+</pkg:package>`
+
+const fuzzWordML = `<?xml version="1.0" encoding="UTF-8"?>
+<w:wordDocument xmlns:w="http://schemas.microsoft.com/office/word/2003/wordml" xmlns:aml="http://schemas.microsoft.com/aml/2001/core"><w:body>` +
+	`<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:footnote><w:p><w:r><w:t>Note</w:t></w:r></w:p></w:footnote></w:r></w:p>` +
+	`<w:p><aml:annotation aml:id="1" aml:author="Jane Doe" w:type="Word.Insertion"><aml:content><w:r><w:t>new</w:t></w:r></aml:content></aml:annotation></w:p>` +
+	`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>A1</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+	`</w:body></w:wordDocument>`
+
+// fuzzODT builds a small OpenDocument text package
+func fuzzODT(f *testing.F) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range []struct{ name, data string }{
+		{"mimetype", "application/vnd.oasis.opendocument.text"},
+		{"content.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"><office:body><office:text>` +
+			`<text:h text:outline-level="1">Heading</text:h><text:p>Hello<text:note text:note-class="footnote"><text:note-body><text:p>Note</text:p></text:note-body></text:note></text:p>` +
+			`<table:table><table:table-row><table:table-cell><text:p>A1</text:p></table:table-cell></table:table-row></table:table>` +
+			`</office:text></office:body></office:document-content>`},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Store})
+		if err != nil {
+			f.Fatal(err)
+		}
+		if _, err := w.Write([]byte(part.data)); err != nil {
+			f.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		f.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func FuzzExtract(f *testing.F) {
+	addTestDocuments(f)
+	f.Add([]byte(fuzzRTF))
+	f.Add(fuzzODT(f))
+	f.Add([]byte(fuzzFlatOPC))
+	f.Add([]byte(fuzzWordML))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Extract recovers from panics, which would hide them
+		w := &WordExtractor{Options: ExtractOptions{Limits: fuzzLimits, EmbeddedDocuments: true}}
+		doc, err := w.extract(newLimiter(context.Background(), w.Options.Limits), bytes.NewReader(data), 0)
+		if err != nil {
+			if !isExtractError(err) {
+				t.Fatalf("error does not wrap an error of this package: %v", err)
+			}
+			return
+		}
+		if doc == nil {
+			t.Fatal("no document and no error")
+		}
+	})
+}
+
+func FuzzCleanText(f *testing.F) {
+	f.Add("Plain text")
+	f.Add("Cell\x07Row\x07\x07Line\x0bPage\x0cParagraph\x0d")
+	f.Add("\x13 HYPERLINK \"x\" \x14Link\x15\x02\x05\x08\x1f\x1e\xa0")
+	f.Fuzz(func(t *testing.T, text string) {
+		cleaned := cleanText(text)
+		if utf8.ValidString(text) && !utf8.ValidString(cleaned) {
+			t.Fatalf("cleanText(%q) = %q is not valid UTF-8", text, cleaned)
+		}
+	})
+}
+
+func FuzzProcessSprms(f *testing.F) {
+	f.Add([]byte{0x16, 0x24, 0x01, 0x17, 0x24, 0x01}, 0)
+	f.Add([]byte{0x00, 0x00, 0x08, 0xD6, 0x04, 0x00, 0x02, 0x01}, 2)
+	f.Add([]byte{0x08, 0xD6, 0xFF, 0xFF}, 0)
+	f.Fuzz(func(t *testing.T, buffer []byte, offset int) {
+		processSprms(buffer, offset, func(buffer []byte, offset int, sprm uint16, ispmd uint16, fspec uint8, sgc uint8, spra uint8) {
+			if offset < 0 || offset > len(buffer) {
+				t.Fatalf("operand offset %d outside buffer of %d bytes", offset, len(buffer))
+			}
+			if sprm == sprmTDefTable {
+				readTableDefinition(buffer[offset:], 20)
+			}
+		})
+	})
+}
+
+func FuzzBufferToUCS2String(f *testing.F) {
+	f.Add([]byte("H\x00i\x00"))
+	f.Add([]byte{0x3D, 0xD8, 0x00, 0xDE})
+	f.Add([]byte{0x00, 0xD8})
+	f.Add([]byte{0x41})
+	f.Fuzz(func(t *testing.T, buffer []byte) {
+		text, err := bufferToUCS2String(buffer)
+		if err != nil {
+			if len(buffer)%2 == 0 {
+				t.Fatalf("error for text of even length: %v", err)
+			}
+			return
+		}
+		if !utf8.ValidString(text) {
+			t.Fatalf("bufferToUCS2String(%x) = %q is not valid UTF-8", buffer, text)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package word_extractor
+
+import (
+	"sort"
+	"strings"
+)
+
+// Hyperlink is a link in the document body
+type Hyperlink struct {
+	// Text is the text shown for the link
+	Text string
+	// URL is the target of a link to outside the document
+	URL string
+	// Anchor is the bookmark a link points to, either within the document or
+	// within the target of URL
+	Anchor string
+	// Offset is the character (rune) offset of the link text within Body,
+	// and Length its length in characters
+	Offset int
+	Length int
+}
+
+// target returns the link target as it is written by inline rendering
+func (h *Hyperlink) target() string {
+	if h.Anchor == "" {
+		return h.URL
+	}
+	return h.URL + "#" + h.Anchor
+}
+
+// instruction returns the HYPERLINK field instruction for the link
+func (h *Hyperlink) instruction() string {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	instruction := "HYPERLINK"
+	if h.URL != "" {
+		instruction += ` "` + quote.Replace(h.URL) + `"`
+	}
+	if h.Anchor != "" {
+		instruction += ` \l "` + quote.Replace(h.Anchor) + `"`
+	}
+	return instruction
+}
+
+// fieldHyperlink makes a link from a HYPERLINK field, as in
+// HYPERLINK "http://example.com" \l "bookmark". It returns false for any
+// other field.
+func fieldHyperlink(field *Field) (*Hyperlink, bool) {
+	if field.Type != "HYPERLINK" {
+		return nil, false
+	}
+	link := &Hyperlink{Offset: field.Offset, Length: field.Length}
+	if len(field.Arguments) > 0 {
+		link.URL = field.Arguments[0]
+	}
+	link.Anchor, _ = field.Switch(`\l`)
+	return link, true
+}
+
+// setHyperlinkText fills in the text of each link from the rendered body and
+// puts the links in document order
+func setHyperlinkText(links []*Hyperlink, body []rune) {
+	for _, link := range links {
+		start, end := link.Offset, link.Offset+link.Length
+		if start > len(body) {
+			start = len(body)
+		}
+		if end > len(body) {
+			end = len(body)
+		}
+		link.Text = string(body[start:end])
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].Offset < links[j].Offset
+	})
+}
+
+// hyperlinkTargets returns the target of each link as text to write after
+// the link, as in "text <http://example.com>"
+func hyperlinkTargets(links []*Hyperlink, length int) []insertion {
+	var result []insertion
+	last := 0
+	for _, link := range links {
+		end := link.Offset + link.Length
+		target := link.target()
+		if target == "" || end < last || end > length {
+			continue
+		}
+		result = append(result, insertion{offset: end, text: " <" + target + ">"})
+		last = end
+	}
+	return result
+}
@@ -0,0 +1,319 @@
+package word_extractor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"unicode/utf16"
+)
+
+// Image is a picture in the document body
+type Image struct {
+	// Name is the file name of the image, taken from its part in .docx files
+	// and numbered in order, such as "image1.png", in .doc files
+	Name string
+	// ContentType is the MIME type of the image, such as "image/png"
+	ContentType string
+	// Data holds the bytes of the image file
+	Data []byte
+	// AltText is the alternative text, or description, of the picture
+	AltText string
+	// Offset is the character (rune) offset within Body where the picture is
+	// anchored
+	Offset int
+}
+
+// Image content types, as written in the content types of a .docx file
+const (
+	contentTypeEMF  = "image/x-emf"
+	contentTypeWMF  = "image/x-wmf"
+	contentTypePICT = "image/x-pict"
+	contentTypeJPEG = "image/jpeg"
+	contentTypePNG  = "image/png"
+	contentTypeBMP  = "image/bmp"
+	contentTypeTIFF = "image/tiff"
+)
+
+// imageExtensions gives the file name extension for each image content type
+var imageExtensions = map[string]string{
+	contentTypeEMF:  "emf",
+	contentTypeWMF:  "wmf",
+	contentTypePICT: "pict",
+	contentTypeJPEG: "jpeg",
+	contentTypePNG:  "png",
+	contentTypeBMP:  "bmp",
+	contentTypeTIFF: "tiff",
+}
+
+// imageName numbers an image file name the way Word names media parts
+func imageName(n int, contentType string) string {
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		ext = "bin"
+	}
+	return "image" + strconv.Itoa(n) + "." + ext
+}
+
+// Office drawing record types
+const (
+	artDggContainer    = 0xF000
+	artBStoreContainer = 0xF001
+	artDgContainer     = 0xF002
+	artSpgrContainer   = 0xF003
+	artSpContainer     = 0xF004
+	artFBSE            = 0xF007
+	artFSP             = 0xF00A
+	artFOPT            = 0xF00B
+	artSecondaryFOPT   = 0xF121
+	artTertiaryFOPT    = 0xF122
+	artBlipEMF         = 0xF01A
+	artBlipWMF         = 0xF01B
+	artBlipPICT        = 0xF01C
+	artBlipJPEG        = 0xF01D
+	artBlipPNG         = 0xF01E
+	artBlipDIB         = 0xF01F
+	artBlipTIFF        = 0xF029
+	artBlipCMYKJPEG    = 0xF02A
+)
+
+// Shape properties read from drawing property tables
+const (
+	propPib         = 0x0104
+	propDescription = 0x0381
+)
+
+// artRecord is a record of an Office drawing, whose data holds either its
+// fields or, for containers, the records it contains
+type artRecord struct {
+	inst uint16
+	typ  uint16
+	data []byte
+}
+
+// readArtRecord reads the record at the start of a drawing buffer and
+// returns it with its size, or a size of zero when it runs past the end
+func readArtRecord(data []byte) (artRecord, int) {
+	if len(data) < 8 {
+		return artRecord{}, 0
+	}
+	header := binary.LittleEndian.Uint16(data)
+	size := binary.LittleEndian.Uint32(data[4:])
+	if uint64(size) > uint64(len(data)-8) {
+		return artRecord{}, 0
+	}
+	return artRecord{
+		inst: header >> 4,
+		typ:  binary.LittleEndian.Uint16(data[2:]),
+		data: data[8 : 8+size],
+	}, 8 + int(size)
+}
+
+// readArtRecords reads the records in a drawing buffer, stopping at the first
+// one that runs past its end
+func readArtRecords(data []byte) []artRecord {
+	var records []artRecord
+	for {
+		rec, size := readArtRecord(data)
+		if size == 0 {
+			return records
+		}
+		records = append(records, rec)
+		data = data[size:]
+	}
+}
+
+// artShape is a picture shape of a drawing, with the blip store entry of its
+// picture counted from 1. Shapes showing an embedded object are marked ole.
+type artShape struct {
+	id      uint32
+	pib     int
+	ole     bool
+	altText string
+}
+
+// readArtShape reads the id and picture properties of a shape container
+func readArtShape(data []byte) artShape {
+	var shape artShape
+	for _, rec := range readArtRecords(data) {
+		switch rec.typ {
+		case artFSP:
+			if len(rec.data) >= 8 {
+				shape.id = binary.LittleEndian.Uint32(rec.data)
+				shape.ole = binary.LittleEndian.Uint32(rec.data[4:])&0x10 != 0
+			}
+		case artFOPT, artSecondaryFOPT, artTertiaryFOPT:
+			readArtProperties(rec, func(id uint16, value uint32, complex []byte) {
+				switch id {
+				case propPib:
+					shape.pib = int(value)
+				case propDescription:
+					shape.altText = utf16String(complex)
+				}
+			})
+		}
+	}
+	return shape
+}
+
+// readArtShapes reads the picture shapes of a drawing by shape id. The first
+// shape of a group container is the group itself, which is given the
+// pictures of all the shapes in the group.
+func readArtShapes(data []byte, group bool, shapes map[uint32][]artShape) []artShape {
+	var pictures []artShape
+	var groupID uint32
+	for i, rec := range readArtRecords(data) {
+		switch rec.typ {
+		case artSpContainer:
+			shape := readArtShape(rec.data)
+			if group && i == 0 {
+				groupID = shape.id
+			} else if shape.pib > 0 && !shape.ole {
+				shapes[shape.id] = []artShape{shape}
+				pictures = append(pictures, shape)
+			}
+		case artSpgrContainer:
+			pictures = append(pictures, readArtShapes(rec.data, true, shapes)...)
+		}
+	}
+	if group && len(pictures) > 0 {
+		shapes[groupID] = pictures
+	}
+	return pictures
+}
+
+// readArtProperties visits the properties of a property table. The data of
+// complex properties follows the table, in the order of the properties.
+func readArtProperties(rec artRecord, visit func(id uint16, value uint32, complex []byte)) {
+	count := int(rec.inst)
+	if count*6 > len(rec.data) {
+		return
+	}
+	extra := rec.data[count*6:]
+	for i := 0; i < count; i++ {
+		opid := binary.LittleEndian.Uint16(rec.data[i*6:])
+		value := binary.LittleEndian.Uint32(rec.data[i*6+2:])
+		var complex []byte
+		if opid&0x8000 != 0 {
+			if uint64(value) > uint64(len(extra)) {
+				return
+			}
+			complex, extra = extra[:value], extra[value:]
+		}
+		visit(opid&0x3FFF, value, complex)
+	}
+}
+
+// utf16String decodes a null terminated little endian UTF-16 string
+func utf16String(data []byte) string {
+	chars := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		c := binary.LittleEndian.Uint16(data[i:])
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return string(utf16.Decode(chars))
+}
+
+// blipStoreEntry returns the picture of a blip store entry. The picture is
+// either kept in the entry or found at its offset in the delay stream.
+func blipStoreEntry(rec artRecord, delay []byte) (*Image, bool) {
+	if rec.typ != artFBSE || len(rec.data) < 36 {
+		return nil, false
+	}
+	if name := 36 + int(rec.data[33]); name < len(rec.data) {
+		if blips := readArtRecords(rec.data[name:]); len(blips) > 0 {
+			return readBlip(blips[0])
+		}
+	}
+	size := binary.LittleEndian.Uint32(rec.data[20:])
+	offset := binary.LittleEndian.Uint32(rec.data[28:])
+	if size == 0 || uint64(offset)+uint64(size) > uint64(len(delay)) {
+		return nil, false
+	}
+	if blips := readArtRecords(delay[offset : offset+size]); len(blips) > 0 {
+		return readBlip(blips[0])
+	}
+	return nil, false
+}
+
+// readBlip decodes the picture of a blip record. Metafiles are stored
+// compressed behind a header; bitmaps are stored as files, except for device
+// independent bitmaps, which lack the bitmap file header.
+func readBlip(rec artRecord) (*Image, bool) {
+	// Blips have one identifier, or two when their instance is odd
+	header := 16
+	if rec.inst&1 == 1 {
+		header = 32
+	}
+
+	var contentType string
+	switch rec.typ {
+	case artBlipEMF, artBlipWMF, artBlipPICT:
+		if len(rec.data) < header+34 {
+			return nil, false
+		}
+		meta := rec.data[header:]
+		size := binary.LittleEndian.Uint32(meta[28:])
+		data := meta[34:]
+		if uint64(size) < uint64(len(data)) {
+			data = data[:size]
+		}
+		if meta[32] == 0 {
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, false
+			}
+			if data, err = io.ReadAll(reader); err != nil {
+				return nil, false
+			}
+		}
+		contentType = map[uint16]string{artBlipEMF: contentTypeEMF, artBlipWMF: contentTypeWMF, artBlipPICT: contentTypePICT}[rec.typ]
+		return &Image{ContentType: contentType, Data: data}, true
+	case artBlipJPEG, artBlipCMYKJPEG:
+		contentType = contentTypeJPEG
+	case artBlipPNG:
+		contentType = contentTypePNG
+	case artBlipTIFF:
+		contentType = contentTypeTIFF
+	case artBlipDIB:
+		if len(rec.data) < header+1 {
+			return nil, false
+		}
+		return &Image{ContentType: contentTypeBMP, Data: bitmapFile(rec.data[header+1:])}, true
+	default:
+		return nil, false
+	}
+	if len(rec.data) < header+1 {
+		return nil, false
+	}
+	return &Image{ContentType: contentType, Data: rec.data[header+1:]}, true
+}
+
+// bitmapFile puts a bitmap file header in front of a device independent
+// bitmap, pointing past its header and color table to the pixels
+func bitmapFile(dib []byte) []byte {
+	offset := 14
+	if len(dib) >= 36 {
+		size := int(binary.LittleEndian.Uint32(dib))
+		bits := int(binary.LittleEndian.Uint16(dib[14:]))
+		colors := int(binary.LittleEndian.Uint32(dib[32:]))
+		if colors == 0 && bits <= 8 {
+			colors = 1 << bits
+		}
+		offset += size + colors*4
+		if size == 40 && binary.LittleEndian.Uint32(dib[16:]) == 3 {
+			// Bit field masks follow the header
+			offset += 12
+		}
+	}
+
+	file := make([]byte, 14, 14+len(dib))
+	copy(file, "BM")
+	binary.LittleEndian.PutUint32(file[2:], uint32(len(file)+len(dib)))
+	binary.LittleEndian.PutUint32(file[10:], uint32(offset))
+	return append(file, dib...)
+}
@@ -0,0 +1,271 @@
+package word_extractor
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits bounds the resources used to extract a document, for documents that
+// cannot be trusted. A limit of zero is not enforced.
+type Limits struct {
+	// MaxInputSize is the size in bytes of the file read
+	MaxInputSize int64
+	// MaxPartSize is the size in bytes of a package part once decompressed,
+	// or of a stream of a compound file
+	MaxPartSize int64
+	// MaxTotalSize is the size in bytes of all the parts and streams read
+	MaxTotalSize int64
+	// MaxCompressionRatio is the size of a package part once decompressed
+	// over its compressed size
+	MaxCompressionRatio float64
+	// MaxXMLDepth is how deep the elements of an XML part are nested, and
+	// MaxXMLTokens the number of XML tokens read in all parts
+	MaxXMLDepth  int
+	MaxXMLTokens int
+	// MaxNesting is how deep the groups of an RTF file are nested
+	MaxNesting int
+	// MaxOutputChars is the number of characters of the text extracted
+	MaxOutputChars int
+	// Timeout is how long the extraction may take
+	Timeout time.Duration
+}
+
+// LimitError is returned when a document goes over one of its Limits. It
+// wraps ErrTooLarge.
+type LimitError struct {
+	// Limit is the name of the field of Limits that was gone over, such as
+	// "MaxPartSize"
+	Limit string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: over %s", ErrTooLarge, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrTooLarge
+}
+
+// limiter enforces the Limits of one extraction, and stops it once its
+// context is done. A nil limiter enforces none.
+type limiter struct {
+	Limits
+	ctx      context.Context
+	deadline time.Time
+	total    int64
+	tokens   int
+	chars    int
+}
+
+// newLimiter starts enforcing limits, with the deadline counted from now
+func newLimiter(ctx context.Context, limits Limits) *limiter {
+	l := &limiter{Limits: limits, ctx: ctx}
+	if limits.Timeout > 0 {
+		l.deadline = time.Now().Add(limits.Timeout)
+	}
+	return l
+}
+
+// check returns an error once the deadline has passed or too much text has
+// been extracted, or the error of the context once it is done
+func (l *limiter) check() error {
+	if l == nil {
+		return nil
+	}
+	if err := l.ctx.Err(); err != nil {
+		return err
+	}
+	if l.overOutput() {
+		return &LimitError{Limit: "MaxOutputChars"}
+	}
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		return &LimitError{Limit: "Timeout"}
+	}
+	return nil
+}
+
+// checked tells whether check can return an error
+func (l *limiter) checked() bool {
+	return !l.deadline.IsZero() || l.ctx.Done() != nil
+}
+
+// checkInput checks the size of the file read. The reader is left at the
+// start.
+func (l *limiter) checkInput(reader io.ReadSeeker) error {
+	if l == nil || l.MaxInputSize <= 0 {
+		return nil
+	}
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if size > l.MaxInputSize {
+		return &LimitError{Limit: "MaxInputSize"}
+	}
+	return nil
+}
+
+// add counts bytes read from a part or stream of the given size so far
+func (l *limiter) add(n, size int64) error {
+	if l == nil {
+		return nil
+	}
+	l.total += n
+	switch {
+	case l.MaxPartSize > 0 && size > l.MaxPartSize:
+		return &LimitError{Limit: "MaxPartSize"}
+	case l.MaxTotalSize > 0 && l.total > l.MaxTotalSize:
+		return &LimitError{Limit: "MaxTotalSize"}
+	}
+	return l.check()
+}
+
+// checkStream checks the size of a compound file stream before it is read
+func (l *limiter) checkStream(name string, size int64) error {
+	if err := l.add(size, size); err != nil {
+		return &ExtractError{Part: name, Offset: -1, Err: err}
+	}
+	return nil
+}
+
+// openPart opens a part of a package. The sizes recorded for a part of a ZIP
+// package are checked before it is read, and the bytes are counted as it is
+// read, as the recorded sizes may not be true.
+func (l *limiter) openPart(name string, f packageFile) (io.ReadCloser, error) {
+	var compressed int64
+	if zf, ok := f.(*zip.File); ok && l != nil {
+		compressed = int64(zf.CompressedSize64)
+		if l.MaxPartSize > 0 && zf.UncompressedSize64 > uint64(l.MaxPartSize) {
+			return nil, &ExtractError{Part: name, Offset: -1, Err: &LimitError{Limit: "MaxPartSize"}}
+		}
+		if l.overRatio(int64(zf.UncompressedSize64), compressed) {
+			return nil, &ExtractError{Part: name, Offset: -1, Err: &LimitError{Limit: "MaxCompressionRatio"}}
+		}
+	}
+	rc, err := f.Open()
+	if err != nil || l == nil {
+		return rc, err
+	}
+	return &partReader{ReadCloser: rc, limits: l, compressed: compressed}, nil
+}
+
+// overRatio tells whether a part decompresses to more than the compression
+// ratio allows
+func (l *limiter) overRatio(size, compressed int64) bool {
+	return l.MaxCompressionRatio > 0 && compressed > 0 && float64(size) > float64(compressed)*l.MaxCompressionRatio
+}
+
+// partReader counts the bytes read from a part against the limits
+type partReader struct {
+	io.ReadCloser
+	limits     *limiter
+	compressed int64
+	read       int64
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+	if limitErr := r.limits.add(int64(n), r.read); limitErr != nil {
+		return n, limitErr
+	}
+	if r.limits.overRatio(r.read, r.compressed) {
+		return n, &LimitError{Limit: "MaxCompressionRatio"}
+	}
+	return n, err
+}
+
+// newDecoder returns a decoder of XML, and a function giving its offset in
+// the XML read. When the XML is limited, its tokens are counted as they are
+// read.
+func (l *limiter) newDecoder(r io.Reader) (*xml.Decoder, func() int64) {
+	decoder := xml.NewDecoder(r)
+	if l == nil || (l.MaxXMLDepth <= 0 && l.MaxXMLTokens <= 0 && l.MaxOutputChars <= 0 && !l.checked()) {
+		return decoder, decoder.InputOffset
+	}
+	return xml.NewTokenDecoder(&xmlCounter{decoder: decoder, limits: l}), decoder.InputOffset
+}
+
+// xmlCounter counts the tokens of XML and how deep its elements are nested
+type xmlCounter struct {
+	decoder *xml.Decoder
+	limits  *limiter
+	depth   int
+}
+
+func (c *xmlCounter) Token() (xml.Token, error) {
+	token, err := c.decoder.Token()
+	if err != nil {
+		return token, err
+	}
+	l := c.limits
+	switch token.(type) {
+	case xml.StartElement:
+		c.depth++
+		if l.MaxXMLDepth > 0 && c.depth > l.MaxXMLDepth {
+			return nil, &LimitError{Limit: "MaxXMLDepth"}
+		}
+	case xml.EndElement:
+		c.depth--
+	}
+	l.tokens++
+	if l.MaxXMLTokens > 0 && l.tokens > l.MaxXMLTokens {
+		return nil, &LimitError{Limit: "MaxXMLTokens"}
+	}
+	// Checking the time for every token would slow reading down
+	if l.tokens%1024 == 0 {
+		if err := l.check(); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+// checkNesting checks how deep the groups of a document are nested
+func (l *limiter) checkNesting(depth int) error {
+	if l == nil || l.MaxNesting <= 0 || depth <= l.MaxNesting {
+		return nil
+	}
+	return &LimitError{Limit: "MaxNesting"}
+}
+
+// addOutput counts n characters of text as they are extracted. Once they go
+// over MaxOutputChars the error is returned, and check returns it from then
+// on so that the extraction stops at its next check.
+func (l *limiter) addOutput(n int) error {
+	if l == nil || l.MaxOutputChars <= 0 {
+		return nil
+	}
+	l.chars += n
+	if l.overOutput() {
+		return &LimitError{Limit: "MaxOutputChars"}
+	}
+	return nil
+}
+
+func (l *limiter) overOutput() bool {
+	return l.MaxOutputChars > 0 && l.chars > l.MaxOutputChars
+}
+
+// checkOutput checks the number of characters of the text extracted
+func (l *limiter) checkOutput(doc *Document) error {
+	if l == nil || l.MaxOutputChars <= 0 {
+		return nil
+	}
+	chars := 0
+	for _, text := range []string{doc.Body, doc.Footnotes, doc.Endnotes, doc.Headers, doc.Footers, doc.Annotations, doc.Textboxes, doc.HeaderTextboxes} {
+		chars += utf8.RuneCountInString(text)
+	}
+	if chars > l.MaxOutputChars {
+		return &LimitError{Limit: "MaxOutputChars"}
+	}
+	return nil
+}
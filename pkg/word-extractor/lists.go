@@ -0,0 +1,239 @@
+package word_extractor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ListItem is the list numbering of a paragraph
+type ListItem struct {
+	// Level is the nesting level of the item, from 1 for the outermost level
+	Level int
+	// Label is the number or bullet shown before the paragraph, such as
+	// "1.", "4.2(b)" or "•"
+	Label string
+	// Number is the count of the item at its level, and Bullet tells whether
+	// the level is bulleted rather than numbered
+	Number int
+	Bullet bool
+}
+
+// Number formats of list levels, named as in WordprocessingML
+const (
+	formatDecimal     = "decimal"
+	formatDecimalZero = "decimalZero"
+	formatUpperRoman  = "upperRoman"
+	formatLowerRoman  = "lowerRoman"
+	formatUpperLetter = "upperLetter"
+	formatLowerLetter = "lowerLetter"
+	formatOrdinal     = "ordinal"
+	formatBullet      = "bullet"
+	formatNone        = "none"
+)
+
+// listLevel is the numbering of one level of a list
+type listLevel struct {
+	start  int
+	format string
+	// text is the label template, where %1 to %9 stand for the numbers of
+	// levels 1 to 9
+	text string
+	// restart is the number of outer levels whose items restart this level,
+	// or -1 when an item at any outer level does
+	restart int
+	// legal writes the numbers of all levels in the label as decimals
+	legal bool
+}
+
+// listDefinition is the numbering of the levels of a list
+type listDefinition struct {
+	levels [9]listLevel
+}
+
+func newListDefinition() *listDefinition {
+	def := &listDefinition{}
+	for i := range def.levels {
+		def.levels[i] = listLevel{start: 1, format: formatDecimal, restart: -1}
+	}
+	return def
+}
+
+// listCounts holds the current number of each level of a list
+type listCounts struct {
+	count [9]int
+	used  [9]bool
+}
+
+// listNumbering numbers list items in document order. Lists are keyed by
+// their definition, so that every paragraph using a definition continues the
+// same numbering, even across the list instances that may restart it.
+type listNumbering struct {
+	lists     map[string]*listCounts
+	instances map[string]bool
+}
+
+func newListNumbering() *listNumbering {
+	return &listNumbering{lists: make(map[string]*listCounts), instances: make(map[string]bool)}
+}
+
+// first tells whether a list instance is used for the first time, which is
+// when it restarts any levels it overrides
+func (n *listNumbering) first(instance string) bool {
+	if n.instances[instance] {
+		return false
+	}
+	n.instances[instance] = true
+	return true
+}
+
+func (n *listNumbering) counts(key string) *listCounts {
+	counts := n.lists[key]
+	if counts == nil {
+		counts = &listCounts{}
+		n.lists[key] = counts
+	}
+	return counts
+}
+
+// restart makes the next item at a level of a list take the given number
+func (n *listNumbering) restart(key string, level, start int) {
+	if level < 0 || level >= 9 {
+		return
+	}
+	counts := n.counts(key)
+	counts.count[level], counts.used[level] = start-1, true
+}
+
+// next numbers an item at a level of a list and returns it
+func (n *listNumbering) next(key string, def *listDefinition, level int) *ListItem {
+	if level < 0 {
+		level = 0
+	}
+	if level >= 9 {
+		level = 8
+	}
+	counts := n.counts(key)
+	lvl := def.levels[level]
+	if counts.used[level] {
+		counts.count[level]++
+	} else {
+		counts.count[level], counts.used[level] = lvl.start, true
+	}
+	for deeper := level + 1; deeper < 9; deeper++ {
+		if restart := def.levels[deeper].restart; restart < 0 || level < restart {
+			counts.used[deeper] = false
+		}
+	}
+
+	item := &ListItem{Level: level + 1, Number: counts.count[level], Bullet: lvl.format == formatBullet}
+	if item.Bullet {
+		item.Label = bulletLabel(lvl.text)
+		return item
+	}
+	item.Label = listLabel(lvl.text, func(placeholder int) string {
+		value, format := def.levels[placeholder].start, def.levels[placeholder].format
+		if counts.used[placeholder] {
+			value = counts.count[placeholder]
+		}
+		if lvl.legal {
+			format = formatDecimal
+		}
+		return formatListNumber(value, format)
+	})
+	return item
+}
+
+// listLabel fills in the %1 to %9 placeholders of a label template
+func listLabel(text string, number func(level int) string) string {
+	var label strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '%' && i+1 < len(runes) && runes[i+1] >= '1' && runes[i+1] <= '9' {
+			label.WriteString(number(int(runes[i+1] - '1')))
+			i++
+			continue
+		}
+		label.WriteRune(runes[i])
+	}
+	return label.String()
+}
+
+// bulletLabel returns the bullet of a level. Bullets drawn from symbol fonts
+// are stored in the private use area, so they are shown as a plain bullet.
+func bulletLabel(text string) string {
+	if text == "" {
+		return "•"
+	}
+	var label strings.Builder
+	for _, r := range text {
+		if r >= 0xF000 && r <= 0xF0FF {
+			r = '•'
+		}
+		label.WriteRune(r)
+	}
+	return label.String()
+}
+
+// formatListNumber writes a list number in the given format
+func formatListNumber(n int, format string) string {
+	switch format {
+	case formatNone:
+		return ""
+	case formatDecimalZero:
+		if n >= 0 && n < 10 {
+			return "0" + strconv.Itoa(n)
+		}
+	case formatUpperRoman:
+		return strings.ToUpper(romanNumber(n))
+	case formatLowerRoman:
+		return romanNumber(n)
+	case formatUpperLetter:
+		return strings.ToUpper(letterNumber(n))
+	case formatLowerLetter:
+		return letterNumber(n)
+	case formatOrdinal:
+		return strconv.Itoa(n) + ordinalSuffix(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// romanNumber writes a number in lower case roman numerals
+func romanNumber(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var result strings.Builder
+	for i, value := range values {
+		for n >= value {
+			result.WriteString(numerals[i])
+			n -= value
+		}
+	}
+	return result.String()
+}
+
+// letterNumber writes a number as Word letters it: a to z, then aa to zz and
+// so on
+func letterNumber(n int) string {
+	if n <= 0 {
+		return strconv.Itoa(n)
+	}
+	letter := string(rune('a' + (n-1)%26))
+	return strings.Repeat(letter, (n-1)/26+1)
+}
+
+func ordinalSuffix(n int) string {
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+		return "th"
+	case n%10 == 1:
+		return "st"
+	case n%10 == 2:
+		return "nd"
+	case n%10 == 3:
+		return "rd"
+	}
+	return "th"
+}
@@ -0,0 +1,49 @@
+package word_extractor
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata holds the document properties stored alongside the text: the core
+// properties (title, author...), the statistics saved by Word, and any custom
+// properties
+type Metadata struct {
+	Title          string
+	Subject        string
+	Author         string
+	Keywords       string
+	Comments       string
+	Category       string
+	LastModifiedBy string
+	Revision       string
+	Created        time.Time
+	Modified       time.Time
+
+	Pages       int
+	Words       int
+	Characters  int
+	Company     string
+	Template    string
+	Application string
+
+	// Custom holds the custom properties by name, with their values as text.
+	// Dates are written in RFC 3339 format.
+	Custom map[string]string
+}
+
+// setCustom records a custom property
+func (m *Metadata) setCustom(name, value string) {
+	if m.Custom == nil {
+		m.Custom = make(map[string]string)
+	}
+	m.Custom[name] = value
+}
+
+// parseCount reads a document statistic, which is zero when missing or
+// invalid
+func parseCount(value string) int {
+	count, _ := strconv.Atoi(strings.TrimSpace(value))
+	return count
+}
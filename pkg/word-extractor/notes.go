@@ -0,0 +1,25 @@
+package word_extractor
+
+import (
+	"strconv"
+)
+
+// Note is a footnote or endnote
+type Note struct {
+	ID string
+	// Text is the content of the note, without the reference mark that
+	// starts it
+	Text string
+	// Offset is the character (rune) offset within Body of the note's
+	// reference mark, where its marker is written when NoteMarkers is set
+	Offset int
+}
+
+// noteMarker returns the marker written into the body for the given note
+// reference, counting the footnotes and the endnotes separately from one
+func noteMarker(endnote bool, number int) string {
+	if endnote {
+		return "[^e" + strconv.Itoa(number) + "]"
+	}
+	return "[^" + strconv.Itoa(number) + "]"
+}
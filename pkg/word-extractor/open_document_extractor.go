@@ -0,0 +1,1321 @@
+package word_extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenDocumentExtractor extracts OpenDocument text files (.odt), as written
+// by LibreOffice and OpenOffice. The text is read from content.xml, the
+// headers and footers from the master pages of styles.xml, and the document
+// properties from meta.xml.
+type OpenDocumentExtractor struct {
+	// Options configures how documents are extracted
+	Options ExtractOptions
+
+	document   *Document
+	files      map[string]*zip.File
+	limits     *limiter
+	mediaTypes map[string]string
+	states     []*odfState
+
+	styles     map[string]*odfStyle
+	listStyles map[string]*listDefinition
+	listKeys   map[string]string
+	lists      int
+	numbering  *listNumbering
+
+	body            *odfStory
+	footnotes       *odfStory
+	endnotes        *odfStory
+	annotations     *odfStory
+	textboxes       []string
+	headerTextboxes []string
+
+	comments    []*odfComment
+	commentEnds map[string]int
+
+	changes     map[string]*odfChange
+	openChanges []*odfRevision
+	hidden      int
+
+	fieldCount int
+	fieldDepth int
+}
+
+// odfState is the state of an element. Each element starts with the state
+// of the element it is in, which is restored when the element closes.
+type odfState struct {
+	// story receives the text of paragraphs, and is nil where text is not
+	// part of the document. para is set inside a paragraph.
+	story *odfStory
+	para  bool
+	// main is set in the body and its textboxes, and header in headers and
+	// footers
+	main, header bool
+
+	// value collects the text of an element that holds a property rather
+	// than document text
+	value *strings.Builder
+
+	automatic bool
+	style     *odfStyle
+	listStyle *listDefinition
+	list      *odfList
+	item      *odfListItem
+	table     *odfTable
+	frame     *odfFrame
+	note      *odfNote
+	comment   *odfComment
+	change    *odfChange
+
+	// close is called when the element closes
+	close func(s *odfState)
+}
+
+// odfStory is a story being written. space is set after white space, which
+// runs into a single space, and at the start of a paragraph, where white
+// space is dropped. join is set when the paragraph mark was hidden by the
+// revision mode, so that the next paragraph runs on.
+type odfStory struct {
+	*storyBuilder
+	space bool
+	join  bool
+}
+
+// odfStyle is a paragraph style, with its display name, the style it
+// inherits from and its outline level, which is zero when it sets none.
+// Automatic styles are the formatting of single paragraphs, named by their
+// parent style.
+type odfStyle struct {
+	name      string
+	parent    string
+	level     int
+	automatic bool
+}
+
+// odfList is a list and its nesting level, from 0. Lists are numbered by key,
+// which a list shares with the lists it continues.
+type odfList struct {
+	key   string
+	style string
+	level int
+}
+
+// odfListItem is an item of a list, whose first paragraph is numbered
+type odfListItem struct {
+	list     *odfList
+	numbered bool
+}
+
+// odfTable tracks the columns of the current row of a table. skip counts
+// the covered cells still to come of a cell spanning several columns, and
+// covered holds the cells spanning rows into the next rows by column.
+type odfTable struct {
+	column  int
+	skip    int
+	covered map[int]*odfSpan
+}
+
+// odfSpan is a cell spanning several rows
+type odfSpan struct {
+	rows    int
+	columns int
+}
+
+// odfFrame is a frame holding pictures or an object. The pictures of a frame
+// that holds an object are only its preview.
+type odfFrame struct {
+	offset int
+	keep   bool
+	images []string
+	object bool
+	title  string
+	desc   string
+}
+
+// odfNote is a footnote or endnote, which is listed when it is in the body
+type odfNote struct {
+	note    *Note
+	endnote bool
+}
+
+// odfComment is an annotation, with the offset of its reference in the body
+// or -1 when it is not in the body
+type odfComment struct {
+	comment *Comment
+	name    string
+	offset  int
+}
+
+// odfChange is a tracked change from the changed regions of the document.
+// The text of a deletion is kept with the change, and is written where the
+// change is marked in the text.
+type odfChange struct {
+	typ     RevisionType
+	known   bool
+	author  string
+	date    time.Time
+	deleted strings.Builder
+}
+
+// odfRevision is a tracked insertion whose text is being read
+type odfRevision struct {
+	revision Revision
+	text     strings.Builder
+	shown    bool
+	record   bool
+}
+
+// Namespaces of OpenDocument files, by the prefix used to match elements
+var odfNamespaces = map[string]string{
+	"urn:oasis:names:tc:opendocument:xmlns:office:1.0":                     "office",
+	"urn:oasis:names:tc:opendocument:xmlns:text:1.0":                       "text",
+	"urn:oasis:names:tc:opendocument:xmlns:style:1.0":                      "style",
+	"urn:oasis:names:tc:opendocument:xmlns:table:1.0":                      "table",
+	"urn:oasis:names:tc:opendocument:xmlns:drawing:1.0":                    "draw",
+	"urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0":             "svg",
+	"urn:oasis:names:tc:opendocument:xmlns:meta:1.0":                       "meta",
+	"urn:oasis:names:tc:opendocument:xmlns:manifest:1.0":                   "manifest",
+	"http://purl.org/dc/elements/1.1/":                                     "dc",
+	"urn:org:documentfoundation:names:experimental:office:xmlns:loext:1.0": "loext",
+}
+
+// odfName returns the name of an element with its usual prefix, such as
+// "text:p"
+func odfName(name xml.Name) string {
+	return odfNamespaces[name.Space] + ":" + name.Local
+}
+
+// openDocumentTextType starts the media types of OpenDocument text files,
+// templates and master documents
+const openDocumentTextType = "application/vnd.oasis.opendocument.text"
+
+// openDocumentTemplateType is the media type of OpenDocument text templates
+const openDocumentTemplateType = "application/vnd.oasis.opendocument.text-template"
+
+// odfFieldTypes gives the Word field type of each OpenDocument text field
+var odfFieldTypes = map[string]string{
+	"text:page-number":      "PAGE",
+	"text:page-count":       "NUMPAGES",
+	"text:word-count":       "NUMWORDS",
+	"text:character-count":  "NUMCHARS",
+	"text:date":             "DATE",
+	"text:time":             "TIME",
+	"text:title":            "TITLE",
+	"text:subject":          "SUBJECT",
+	"text:keywords":         "KEYWORDS",
+	"text:initial-creator":  "AUTHOR",
+	"text:file-name":        "FILENAME",
+	"text:bookmark-ref":     "REF",
+	"text:sequence":         "SEQ",
+	"text:database-display": "MERGEFIELD",
+}
+
+// odfShapes lists the drawing shapes whose paragraphs are read as textboxes
+var odfShapes = map[string]bool{
+	"draw:text-box":     true,
+	"draw:custom-shape": true,
+	"draw:rect":         true,
+	"draw:ellipse":      true,
+	"draw:circle":       true,
+	"draw:polygon":      true,
+	"draw:caption":      true,
+}
+
+// odfNumFormats gives the number format of each OpenDocument list numbering
+var odfNumFormats = map[string]string{
+	"":  formatNone,
+	"1": formatDecimal,
+	"a": formatLowerLetter,
+	"A": formatUpperLetter,
+	"i": formatLowerRoman,
+	"I": formatUpperRoman,
+}
+
+// NewOpenDocumentExtractor creates a new instance of OpenDocumentExtractor
+func NewOpenDocumentExtractor() *OpenDocumentExtractor {
+	return &OpenDocumentExtractor{}
+}
+
+// Extract implements the DocumentExtractor interface
+func (e *OpenDocumentExtractor) Extract(reader io.ReadSeeker) (*Document, error) {
+	return e.ExtractContext(context.Background(), reader)
+}
+
+// ExtractContext extracts a document like Extract, and stops with the error
+// of the context once it is done
+func (e *OpenDocumentExtractor) ExtractContext(ctx context.Context, reader io.ReadSeeker) (*Document, error) {
+	return e.extractLimited(newLimiter(ctx, e.Options.Limits), reader)
+}
+
+// extractLimited extracts a document within the limiter of the extraction it
+// is part of
+func (e *OpenDocumentExtractor) extractLimited(limits *limiter, reader io.ReadSeeker) (*Document, error) {
+	e.limits = limits
+	if err := e.limits.checkInput(reader); err != nil {
+		return nil, err
+	}
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	readerAt, ok := reader.(io.ReaderAt)
+	if !ok {
+		readerAt = NewUnbufferedReaderAt(reader)
+	}
+	zr, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		return nil, partError("", -1, err)
+	}
+	e.files = make(map[string]*zip.File)
+	for _, f := range zr.File {
+		e.files[f.Name] = f
+	}
+	if e.files["content.xml"] == nil {
+		return nil, &ExtractError{Part: "content.xml", Offset: -1, Err: errMissingPart}
+	}
+
+	e.document = NewDocument()
+	e.mediaTypes = make(map[string]string)
+	e.styles, e.listStyles, e.listKeys = make(map[string]*odfStyle), make(map[string]*listDefinition), make(map[string]string)
+	e.lists, e.numbering = 0, newListNumbering()
+	e.body, e.footnotes, e.endnotes, e.annotations = newOdfStory(e.limits), newOdfStory(e.limits), newOdfStory(e.limits), newOdfStory(e.limits)
+	e.textboxes, e.headerTextboxes = nil, nil
+	e.comments, e.commentEnds = nil, make(map[string]int)
+	e.changes, e.openChanges, e.hidden = make(map[string]*odfChange), nil, 0
+	e.fieldCount, e.fieldDepth = 0, 0
+
+	e.document.Format = FormatOdt
+	if data, err := e.readPart("mimetype"); err == nil && strings.TrimSpace(string(data)) == openDocumentTemplateType {
+		e.document.Format = FormatOtt
+	}
+	if data, err := e.readPart("META-INF/manifest.xml"); err == nil {
+		e.readManifest(data)
+	}
+	if data, err := e.readPart("meta.xml"); err == nil {
+		if err := e.readMeta(data); err != nil {
+			return nil, partError("meta.xml", -1, err)
+		}
+	}
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		data, err := e.readPart(name)
+		if err != nil {
+			if name == "styles.xml" {
+				continue
+			}
+			return nil, err
+		}
+		if err := e.readContent(data); err != nil {
+			return nil, partError(name, -1, err)
+		}
+	}
+	e.finish()
+	if err := e.limits.checkOutput(e.document); err != nil {
+		return nil, err
+	}
+	return e.document, nil
+}
+
+func newOdfStory(limits *limiter) *odfStory {
+	return &odfStory{storyBuilder: newStoryBuilder(limits), space: true}
+}
+
+// readPart reads the contents of a file of the package, within the limits
+func (e *OpenDocumentExtractor) readPart(name string) ([]byte, error) {
+	f := e.files[name]
+	if f == nil {
+		return nil, &ExtractError{Part: name, Offset: -1, Err: errMissingPart}
+	}
+	rc, err := e.limits.openPart(name, f)
+	if err != nil {
+		return nil, partError(name, -1, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, partError(name, -1, err)
+}
+
+// openDocumentMediaType returns the media type of an OpenDocument package,
+// which is held in its mimetype file, or an empty string for other ZIP files
+func openDocumentMediaType(reader io.ReadSeeker) string {
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ""
+	}
+	defer reader.Seek(0, io.SeekStart)
+	readerAt, ok := reader.(io.ReaderAt)
+	if !ok {
+		readerAt = NewUnbufferedReaderAt(reader)
+	}
+	zr, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		return ""
+	}
+	for _, f := range zr.File {
+		if f.Name != "mimetype" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return ""
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, 256))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
+
+// readManifest reads the media types of the files of the package
+func (e *OpenDocumentExtractor) readManifest(data []byte) {
+	decoder, _ := e.limits.newDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if t, ok := token.(xml.StartElement); ok && odfName(t.Name) == "manifest:file-entry" {
+			e.mediaTypes[attrValue(t, "full-path")] = attrValue(t, "media-type")
+		}
+	}
+}
+
+// readMeta reads the document properties. Each property is an element of
+// its own, whose text is taken as it closes.
+func (e *OpenDocumentExtractor) readMeta(data []byte) error {
+	meta := &e.document.Metadata
+	decoder, _ := e.limits.newDecoder(bytes.NewReader(data))
+	var text strings.Builder
+	var property string
+	var keywords []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			text.Reset()
+			switch odfName(t.Name) {
+			case "meta:user-defined":
+				property = attrValue(t, "name")
+			case "meta:template":
+				if href := attrValue(t, "href"); href != "" {
+					meta.Template = path.Base(href)
+				}
+			case "meta:document-statistic":
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "page-count":
+						meta.Pages = parseCount(attr.Value)
+					case "word-count":
+						meta.Words = parseCount(attr.Value)
+					case "character-count":
+						meta.Characters = parseCount(attr.Value)
+					}
+				}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			text.Reset()
+			switch odfName(t.Name) {
+			case "dc:title":
+				meta.Title = value
+			case "dc:subject":
+				meta.Subject = value
+			case "dc:description":
+				meta.Comments = value
+			case "meta:keyword":
+				keywords = append(keywords, value)
+			case "meta:initial-creator":
+				meta.Author = value
+			case "dc:creator":
+				meta.LastModifiedBy = value
+			case "meta:creation-date":
+				meta.Created = parseOdfDate(value)
+			case "dc:date":
+				meta.Modified = parseOdfDate(value)
+			case "meta:editing-cycles":
+				meta.Revision = value
+			case "meta:generator":
+				meta.Application = value
+			case "meta:user-defined":
+				if property != "" {
+					meta.setCustom(property, value)
+				}
+				property = ""
+			}
+		}
+	}
+	meta.Keywords = strings.Join(keywords, ", ")
+	return nil
+}
+
+// parseOdfDate reads a date of the document, which is written without a
+// time zone when it is in local time. Such dates are read as UTC.
+func parseOdfDate(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// readContent reads the styles, master pages and body of content.xml or
+// styles.xml
+func (e *OpenDocumentExtractor) readContent(data []byte) error {
+	e.states = []*odfState{{}}
+	decoder, _ := e.limits.newDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			child := *e.state()
+			child.close = nil
+			e.states = append(e.states, &child)
+			e.handleOpenTag(&child, t)
+		case xml.EndElement:
+			if len(e.states) <= 1 {
+				continue
+			}
+			state := e.state()
+			e.states = e.states[:len(e.states)-1]
+			if state.close != nil {
+				state.close(state)
+			}
+		case xml.CharData:
+			e.handleCharData(e.state(), string(t))
+		}
+	}
+}
+
+func (e *OpenDocumentExtractor) state() *odfState {
+	return e.states[len(e.states)-1]
+}
+
+func (e *OpenDocumentExtractor) handleOpenTag(state *odfState, se xml.StartElement) {
+	name := odfName(se.Name)
+	if odfShapes[name] {
+		e.startTextbox(state)
+		return
+	}
+	if fieldType, ok := odfFieldTypes[name]; ok {
+		e.startField(state, fieldType, se)
+		return
+	}
+	if strings.HasPrefix(name, "text:") && strings.HasSuffix(name, "-source") {
+		// The templates of an index are not part of its text
+		state.story = nil
+		return
+	}
+
+	switch name {
+	case "office:automatic-styles":
+		state.automatic = true
+
+	case "style:style":
+		if attrValue(se, "family") != "paragraph" {
+			return
+		}
+		style := &odfStyle{name: attrValue(se, "display-name"), parent: attrValue(se, "parent-style-name"), automatic: state.automatic}
+		if style.name == "" {
+			style.name = odfStyleName(attrValue(se, "name"))
+		}
+		style.level, _ = strconv.Atoi(attrValue(se, "default-outline-level"))
+		e.styles[attrValue(se, "name")] = style
+		state.style = style
+
+	case "text:list-style":
+		state.listStyle = newListDefinition()
+		e.listStyles[attrValue(se, "name")] = state.listStyle
+
+	case "text:outline-style":
+		state.listStyle = nil
+
+	case "text:list-level-style-number", "text:list-level-style-bullet", "text:list-level-style-image":
+		e.readListLevel(state, se)
+
+	case "style:header", "style:header-left", "style:header-first", "loext:header-first",
+		"style:footer", "style:footer-left", "style:footer-first", "loext:footer-first":
+		if attrValue(se, "display") == "false" {
+			state.story = nil
+			return
+		}
+		story := newOdfStory(e.limits)
+		state.story, state.para, state.main, state.header = story, false, false, true
+		footer := strings.Contains(name, "footer")
+		state.close = func(*odfState) {
+			story.finish()
+			if footer {
+				e.document.Footers += story.String()
+			} else {
+				e.document.Headers += story.String()
+			}
+		}
+
+	case "office:text":
+		state.story, state.para, state.main = e.body, false, true
+
+	case "text:tracked-changes":
+		state.story = nil
+
+	case "text:changed-region":
+		state.change = &odfChange{}
+		e.changes[attrValue(se, "id")] = state.change
+
+	case "text:insertion", "text:deletion":
+		if state.change != nil {
+			state.change.typ, state.change.known = Insertion, true
+			if name == "text:deletion" {
+				state.change.typ = Deletion
+			}
+		}
+
+	case "text:change-start":
+		e.startChange(state, attrValue(se, "change-id"))
+
+	case "text:change-end":
+		e.endChange(state)
+
+	case "text:change":
+		e.deletion(state, attrValue(se, "change-id"))
+
+	case "text:p", "text:h":
+		e.startParagraph(state, se)
+
+	case "text:s":
+		count := 1
+		if c, err := strconv.Atoi(attrValue(se, "c")); err == nil && c > 0 {
+			count = c
+		}
+		e.text(state, strings.Repeat(" ", count))
+
+	case "text:tab":
+		e.text(state, "\t")
+
+	case "text:line-break":
+		e.text(state, "\n")
+
+	case "text:number", "text:note-citation":
+		// Numbers and note marks are shown as they were last laid out
+		state.story = nil
+
+	case "text:list":
+		e.startList(state, se)
+
+	case "text:note":
+		e.startNote(state, se)
+
+	case "text:note-body":
+		e.startNoteBody(state)
+
+	case "office:annotation":
+		e.startAnnotation(state, se)
+
+	case "office:annotation-end":
+		if state.story == e.body {
+			e.commentEnds[attrValue(se, "name")] = e.body.offset()
+		}
+
+	case "dc:creator", "dc:date", "meta:creator-initials", "loext:sender-initials":
+		e.readProperty(state, name)
+
+	case "table:table":
+		if state.story != nil {
+			state.story.startTable()
+			state.table = &odfTable{covered: make(map[int]*odfSpan)}
+			story := state.story
+			state.close = func(*odfState) {
+				story.endTable()
+			}
+		}
+
+	case "table:table-row":
+		if state.story != nil && state.table != nil {
+			state.table.column, state.table.skip = 0, 0
+			state.story.startRow()
+			story := state.story
+			state.close = func(*odfState) {
+				story.endRow()
+			}
+		}
+
+	case "table:table-cell", "table:covered-table-cell":
+		e.startCell(state, se, name == "table:covered-table-cell")
+
+	case "draw:frame":
+		state.frame = &odfFrame{offset: e.body.offset(), keep: state.main && !state.header}
+		state.close = func(s *odfState) {
+			e.addImages(s.frame)
+		}
+
+	case "draw:image":
+		if state.frame != nil {
+			state.frame.images = append(state.frame.images, attrValue(se, "href"))
+		}
+
+	case "draw:object", "draw:object-ole":
+		if state.frame != nil {
+			state.frame.object = true
+		}
+		if name == "draw:object-ole" {
+			e.addObject(attrValue(se, "href"))
+		}
+
+	case "svg:title", "svg:desc":
+		if frame := state.frame; frame != nil {
+			state.story, state.value = nil, &strings.Builder{}
+			state.close = func(s *odfState) {
+				if name == "svg:title" {
+					frame.title = strings.TrimSpace(s.value.String())
+				} else {
+					frame.desc = strings.TrimSpace(s.value.String())
+				}
+			}
+		}
+
+	case "text:a":
+		e.startLink(state, se)
+
+	case "text:list-item", "text:list-header":
+		state.item = nil
+		if name == "text:list-item" && state.list != nil {
+			state.item = &odfListItem{list: state.list}
+			if start, err := strconv.Atoi(attrValue(se, "start-value")); err == nil {
+				e.numbering.restart(state.list.key, state.list.level, start)
+			}
+		}
+	}
+}
+
+func (e *OpenDocumentExtractor) handleCharData(state *odfState, text string) {
+	if state.value != nil {
+		state.value.WriteString(text)
+		return
+	}
+	if !state.para || state.story == nil {
+		return
+	}
+
+	// White space runs into a single space
+	var collapsed strings.Builder
+	story := state.story
+	for _, r := range text {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			if !story.space {
+				collapsed.WriteRune(' ')
+				story.space = true
+			}
+			continue
+		}
+		collapsed.WriteRune(r)
+		story.space = false
+	}
+	e.write(state, collapsed.String())
+}
+
+// text writes text given by an element, such as a tab or spaces
+func (e *OpenDocumentExtractor) text(state *odfState, text string) {
+	if state.value != nil {
+		state.value.WriteString(text)
+		return
+	}
+	if !state.para || state.story == nil {
+		return
+	}
+	state.story.space = false
+	e.write(state, text)
+}
+
+// write writes text to the story of an element, unless it is inside a
+// tracked change that is hidden by the revision mode. The text is also
+// collected by any open tracked changes.
+func (e *OpenDocumentExtractor) write(state *odfState, text string) {
+	if text == "" {
+		return
+	}
+	for _, rev := range e.openChanges {
+		rev.text.WriteString(text)
+	}
+	if e.hidden > 0 {
+		return
+	}
+	state.story.write(text)
+}
+
+// readProperty collects the author, date or initials of a comment or tracked
+// change
+func (e *OpenDocumentExtractor) readProperty(state *odfState, name string) {
+	comment, change := state.comment, state.change
+	if comment == nil && change == nil {
+		return
+	}
+	state.story, state.value = nil, &strings.Builder{}
+	state.close = func(s *odfState) {
+		value := strings.TrimSpace(s.value.String())
+		if comment != nil {
+			switch name {
+			case "dc:creator":
+				comment.comment.Author = value
+			case "dc:date":
+				comment.comment.Date = parseOdfDate(value)
+			default:
+				comment.comment.Initials = value
+			}
+			return
+		}
+		switch name {
+		case "dc:creator":
+			change.author = value
+		case "dc:date":
+			change.date = parseOdfDate(value)
+		}
+	}
+}
+
+// readListLevel reads the numbering of a level of a list style
+func (e *OpenDocumentExtractor) readListLevel(state *odfState, se xml.StartElement) {
+	def := state.listStyle
+	level, err := strconv.Atoi(attrValue(se, "level"))
+	if def == nil || err != nil || level < 1 || level > len(def.levels) {
+		return
+	}
+	lvl := &def.levels[level-1]
+	if se.Name.Local != "list-level-style-number" {
+		lvl.format, lvl.text = formatBullet, attrValue(se, "bullet-char")
+		return
+	}
+
+	format, ok := odfNumFormats[attrValue(se, "num-format")]
+	if !ok {
+		format = formatDecimal
+	}
+	lvl.format = format
+	if start, err := strconv.Atoi(attrValue(se, "start-value")); err == nil {
+		lvl.start = start
+	}
+	var numbers []string
+	if format != formatNone {
+		shown := 1
+		if display, err := strconv.Atoi(attrValue(se, "display-levels")); err == nil && display > 0 {
+			shown = display
+		}
+		for l := level - shown + 1; l <= level; l++ {
+			if l >= 1 {
+				numbers = append(numbers, "%"+strconv.Itoa(l))
+			}
+		}
+	}
+	lvl.text = attrValue(se, "num-prefix") + strings.Join(numbers, ".") + attrValue(se, "num-suffix")
+}
+
+// odfStyleName decodes a style name in which characters that cannot be part
+// of a name are written by their code, such as "Heading_20_1"
+func odfStyleName(name string) string {
+	var decoded strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '_' {
+			if end := strings.IndexByte(name[i+1:], '_'); end > 0 {
+				if code, err := strconv.ParseUint(name[i+1:i+1+end], 16, 32); err == nil {
+					decoded.WriteRune(rune(code))
+					i += end + 1
+					continue
+				}
+			}
+		}
+		decoded.WriteByte(name[i])
+	}
+	return decoded.String()
+}
+
+// startList starts a list. A list that is not nested starts its numbering
+// again, unless it continues a list of the same style.
+func (e *OpenDocumentExtractor) startList(state *odfState, se xml.StartElement) {
+	style := attrValue(se, "style-name")
+	parent := state.list
+	if parent != nil {
+		if style == "" {
+			style = parent.style
+		}
+		state.list = &odfList{key: parent.key, style: style, level: parent.level + 1}
+		return
+	}
+	key, ok := e.listKeys[style]
+	if !ok || (attrValue(se, "continue-numbering") != "true" && attrValue(se, "continue-list") == "") {
+		e.lists++
+		key = "list:" + strconv.Itoa(e.lists)
+		e.listKeys[style] = key
+	}
+	state.list = &odfList{key: key, style: style}
+}
+
+// startParagraph starts a paragraph or heading. Paragraphs of the body are
+// given their style, outline level and list numbering as they close.
+func (e *OpenDocumentExtractor) startParagraph(state *odfState, se xml.StartElement) {
+	if change := state.change; change != nil {
+		// The text of a deletion is kept with its change
+		if change.deleted.Len() > 0 {
+			change.deleted.WriteString("\n")
+		}
+		state.story, state.value = nil, &change.deleted
+		return
+	}
+	story := state.story
+	if story == nil {
+		return
+	}
+	state.para = true
+	if story.join {
+		story.join = false
+	} else {
+		story.startParagraph()
+	}
+	story.space = true
+	styleName := attrValue(se, "style-name")
+	level := 0
+	if se.Name.Local == "h" {
+		level, _ = strconv.Atoi(attrValue(se, "outline-level"))
+	}
+	item := state.item
+	state.close = func(s *odfState) {
+		if e.hidden > 0 && story == e.body {
+			story.join = true
+			return
+		}
+		p := story.endParagraph()
+		story.space = true
+		if story != e.body {
+			return
+		}
+		e.setParagraphStyle(p, styleName, level)
+		if item != nil && !item.numbered {
+			item.numbered = true
+			def := e.listStyles[item.list.style]
+			if def == nil {
+				def = newListDefinition()
+			}
+			p.List = e.numbering.next(item.list.key, def, item.list.level)
+		}
+	}
+}
+
+// setParagraphStyle sets the style and outline level of a paragraph of the
+// body. The style is named by the first style that is not automatic, and an
+// outline level set on a heading wins over the one of its style.
+func (e *OpenDocumentExtractor) setParagraphStyle(p *Paragraph, styleName string, level int) {
+	var styles []*odfStyle
+	for style := e.styles[styleName]; style != nil && len(styles) <= len(e.styles); style = e.styles[style.parent] {
+		styles = append(styles, style)
+	}
+	for _, style := range styles {
+		if !style.automatic {
+			p.Style = style.name
+			break
+		}
+	}
+	p.Level = headingStyleLevel(p.Style)
+	if level > 0 {
+		p.Level = outlineLevel(level - 1)
+		return
+	}
+	for _, style := range styles {
+		if style.level > 0 {
+			p.Level = outlineLevel(style.level - 1)
+			break
+		}
+	}
+}
+
+// startCell starts a table cell. Cells covered by a cell spanning columns
+// are folded into it, and cells covered by a cell spanning rows continue
+// its vertical merge.
+func (e *OpenDocumentExtractor) startCell(state *odfState, se xml.StartElement, covered bool) {
+	table, story := state.table, state.story
+	if table == nil || story == nil {
+		return
+	}
+	column := table.column
+	table.column++
+	if covered {
+		if table.skip > 0 {
+			table.skip--
+			state.story = nil
+			return
+		}
+		span := table.covered[column]
+		if span == nil {
+			state.story = nil
+			return
+		}
+		story.startCell()
+		story.setGridSpan(span.columns)
+		story.setVMerge(MergeContinue)
+		table.skip = span.columns - 1
+		if span.rows--; span.rows <= 0 {
+			delete(table.covered, column)
+		}
+		state.story = nil
+		state.close = func(*odfState) {
+			story.endCell()
+		}
+		return
+	}
+
+	story.startCell()
+	columns, _ := strconv.Atoi(attrValue(se, "number-columns-spanned"))
+	if columns > 1 {
+		story.setGridSpan(columns)
+		table.skip = columns - 1
+	} else {
+		columns = 1
+	}
+	if rows, _ := strconv.Atoi(attrValue(se, "number-rows-spanned")); rows > 1 {
+		story.setVMerge(MergeRestart)
+		table.covered[column] = &odfSpan{rows: rows - 1, columns: columns}
+	}
+	state.close = func(*odfState) {
+		story.endCell()
+	}
+}
+
+// startTextbox starts the text of a textbox or shape, which is a story of
+// its own
+func (e *OpenDocumentExtractor) startTextbox(state *odfState) {
+	if state.story == nil && !state.header && !state.main {
+		return
+	}
+	story := newOdfStory(e.limits)
+	state.story, state.para = story, false
+	state.list, state.item, state.table = nil, nil, nil
+	state.close = func(s *odfState) {
+		story.finish()
+		text := story.String()
+		if text == "" {
+			return
+		}
+		if s.header {
+			e.headerTextboxes = append(e.headerTextboxes, text)
+		} else {
+			e.textboxes = append(e.textboxes, text)
+		}
+	}
+}
+
+// startNote starts a footnote or endnote, whose reference is where the note
+// is in the body. Only the body of the note is text.
+func (e *OpenDocumentExtractor) startNote(state *odfState, se xml.StartElement) {
+	note := &odfNote{
+		note:    &Note{ID: attrValue(se, "id"), Offset: -1},
+		endnote: attrValue(se, "note-class") == "endnote",
+	}
+	if state.story == e.body && state.para {
+		doc := e.document
+		note.note.Offset = e.body.offset()
+		if note.endnote {
+			doc.endnotes = append(doc.endnotes, note.note)
+		} else {
+			doc.footnotes = append(doc.footnotes, note.note)
+		}
+		if e.Options.NoteMarkers {
+			number := len(doc.footnotes)
+			if note.endnote {
+				number = len(doc.endnotes)
+			}
+			e.write(state, noteMarker(note.endnote, number))
+		}
+	}
+	state.note = note
+	state.story, state.para = nil, false
+}
+
+// startNoteBody starts the text of a note, which is written to the
+// footnotes or the endnotes
+func (e *OpenDocumentExtractor) startNoteBody(state *odfState) {
+	note := state.note
+	if note == nil {
+		return
+	}
+	story := e.footnotes
+	if note.endnote {
+		story = e.endnotes
+	}
+	start := story.offset()
+	state.story, state.para, state.main = story, false, false
+	state.list, state.item, state.table = nil, nil, nil
+	state.close = func(*odfState) {
+		note.note.Text = strings.TrimSpace(string(story.text[start:]))
+	}
+}
+
+// startAnnotation starts a comment, anchored from where it is in the body to
+// the end of its range, if it has one
+func (e *OpenDocumentExtractor) startAnnotation(state *odfState, se xml.StartElement) {
+	comment := &odfComment{comment: &Comment{}, name: attrValue(se, "name"), offset: -1}
+	if state.story == e.body {
+		comment.offset = e.body.offset()
+	}
+	story := e.annotations
+	start := story.offset()
+	state.story, state.para, state.main = story, false, false
+	state.list, state.item, state.table = nil, nil, nil
+	state.comment = comment
+	state.close = func(*odfState) {
+		comment.comment.Text = strings.TrimSuffix(string(story.text[start:]), "\n")
+		if comment.offset >= 0 {
+			e.comments = append(e.comments, comment)
+		}
+	}
+}
+
+// startLink starts a hyperlink. Links of the body are also listed as
+// HYPERLINK fields, as Word writes them.
+func (e *OpenDocumentExtractor) startLink(state *odfState, se xml.StartElement) {
+	if state.story != e.body || !state.para {
+		return
+	}
+	link := &Hyperlink{Offset: e.body.offset()}
+	href := attrValue(se, "href")
+	if strings.HasPrefix(href, "#") {
+		link.Anchor = href[1:]
+	} else {
+		link.URL = href
+	}
+	field := &Field{Depth: e.fieldDepth, begin: e.fieldCount}
+	e.fieldCount++
+	e.fieldDepth++
+	state.close = func(*odfState) {
+		e.fieldDepth--
+		link.Length = e.body.offset() - link.Offset
+		parsed := parseField(link.instruction())
+		field.Type, field.Instruction, field.Arguments, field.Switches = parsed.Type, parsed.Instruction, parsed.Arguments, parsed.Switches
+		field.Offset, field.Length = link.Offset, link.Length
+		e.document.hyperlinks = append(e.document.hyperlinks, link)
+		e.document.fields = append(e.document.fields, field)
+	}
+}
+
+// startField starts a text field of the body, which is listed as the Word
+// field that shows the same value
+func (e *OpenDocumentExtractor) startField(state *odfState, fieldType string, se xml.StartElement) {
+	if state.story != e.body || !state.para {
+		return
+	}
+	instruction := fieldType
+	var argument string
+	switch fieldType {
+	case "REF":
+		argument = attrValue(se, "ref-name")
+	case "SEQ":
+		argument = attrValue(se, "name")
+	case "MERGEFIELD":
+		argument = attrValue(se, "column-name")
+	}
+	if strings.ContainsAny(argument, " \t") {
+		argument = `"` + argument + `"`
+	}
+	if argument != "" {
+		instruction += " " + argument
+	}
+	offset, depth, begin := e.body.offset(), e.fieldDepth, e.fieldCount
+	e.fieldCount++
+	e.fieldDepth++
+	state.close = func(*odfState) {
+		e.fieldDepth--
+		field := parseField(instruction)
+		field.Depth, field.begin = depth, begin
+		field.Offset, field.Length = offset, e.body.offset()-offset
+		e.document.fields = append(e.document.fields, field)
+	}
+}
+
+// startChange starts a tracked insertion, which runs up to its end mark
+func (e *OpenDocumentExtractor) startChange(state *odfState, id string) {
+	change := e.changes[id]
+	if change == nil || !change.known || change.typ != Insertion || state.story == nil {
+		return
+	}
+	rev := &odfRevision{
+		revision: Revision{Type: Insertion, Author: change.author, Date: change.date, Offset: state.story.offset()},
+		shown:    e.Options.Revisions.shows(Insertion),
+		record:   state.story == e.body,
+	}
+	if start, _ := e.Options.Revisions.markers(Insertion); rev.shown && e.hidden == 0 {
+		state.story.write(start)
+	}
+	if !rev.shown {
+		e.hidden++
+	}
+	e.openChanges = append(e.openChanges, rev)
+}
+
+// endChange ends the innermost tracked insertion
+func (e *OpenDocumentExtractor) endChange(state *odfState) {
+	n := len(e.openChanges)
+	if n == 0 {
+		return
+	}
+	rev := e.openChanges[n-1]
+	e.openChanges = e.openChanges[:n-1]
+	if !rev.shown {
+		e.hidden--
+	} else if _, end := e.Options.Revisions.markers(Insertion); e.hidden == 0 && state.story != nil {
+		state.story.write(end)
+	}
+	if rev.record && rev.text.Len() > 0 {
+		rev.revision.Text = rev.text.String()
+		e.document.revisions = append(e.document.revisions, rev.revision)
+	}
+}
+
+// deletion writes the text of a tracked deletion where it was deleted, when
+// the revision mode shows it
+func (e *OpenDocumentExtractor) deletion(state *odfState, id string) {
+	change := e.changes[id]
+	if change == nil || !change.known || change.typ != Deletion || state.story == nil {
+		return
+	}
+	story := state.story
+	text := change.deleted.String()
+	if story == e.body && text != "" {
+		e.document.revisions = append(e.document.revisions, Revision{
+			Type:   Deletion,
+			Author: change.author,
+			Date:   change.date,
+			Text:   text,
+			Offset: story.offset(),
+		})
+	}
+	if !e.Options.Revisions.shows(Deletion) || e.hidden > 0 || !state.para {
+		return
+	}
+	start, end := e.Options.Revisions.markers(Deletion)
+	story.write(start + text + end)
+	story.space = false
+}
+
+// addImages lists the pictures of a frame in the body, unless they are the
+// preview of an object
+func (e *OpenDocumentExtractor) addImages(frame *odfFrame) {
+	if !frame.keep || frame.object || e.hidden > 0 {
+		return
+	}
+	altText := frame.desc
+	if altText == "" {
+		altText = frame.title
+	}
+	for _, href := range frame.images {
+		name := strings.TrimPrefix(href, "./")
+		data, err := e.readPart(name)
+		if err != nil {
+			continue
+		}
+		contentType := e.mediaTypes[name]
+		if contentType == "" {
+			contentType = odfImageTypes[strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))]
+		}
+		e.document.images = append(e.document.images, &Image{
+			Name:        path.Base(name),
+			ContentType: contentType,
+			Data:        data,
+			AltText:     altText,
+			Offset:      frame.offset,
+		})
+	}
+}
+
+// odfImageTypes gives the content type of pictures by extension, for
+// packages whose manifest does not give it
+var odfImageTypes = map[string]string{
+	"png":  contentTypePNG,
+	"jpg":  contentTypeJPEG,
+	"jpeg": contentTypeJPEG,
+	"emf":  contentTypeEMF,
+	"wmf":  contentTypeWMF,
+	"bmp":  contentTypeBMP,
+	"tif":  contentTypeTIFF,
+	"tiff": contentTypeTIFF,
+	"gif":  "image/gif",
+	"svg":  "image/svg+xml",
+}
+
+// addObject lists an OLE object of the package. Objects are stored as
+// compound files, which are read for their ProgID and any file they
+// package.
+func (e *OpenDocumentExtractor) addObject(href string) {
+	name := strings.TrimPrefix(href, "./")
+	data, err := e.readPart(name)
+	if err != nil {
+		return
+	}
+	object := &EmbeddedObject{Name: path.Base(name), Data: data}
+	if bytes.HasPrefix(data, compoundSignature) {
+		if entries, err := readCompoundFile(e.limits, bytes.NewReader(data)); err == nil {
+			object, _ = compoundObject(object.Name, "", entries, data)
+		}
+	}
+	e.document.objects = append(e.document.objects, object)
+}
+
+// finish fills in the document from the stories that were read
+func (e *OpenDocumentExtractor) finish() {
+	e.body.finish()
+	doc := e.document
+	doc.Body = e.body.String()
+	doc.Blocks = e.body.Blocks()
+	doc.Footnotes = e.footnotes.String()
+	doc.Endnotes = e.endnotes.String()
+	doc.Annotations = e.annotations.String()
+	if len(e.textboxes) > 0 {
+		doc.Textboxes = strings.Join(e.textboxes, "\n") + "\n"
+	}
+	if len(e.headerTextboxes) > 0 {
+		doc.HeaderTextboxes = strings.Join(e.headerTextboxes, "\n") + "\n"
+	}
+
+	sort.SliceStable(doc.revisions, func(i, j int) bool {
+		return doc.revisions[i].Offset < doc.revisions[j].Offset
+	})
+
+	text := []rune(doc.Body)
+	for i, c := range e.comments {
+		c.comment.ID = strconv.Itoa(i)
+		end, ok := e.commentEnds[c.name]
+		if !ok || c.name == "" {
+			end = c.offset
+		}
+		c.comment.anchor(text, c.offset, end)
+		doc.comments = append(doc.comments, c.comment)
+	}
+	sortFields(doc.fields)
+	setFieldResults(doc.fields, text)
+	setHyperlinkText(doc.hyperlinks, text)
+}
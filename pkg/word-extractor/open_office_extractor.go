@@ -2,23 +2,186 @@ package word_extractor
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type OpenOfficeExtractor struct {
+	// Options configures how documents are extracted
+	Options ExtractOptions
+
 	document      *Document
 	streamTypes   map[string]bool
 	headerTypes   map[string]bool
 	actions       map[string]Action
 	defaults      map[string]string
+	overrides     map[string]string
 	relationships map[string]Relationship
 	context       []string
-	pieces        [][]rune   // Changed from []string to [][]rune
-	piecesStack   [][][]rune // Stack to hold pieces state for nested contexts like textboxes
+	story         *storyBuilder
+	storyStack    []*storyBuilder // Stack to hold story state for nested contexts like textboxes
+
+	inDocument      bool
+	openRevisions   []*openRevision
+	hiddenRevisions int
+	lastRevision    int
+	inParagraphPr   bool
+	hideParagraph   bool
+	joinParagraph   bool
+
+	comments       []*docxComment
+	comment        *docxComment
+	commentStarts  map[string]int
+	commentEnds    map[string]int
+	commentParents map[string]string
+
+	note       *docxNote
+	noteTexts  map[string]string
+	noteRefs   []docxNoteReference
+	noteCounts map[string]int
+
+	part              string
+	mainPart          string
+	partRelationships map[string]map[string]Relationship
+	fields            []*docxField
+	fieldCount        int
+	bodyFields        []*Field
+	inInstruction     bool
+	openLinks         []*docxLink
+	links             []*docxLink
+
+	styles       map[string]*docxStyle
+	defaultStyle string
+	paraStyle    string
+	paraLevel    int
+	paraNumID    string
+	paraIlvl     int
+	paragraphs   []docxParagraph
+
+	abstractNums map[string]*listDefinition
+	nums         map[string]*docxNum
+
+	files      map[string]packageFile
+	limits     *limiter
+	images     []docxImage
+	pictureAlt string
+	objects    int
+	fallbacks  int
+	objectRefs []docxObjectReference
+
+	// Word 2003 XML keeps notes, comments and headers inline, and pictures
+	// as base64 data in the body
+	wordXML         bool
+	inlineStories   []inlineStory
+	inlineParts     map[string]*storyBuilder
+	annotationTypes []string
+	binName         string
+	binText         strings.Builder
+	binData         map[string][]byte
+}
+
+// docxStyle is a paragraph style read from the styles part. level is the
+// outline level of the style, or -1 when it sets none, and numID and ilvl
+// its list numbering.
+type docxStyle struct {
+	name    string
+	basedOn string
+	level   int
+	numID   string
+	ilvl    int
+}
+
+// docxParagraph is a paragraph of the body with the style, outline level and
+// list numbering set on it, which are resolved once the styles and numbering
+// parts have been read. ilvl is -1 when the paragraph does not set it.
+type docxParagraph struct {
+	paragraph *Paragraph
+	style     string
+	level     int
+	numID     string
+	ilvl      int
+}
+
+// docxNum is a list instance from the numbering part, which numbers its items
+// with an abstract list definition. starts holds the levels it restarts.
+type docxNum struct {
+	abstractID string
+	starts     map[int]int
+}
+
+// docxField is a field that is being read, either a simple field or a
+// complex field made of w:fldChar marks and w:instrText
+type docxField struct {
+	instruction strings.Builder
+	separated   bool
+	start       int
+	depth       int
+	order       int
+}
+
+// docxLink is a hyperlink in the body, with the relationship that holds its
+// target. A w:hyperlink element is also listed as a HYPERLINK field, as Word
+// writes one when it saves a hyperlink field.
+type docxLink struct {
+	link  *Hyperlink
+	relID string
+	field *Field
+}
+
+// docxImage is a picture in the body, with the relationship that holds its
+// media part
+type docxImage struct {
+	relID   string
+	altText string
+	offset  int
+}
+
+// docxObjectReference is an embedded object in a part, with the ProgID of its
+// application and the relationship that holds its part
+type docxObjectReference struct {
+	part   string
+	relID  string
+	progID string
+}
+
+// docxNote is a footnote or endnote that is being read
+type docxNote struct {
+	key   string
+	start int
+}
+
+// docxNoteReference is a footnote or endnote reference in the body. Notes are
+// keyed by element name and id, such as "footnote:1".
+type docxNoteReference struct {
+	key    string
+	id     string
+	offset int
+}
+
+// docxComment is a comment read from the comments part. Replies are linked
+// through the paragraph id of the comment's last paragraph.
+type docxComment struct {
+	comment *Comment
+	start   int
+	paraID  string
+}
+
+// openRevision is a tracked change element (w:ins, w:del...) that is still open
+type openRevision struct {
+	revision Revision
+	text     strings.Builder
+	shown    bool
+	marked   bool
+	record   bool
 }
 
 type Action struct {
@@ -34,7 +197,6 @@ type Relationship struct {
 func NewOpenOfficeExtractor() *OpenOfficeExtractor {
 	e := &OpenOfficeExtractor{
 		streamTypes: map[string]bool{
-			"application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml":    true,
 			"application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml":         true,
 			"application/vnd.openxmlformats-officedocument.wordprocessingml.commentsExtended+xml": true,
 			"application/vnd.openxmlformats-officedocument.wordprocessingml.footnotes+xml":        true,
@@ -42,41 +204,136 @@ func NewOpenOfficeExtractor() *OpenOfficeExtractor {
 			"application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml":           true,
 			"application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml":           true,
 			"application/vnd.openxmlformats-package.relationships+xml":                            true,
+			stylesType:             true,
+			numberingType:          true,
+			corePropertiesType:     true,
+			extendedPropertiesType: true,
+			customPropertiesType:   true,
 		},
 		headerTypes: map[string]bool{
 			"http://schemas.openxmlformats.org/officeDocument/2006/relationships/header": true,
 			"http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer": true,
+			"http://purl.oclc.org/ooxml/officeDocument/relationships/header":             true,
+			"http://purl.oclc.org/ooxml/officeDocument/relationships/footer":             true,
 		},
 		actions:       make(map[string]Action),
 		defaults:      make(map[string]string),
+		overrides:     make(map[string]string),
 		relationships: make(map[string]Relationship),
 	}
+	for typ := range mainDocumentTypes {
+		e.streamTypes[typ] = true
+	}
 	return e
 }
 
 // Update the Extract method signature to match the interface
 func (e *OpenOfficeExtractor) Extract(reader io.ReadSeeker) (*Document, error) {
+	return e.ExtractContext(context.Background(), reader)
+}
+
+// ExtractContext extracts a document like Extract, and stops with the error
+// of the context once it is done
+func (e *OpenOfficeExtractor) ExtractContext(ctx context.Context, reader io.ReadSeeker) (*Document, error) {
+	return e.extractLimited(newLimiter(ctx, e.Options.Limits), reader)
+}
+
+// extractLimited extracts a document within the limiter of the extraction it
+// is part of
+func (e *OpenOfficeExtractor) extractLimited(limits *limiter, reader io.ReadSeeker) (*Document, error) {
 	e.document = NewDocument()
 	e.relationships = make(map[string]Relationship)
+	e.comments = nil
+	e.commentStarts = make(map[string]int)
+	e.commentEnds = make(map[string]int)
+	e.commentParents = make(map[string]string)
+	e.noteTexts = make(map[string]string)
+	e.noteRefs = nil
+	e.noteCounts = make(map[string]int)
+	e.partRelationships = make(map[string]map[string]Relationship)
+	e.fields, e.openLinks, e.links = nil, nil, nil
+	e.fieldCount, e.bodyFields = 0, nil
+	e.styles, e.defaultStyle, e.paragraphs = make(map[string]*docxStyle), "", nil
+	e.abstractNums, e.nums = make(map[string]*listDefinition), make(map[string]*docxNum)
+	e.images, e.objects, e.fallbacks, e.objectRefs = nil, 0, 0, nil
+	e.wordXML, e.inlineStories, e.annotationTypes = false, nil, nil
+	e.inlineParts, e.binData = make(map[string]*storyBuilder), make(map[string][]byte)
+
+	e.limits = limits
+	if err := e.limits.checkInput(reader); err != nil {
+		return nil, err
+	}
 
-	size, err := reader.Seek(0, io.SeekEnd)
+	files, entryNames, err := e.openPackage(reader)
 	if err != nil {
 		return nil, err
 	}
+	e.files = files
+
+	// The macros of a package are kept in its VBA project part
+	for name := range files {
+		if path.Base(name) == "vbaProject.bin" {
+			e.document.HasMacros = true
+		}
+	}
+
+	// Process entries in order
+	for _, name := range entryNames {
+		if e.shouldProcess(name) {
+			if err := e.handleEntry(name, files[name]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	e.buildComments()
+	e.buildNotes()
+	e.buildFields()
+	e.buildHyperlinks()
+	e.buildParagraphStyles()
+	e.buildImages()
+	e.buildEmbeddedObjects()
+
+	// Post-process textboxes and headerTextboxes
+	if e.document.Textboxes != "" {
+		e.document.Textboxes += "\n"
+	}
+	if e.document.HeaderTextboxes != "" {
+		e.document.HeaderTextboxes += "\n"
+	}
+
+	if err := e.limits.checkOutput(e.document); err != nil {
+		return nil, err
+	}
+	return e.document, nil
+}
+
+// openPackage reads the parts of a document, in the order they are
+// processed: the entries of a ZIP package, or the parts of a document saved
+// as a single XML file. The content types part comes first.
+func (e *OpenOfficeExtractor) openPackage(reader io.ReadSeeker) (map[string]packageFile, []string, error) {
+	if root, ok := xmlRootElement(reader); ok {
+		return e.openXMLDocument(reader, root)
+	}
+
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Reset to beginning of file
 	_, err = reader.Seek(0, io.SeekStart)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	zr, err := zip.NewReader(reader.(io.ReaderAt), size)
 	if err != nil {
-		return nil, err
+		return nil, nil, partError("", -1, err)
 	}
 
 	// Build entry table and order files
-	entryTable := make(map[string]*zip.File)
+	entryTable := make(map[string]packageFile)
 	entryNames := make([]string, 0)
 
 	for _, f := range zr.File {
@@ -98,27 +355,10 @@ func (e *OpenOfficeExtractor) Extract(reader io.ReadSeeker) (*Document, error) {
 		}
 	}
 	if !found {
-		return nil, fmt.Errorf("invalid Open Office XML: missing content types")
-	}
-
-	// Process entries in order
-	for _, name := range entryNames {
-		if e.shouldProcess(name) {
-			if err := e.handleEntry(entryTable[name]); err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	// Post-process textboxes and headerTextboxes
-	if e.document.Textboxes != "" {
-		e.document.Textboxes += "\n"
-	}
-	if e.document.HeaderTextboxes != "" {
-		e.document.HeaderTextboxes += "\n"
+		return nil, nil, &ExtractError{Part: contentTypesFile, Offset: -1, Err: errMissingPart}
 	}
 
-	return e.document, nil
+	return entryTable, entryNames, nil
 }
 
 func (e *OpenOfficeExtractor) shouldProcess(filename string) bool {
@@ -133,25 +373,43 @@ func (e *OpenOfficeExtractor) shouldProcess(filename string) bool {
 	return ok && e.streamTypes[defaultType]
 }
 
-func (e *OpenOfficeExtractor) handleEntry(f *zip.File) error {
-	rc, err := f.Open()
+func (e *OpenOfficeExtractor) handleEntry(name string, f packageFile) error {
+	rc, err := e.limits.openPart(name, f)
 	if err != nil {
-		return err
+		return partError(name, -1, err)
 	}
 	defer rc.Close()
 
-	decoder := xml.NewDecoder(rc)
+	e.part = name
+	decoder, offset := e.limits.newDecoder(rc)
+	switch typ := e.actions[name].typ; typ {
+	case corePropertiesType, extendedPropertiesType, customPropertiesType:
+		return partError(name, offset(), e.readProperties(decoder, typ))
+	case stylesType:
+		return partError(name, offset(), e.readStyles(decoder))
+	case numberingType:
+		return partError(name, offset(), e.readNumbering(decoder))
+	}
+
 	for {
 		token, err := decoder.Token()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
+			return partError(name, offset(), err)
 		}
 
 		switch t := token.(type) {
 		case xml.StartElement:
+			if e.wordXML {
+				if ok, err := e.readWordXMLBlock(decoder, t); ok {
+					if err != nil {
+						return partError(name, offset(), err)
+					}
+					continue
+				}
+			}
 			e.handleOpenTag(t)
 		case xml.EndElement:
 			e.handleCloseTag(t)
@@ -162,17 +420,106 @@ func (e *OpenOfficeExtractor) handleEntry(f *zip.File) error {
 	return nil
 }
 
+// mainDocumentTypes gives the format of each content type of the main
+// document part: documents and templates, with or without macros
+var mainDocumentTypes = map[string]Format{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml": FormatDocx,
+	"application/vnd.ms-word.document.macroEnabled.main+xml":                           FormatDocm,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.template.main+xml": FormatDotx,
+	"application/vnd.ms-word.template.macroEnabledTemplate.main+xml":                   FormatDotm,
+}
+
+// Content types of the styles and numbering parts
 const (
-	WordMLNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	stylesType    = "application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"
+	numberingType = "application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"
 )
 
+// Content types of the document property parts
+const (
+	corePropertiesType     = "application/vnd.openxmlformats-package.core-properties+xml"
+	extendedPropertiesType = "application/vnd.openxmlformats-officedocument.extended-properties+xml"
+	customPropertiesType   = "application/vnd.openxmlformats-officedocument.custom-properties+xml"
+)
+
+const (
+	WordMLNamespace       = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	WordMLStrictNamespace = "http://purl.oclc.org/ooxml/wordprocessingml/main"
+	WordML2012Namespace   = "http://schemas.microsoft.com/office/word/2012/wordml"
+)
+
+// Namespaces of the drawings that hold pictures and objects
+const (
+	officeNamespace              = "urn:schemas-microsoft-com:office:office"
+	drawingNamespace             = "http://schemas.openxmlformats.org/drawingml/2006/main"
+	wordDrawingNamespace         = "http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"
+	vmlNamespace                 = "urn:schemas-microsoft-com:vml"
+	markupCompatibilityNamespace = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+)
+
+// strictNamespaces maps the namespaces of ISO 29500 Strict documents to their
+// transitional counterparts. Content types are the same in both.
+var strictNamespaces = map[string]string{
+	WordMLStrictNamespace:                                        WordMLNamespace,
+	"http://purl.oclc.org/ooxml/drawingml/main":                  drawingNamespace,
+	"http://purl.oclc.org/ooxml/drawingml/wordprocessingDrawing": wordDrawingNamespace,
+}
+
+// transitionalName returns a name in a Strict namespace as the name in the
+// transitional one
+func transitionalName(name xml.Name) xml.Name {
+	if space, ok := strictNamespaces[name.Space]; ok {
+		name.Space = space
+	}
+	return name
+}
+
 func (e *OpenOfficeExtractor) isWordMLElement(se xml.Name) bool {
-	return se.Space == WordMLNamespace
+	se = transitionalName(se)
+	return se.Space == WordMLNamespace || se.Space == WordML2003Namespace
 }
 
 func (e *OpenOfficeExtractor) handleOpenTag(se xml.StartElement) {
 	// For debugging
 	// fmt.Printf("StartElement Space: %s, Local: %s\n", se.Name.Space, se.Name.Local)
+	se.Name = transitionalName(se.Name)
+
+	// Comment threads are held in the Word 2012 namespace
+	if se.Name.Space == WordML2012Namespace && se.Name.Local == "commentEx" {
+		if parent := attrValue(se, "paraIdParent"); parent != "" {
+			e.commentParents[attrValue(se, "paraId")] = parent
+		}
+		return
+	}
+
+	// Pictures are drawn with DrawingML, or with VML in older documents. The
+	// fallback of alternate content repeats the drawing it stands in for.
+	switch {
+	case se.Name.Space == markupCompatibilityNamespace && se.Name.Local == "Fallback":
+		e.fallbacks++
+	case se.Name.Space == wordDrawingNamespace && se.Name.Local == "docPr":
+		e.pictureAlt = attrValue(se, "descr")
+	case se.Name.Space == drawingNamespace && se.Name.Local == "blip":
+		e.addImage(attrValue(se, "embed"), e.pictureAlt)
+	case se.Name.Space == vmlNamespace && se.Name.Local == "shape":
+		e.pictureAlt = attrValue(se, "alt")
+	case se.Name.Space == vmlNamespace && se.Name.Local == "imagedata":
+		alt := e.pictureAlt
+		if alt == "" {
+			alt = attrValue(se, "title")
+		}
+		id := attrValue(se, "id")
+		if id == "" {
+			id = attrValue(se, "src")
+		}
+		e.addImage(id, alt)
+	case se.Name.Space == amlNamespace && se.Name.Local == "annotation":
+		e.openAnnotation(se)
+	case se.Name.Space == officeNamespace && se.Name.Local == "OLEObject":
+		if attrValue(se, "Type") != "Link" {
+			e.objectRefs = append(e.objectRefs, docxObjectReference{part: e.part, relID: attrValue(se, "id"), progID: attrValue(se, "ProgID")})
+		}
+	}
 
 	// Only check Local name if it's in the Word ML namespace
 	if !e.isWordMLElement(se.Name) && se.Name.Local != "Override" && se.Name.Local != "Default" && se.Name.Local != "Relationship" {
@@ -191,9 +538,16 @@ func (e *OpenOfficeExtractor) handleOpenTag(se xml.StartElement) {
 				partName = strings.TrimPrefix(attr.Value, "/")
 			}
 		}
+		e.overrides[partName] = contentType
 		if e.streamTypes[contentType] {
 			e.actions[partName] = Action{typ: contentType, action: e.streamTypes[contentType]}
 		}
+		if format, ok := mainDocumentTypes[contentType]; ok {
+			e.mainPart = partName
+			if e.document.Format == "" {
+				e.document.Format = format
+			}
+		}
 
 	case "Default":
 		var extension, contentType string
@@ -220,16 +574,40 @@ func (e *OpenOfficeExtractor) handleOpenTag(se xml.StartElement) {
 			}
 		}
 		e.relationships[id] = Relationship{Type: typ, Target: target}
+		if e.partRelationships[e.part] == nil {
+			e.partRelationships[e.part] = make(map[string]Relationship)
+		}
+		e.partRelationships[e.part][id] = Relationship{Type: typ, Target: target}
 
 	case "document", "footnotes", "endnotes", "comments":
 		e.context = []string{"content", "body"}
-		e.pieces = [][]rune{}
+		e.story = newStoryBuilder(e.limits)
+		e.inDocument = se.Name.Local == "document"
+
+	case "wordDocument":
+		// The properties, styles and lists of Word 2003 XML come before the
+		// body, which holds the text
+		e.story = newStoryBuilder(e.limits)
+		e.inDocument = true
+
+	case "body":
+		if e.wordXML {
+			e.context = []string{"content", "body"}
+		}
 
 	case "hdr", "ftr":
+		if e.wordXML {
+			e.pushStory(se.Name.Local, newStoryBuilder(e.limits), "content", "header")
+			break
+		}
 		e.context = []string{"content", "header"}
-		e.pieces = [][]rune{}
+		e.story = newStoryBuilder(e.limits)
 
 	case "endnote", "footnote": // JS: w:endnote, w:footnote
+		if e.wordXML {
+			e.openInlineNote(se)
+			break
+		}
 		typ := "content"
 		for _, attr := range se.Attr {
 			if attr.Name.Local == "type" {
@@ -237,10 +615,53 @@ func (e *OpenOfficeExtractor) handleOpenTag(se xml.StartElement) {
 			}
 		}
 		e.context = append([]string{typ}, e.context...)
+		if typ == "content" {
+			e.note = &docxNote{key: se.Name.Local + ":" + attrValue(se, "id"), start: e.story.offset()}
+		}
+
+	case "footnoteReference", "endnoteReference":
+		if e.inDocument && len(e.storyStack) == 0 {
+			kind := strings.TrimSuffix(se.Name.Local, "Reference")
+			id := attrValue(se, "id")
+			e.noteRefs = append(e.noteRefs, docxNoteReference{key: kind + ":" + id, id: id, offset: e.story.offset()})
+			e.noteCounts[kind]++
+			if e.Options.NoteMarkers {
+				e.writeText(noteMarker(kind == "endnote", e.noteCounts[kind]))
+			}
+		}
+
+	case "comment":
+		date, _ := time.Parse(time.RFC3339, attrValue(se, "date"))
+		e.comment = &docxComment{
+			comment: &Comment{
+				ID:       attrValue(se, "id"),
+				Author:   attrValue(se, "author"),
+				Initials: attrValue(se, "initials"),
+				Date:     date,
+			},
+			start: e.story.offset(),
+		}
+
+	case "commentRangeStart":
+		if e.inDocument && len(e.storyStack) == 0 {
+			e.commentStarts[attrValue(se, "id")] = e.story.offset()
+		}
+
+	case "commentRangeEnd":
+		if e.inDocument && len(e.storyStack) == 0 {
+			e.commentEnds[attrValue(se, "id")] = e.story.offset()
+		}
+
+	case "commentReference":
+		// A comment without a range is anchored at its reference
+		id := attrValue(se, "id")
+		if _, ok := e.commentEnds[id]; !ok && e.inDocument && len(e.storyStack) == 0 {
+			e.commentEnds[id] = e.story.offset()
+		}
 
 	case "tab": // JS: w:tab
 		if len(e.context) > 0 && e.context[0] == "content" {
-			e.pieces = append(e.pieces, []rune("\t"))
+			e.writeText("\t")
 		}
 
 	case "br": // JS: w:br
@@ -259,37 +680,161 @@ func (e *OpenOfficeExtractor) handleOpenTag(se xml.StartElement) {
 			// 	e.pieces = append(e.pieces, []rune("\\n"))
 			// }
 			// Simplified version since outcome is currently the same:
-			e.pieces = append(e.pieces, []rune("\n"))
+			e.writeText("\n")
 		}
 
-	case "del", "instrText": // JS: w:del, w:instrText
+	case "instrText", "delInstrText": // JS: w:instrText
 		e.context = append([]string{"deleted"}, e.context...)
+		e.inInstruction = true
+
+	case "fldChar":
+		switch attrValue(se, "fldCharType") {
+		case "begin":
+			e.openField(&docxField{})
+		case "separate":
+			if len(e.fields) > 0 {
+				field := e.fields[len(e.fields)-1]
+				field.separated = true
+				field.start = e.story.offset()
+			}
+		case "end":
+			e.closeField()
+		}
+
+	case "fldSimple":
+		field := &docxField{separated: true, start: e.story.offset()}
+		field.instruction.WriteString(attrValue(se, "instr"))
+		e.openField(field)
+
+	case "hyperlink":
+		e.openLinks = append(e.openLinks, &docxLink{
+			link:  &Hyperlink{Anchor: attrValue(se, "anchor"), Offset: e.story.offset()},
+			relID: attrValue(se, "id"),
+			field: &Field{Type: "HYPERLINK", Depth: len(e.fields), begin: e.fieldCount},
+		})
+		e.fieldCount++
+
+	case "hlink":
+		// Word 2003 XML gives the target of a link on the element
+		e.openLinks = append(e.openLinks, &docxLink{
+			link:  &Hyperlink{URL: attrValue(se, "dest"), Anchor: attrValue(se, "bookmark"), Offset: e.story.offset()},
+			field: &Field{Type: "HYPERLINK", Depth: len(e.fields), begin: e.fieldCount},
+		})
+		e.fieldCount++
+
+	case "ins", "moveTo":
+		e.openRevision(se, Insertion)
+
+	case "del", "moveFrom": // JS: w:del
+		e.openRevision(se, Deletion)
+
+	case "pPrChange":
+		// The previous paragraph properties hold no revision marks
+		e.inParagraphPr = false
 
 	case "tabs": // JS: w:tabs
 		e.context = append([]string{"tabs"}, e.context...)
 
+	case "p": // JS: w:p
+		if e.comment != nil {
+			e.comment.paraID = attrValue(se, "paraId")
+		}
+		// A paragraph whose mark was hidden by the revision mode runs on
+		// into this one
+		if !e.joinParagraph {
+			e.story.startParagraph()
+		}
+		e.joinParagraph = false
+		e.hideParagraph = false
+		e.paraStyle, e.paraLevel = "", -1
+		e.paraNumID, e.paraIlvl = "", -1
+
+	case "pStyle":
+		if e.inParagraphPr {
+			e.paraStyle = attrValue(se, "val")
+		}
+
+	case "outlineLvl":
+		if e.inParagraphPr {
+			if level, err := strconv.Atoi(attrValue(se, "val")); err == nil {
+				e.paraLevel = level
+			}
+		}
+
+	case "numId", "ilfo":
+		if e.inParagraphPr {
+			e.paraNumID = attrValue(se, "val")
+		}
+
+	case "ilvl":
+		if e.inParagraphPr {
+			if ilvl, err := strconv.Atoi(attrValue(se, "val")); err == nil {
+				e.paraIlvl = ilvl
+			}
+		}
+
+	case "pPr":
+		e.inParagraphPr = true
+
+	case "tbl":
+		e.story.startTable()
+
+	case "tr":
+		e.story.startRow()
+
 	case "tc": // JS: w:tc
 		e.context = append([]string{"cell"}, e.context...)
+		e.story.startCell()
 
 	case "drawing": // JS: w:drawing
 		e.context = append([]string{"drawing"}, e.context...)
+		e.pictureAlt = ""
+
+	case "object":
+		// The picture of an embedded object is only its preview
+		e.objects++
+
+	case "binData":
+		e.binName = attrValue(se, "name")
+
+	case "objectEmbed":
+		e.objectRefs = append(e.objectRefs, docxObjectReference{part: e.part, relID: attrValue(se, "id"), progID: attrValue(se, "progId")})
+
+	case "gridSpan":
+		if span, err := strconv.Atoi(attrValue(se, "val")); err == nil {
+			e.story.setGridSpan(span)
+		}
+
+	case "vMerge", "vmerge":
+		if attrValue(se, "val") == "restart" {
+			e.story.setVMerge(MergeRestart)
+		} else {
+			e.story.setVMerge(MergeContinue)
+		}
+
+	case "hMerge", "hmerge":
+		if attrValue(se, "val") != "restart" {
+			e.story.foldCell()
+		}
 
 	case "txbxContent": // JS: w:txbxContent
-		// Push current pieces onto the stack
-		e.piecesStack = append(e.piecesStack, e.pieces)
-		// Reset pieces for the textbox content
-		e.pieces = [][]rune{}
+		// Push current story onto the stack
+		e.storyStack = append(e.storyStack, e.story)
+		// Start a fresh story for the textbox content
+		e.story = newStoryBuilder(e.limits)
 		// Push textbox context marker
 		e.context = append([]string{"textbox"}, e.context...)
-		// --- Original incorrect Go logic removed ---
-		// oldPieces := e.pieces
-		// e.pieces = [][]rune{}
-		// e.context = append([]string{"textbox"}, e.context...)
-		// e.pieces = append(oldPieces, e.pieces...)
 	}
 }
 
 func (e *OpenOfficeExtractor) handleCloseTag(ee xml.EndElement) {
+	if ee.Name.Space == markupCompatibilityNamespace && ee.Name.Local == "Fallback" {
+		e.fallbacks--
+	}
+	if ee.Name.Space == amlNamespace && ee.Name.Local == "annotation" {
+		e.closeAnnotation(ee)
+	}
+
 	// Only check Local name if it's in the Word ML namespace
 	if !e.isWordMLElement(ee.Name) && ee.Name.Local != "Override" && ee.Name.Local != "Default" && ee.Name.Local != "Relationship" {
 		return
@@ -297,44 +842,110 @@ func (e *OpenOfficeExtractor) handleCloseTag(ee xml.EndElement) {
 
 	switch ee.Name.Local {
 	// Match JS order
-	case "document": // JS: w:document
-		e.document.Body = string(joinRunes(e.pieces))
+	case "body":
+		if e.wordXML {
+			e.context = nil
+		}
+
+	case "document", "wordDocument": // JS: w:document
+		e.story.finish()
+		e.document.Body = e.story.String()
+		e.document.Blocks = e.story.Blocks()
+		sort.SliceStable(e.document.revisions, func(i, j int) bool {
+			return e.document.revisions[i].Offset < e.document.revisions[j].Offset
+		})
 		e.context = nil
+		e.inDocument = false
 
 	case "footnote", "endnote": // JS: w:footnote, w:endnote (Combined in Go)
+		if e.wordXML {
+			e.closeInlineNote(ee.Name.Local)
+			break
+		}
 		if len(e.context) > 0 {
 			e.context = e.context[1:]
 		}
+		if e.note != nil {
+			e.noteTexts[e.note.key] = strings.TrimSpace(string(e.story.text[e.note.start:]))
+			e.note = nil
+		}
 
 	case "footnotes": // JS: w:footnotes
-		e.document.Footnotes = string(joinRunes(e.pieces))
+		e.document.Footnotes = e.story.String()
 		e.context = nil
 
 	case "endnotes": // JS: w:endnotes
-		e.document.Endnotes = string(joinRunes(e.pieces))
+		e.document.Endnotes = e.story.String()
 		e.context = nil
 
 	case "comments": // JS: w:comments
-		e.document.Annotations = string(joinRunes(e.pieces))
+		e.document.Annotations = e.story.String()
 		e.context = nil
 
+	case "comment":
+		if e.comment != nil {
+			text := string(e.story.text[e.comment.start:])
+			e.comment.comment.Text = strings.TrimSuffix(text, "\n")
+			e.comments = append(e.comments, e.comment)
+			e.comment = nil
+		}
+
 	case "hdr": // JS: w:hdr
-		e.document.Headers += string(joinRunes(e.pieces))
-		e.context = nil
+		story := e.story
+		if _, ok := e.popStory(ee.Name.Local); !ok {
+			e.context = nil
+		}
+		e.document.Headers += story.String()
 
 	case "ftr": // JS: w:ftr
-		e.document.Footers += string(joinRunes(e.pieces))
-		e.context = nil
+		story := e.story
+		if _, ok := e.popStory(ee.Name.Local); !ok {
+			e.context = nil
+		}
+		e.document.Footers += story.String()
 
 	case "p": // JS: w:p
-		if len(e.context) > 0 && (e.context[0] == "content" || e.context[0] == "cell" || e.context[0] == "textbox") {
-			e.pieces = append(e.pieces, []rune("\n")) // Corrected: Use newline rune
+		if e.hideParagraph {
+			e.joinParagraph = true
+		} else if len(e.context) > 0 && (e.context[0] == "content" || e.context[0] == "cell" || e.context[0] == "textbox") {
+			p := e.story.endParagraph()
+			if e.inDocument && len(e.storyStack) == 0 {
+				e.paragraphs = append(e.paragraphs, docxParagraph{
+					paragraph: p,
+					style:     e.paraStyle,
+					level:     e.paraLevel,
+					numID:     e.paraNumID,
+					ilvl:      e.paraIlvl,
+				})
+			}
 		}
 
-	case "del", "instrText": // JS: w:del, w:instrText
+	case "pPr":
+		e.inParagraphPr = false
+
+	case "instrText", "delInstrText": // JS: w:instrText
 		if len(e.context) > 0 {
 			e.context = e.context[1:]
 		}
+		e.inInstruction = false
+
+	case "fldSimple":
+		e.closeField()
+
+	case "hyperlink", "hlink":
+		if n := len(e.openLinks); n > 0 {
+			link := e.openLinks[n-1]
+			e.openLinks = e.openLinks[:n-1]
+			if e.inDocument && len(e.storyStack) == 0 {
+				link.link.Length = e.story.offset() - link.link.Offset
+				link.field.Offset, link.field.Length = link.link.Offset, link.link.Length
+				e.links = append(e.links, link)
+				e.bodyFields = append(e.bodyFields, link.field)
+			}
+		}
+
+	case "ins", "moveTo", "del", "moveFrom": // JS: w:del
+		e.closeRevision()
 
 	case "tabs": // JS: w:tabs
 		if len(e.context) > 0 {
@@ -342,27 +953,34 @@ func (e *OpenOfficeExtractor) handleCloseTag(ee xml.EndElement) {
 		}
 
 	case "tc":
-		// In JS, it pops the last piece (often a \n from <w:p>) before adding \t.
-		if len(e.pieces) > 0 {
-			e.pieces = e.pieces[:len(e.pieces)-1]
-		}
-		e.pieces = append(e.pieces, []rune("\t"))
+		// As in JS, the cell's final \n (from its last <w:p>) becomes a \t.
+		e.story.endCell()
 		if len(e.context) > 0 {
 			e.context = e.context[1:] // Pop "cell"
 		}
 
 	case "tr": // JS: w:tr
 		// Add newline after a table row (Matches JS unconditional behavior)
-		e.pieces = append(e.pieces, []rune("\n")) // Corrected: Use newline rune and remove condition
+		e.story.endRow()
+
+	case "tbl":
+		e.story.endTable()
 
 	case "drawing": // JS: w:drawing
 		if len(e.context) > 0 {
 			e.context = e.context[1:]
 		}
 
+	case "object":
+		e.objects--
+
+	case "binData":
+		e.closeBinData()
+
 	case "txbxContent":
 		// Get the text content accumulated within the textbox
-		textBox := string(joinRunes(e.pieces))
+		e.story.finish()
+		textBox := e.story.String()
 
 		if e.context[0] != "textbox" {
 			fmt.Printf("Warning: Invalid textbox context\n")
@@ -373,36 +991,19 @@ func (e *OpenOfficeExtractor) handleCloseTag(ee xml.EndElement) {
 			e.context = e.context[1:] // Pop "textbox"
 		}
 
-		// Pop the previous pieces state from the stack
-		if len(e.piecesStack) > 0 {
-			e.pieces = e.piecesStack[len(e.piecesStack)-1]
-			e.piecesStack = e.piecesStack[:len(e.piecesStack)-1]
+		// Pop the previous story from the stack
+		if len(e.storyStack) > 0 {
+			e.story = e.storyStack[len(e.storyStack)-1]
+			e.storyStack = e.storyStack[:len(e.storyStack)-1]
 		} else {
 			// Should not happen if open/close tags are balanced
-			e.pieces = [][]rune{} // Reset pieces if stack is empty
-		}
-
-		// --- Original incorrect Go restoration logic removed ---
-		// if len(e.context) < 3 { // This check was arbitrary
-		// 	return
-		// }
-		// textBox := string(joinRunes(e.pieces))
-		// e.context = e.context[1:] // remove "textbox"
-		// // Restore previous pieces from context
-		// prevPieces := [][]rune{}
-		// idx := 0
-		// for i, ctx := range e.context {
-		// 	if ctx != "" {
-		// 		prevPieces = e.pieces[i:] // Incorrect logic
-		// 		idx = i
-		// 		break
-		// 	}
-		// }
-		// e.context = e.context[:idx]
-		// e.pieces = prevPieces
-
-		// If in drawing context, discard (Matches JS)
+			e.story = newStoryBuilder(e.limits)
+		}
+
+		// If in drawing context, discard (Matches JS). Its text is no longer
+		// counted as extracted.
 		if len(e.context) > 0 && e.context[0] == "drawing" {
+			e.limits.addOutput(-utf8.RuneCountInString(textBox))
 			return
 		}
 
@@ -435,6 +1036,13 @@ func (e *OpenOfficeExtractor) handleCloseTag(ee xml.EndElement) {
 }
 
 func (e *OpenOfficeExtractor) handleCharData(cd xml.CharData) {
+	if e.inInstruction && len(e.fields) > 0 {
+		e.fields[len(e.fields)-1].instruction.Write(cd)
+	}
+	if e.binName != "" {
+		e.binText.Write(cd)
+		return
+	}
 	if len(e.context) == 0 {
 		return
 	}
@@ -443,23 +1051,649 @@ func (e *OpenOfficeExtractor) handleCharData(cd xml.CharData) {
 	// fmt.Printf("Current context: %s\n", e.context[0])
 
 	if e.context[0] == "content" || e.context[0] == "cell" || e.context[0] == "textbox" {
-		// fmt.Printf("Append to pieces: %s\n", string(cd))
-		e.pieces = append(e.pieces, []rune(string(cd)))
+		e.writeText(string(cd))
+	}
+}
+
+// writeText adds text to the current story, unless it is inside a tracked
+// change that is hidden by the revision mode. The text is also collected by
+// any open tracked changes.
+func (e *OpenOfficeExtractor) writeText(text string) {
+	for _, rev := range e.openRevisions {
+		rev.text.WriteString(text)
+	}
+	if e.hiddenRevisions > 0 {
+		return
+	}
+	for _, rev := range e.openRevisions {
+		if start, _ := e.Options.Revisions.markers(rev.revision.Type); !rev.marked && start != "" {
+			e.story.write(start)
+			rev.marked = true
+		}
+	}
+	e.story.write(text)
+}
+
+func (e *OpenOfficeExtractor) openRevision(se xml.StartElement, typ RevisionType) {
+	// A revision mark in the paragraph properties applies to the paragraph
+	// mark itself
+	if e.inParagraphPr && !e.Options.Revisions.shows(typ) {
+		e.hideParagraph = true
+	}
+
+	// Word 2003 XML gives the date of a change as its creation date
+	date := attrValue(se, "date")
+	if date == "" {
+		date = attrValue(se, "createdate")
+	}
+	revDate, _ := time.Parse(time.RFC3339, date)
+	rev := &openRevision{
+		revision: Revision{
+			Type:   typ,
+			Author: attrValue(se, "author"),
+			Date:   revDate,
+			Offset: e.story.offset(),
+		},
+		shown:  e.Options.Revisions.shows(typ),
+		record: e.inDocument && len(e.storyStack) == 0,
+	}
+	if !rev.shown {
+		e.hiddenRevisions++
+	}
+	e.openRevisions = append(e.openRevisions, rev)
+}
+
+func (e *OpenOfficeExtractor) closeRevision() {
+	if len(e.openRevisions) == 0 {
+		return
+	}
+	rev := e.openRevisions[len(e.openRevisions)-1]
+	e.openRevisions = e.openRevisions[:len(e.openRevisions)-1]
+	if !rev.shown {
+		e.hiddenRevisions--
+	}
+	if rev.marked {
+		_, end := e.Options.Revisions.markers(rev.revision.Type)
+		e.story.write(end)
+	}
+	// Revision marks on paragraph properties have no text, and are not listed
+	if !rev.record || rev.text.Len() == 0 {
+		return
+	}
+
+	// Word often splits one change over several elements, so a revision
+	// that carries straight on from the previous one is joined to it
+	revisions := e.document.revisions
+	if n := len(revisions); n > 0 && e.lastRevision == rev.revision.Offset && sameRevision(revisions[n-1], rev.revision) {
+		revisions[n-1].Text += rev.text.String()
+	} else {
+		rev.revision.Text = rev.text.String()
+		e.document.revisions = append(revisions, rev.revision)
+	}
+	e.lastRevision = e.story.offset()
+}
+
+// buildComments anchors the comments read from the comments part to the body
+// and threads their replies
+func (e *OpenOfficeExtractor) buildComments() {
+	if len(e.comments) == 0 {
+		return
+	}
+	body := []rune(e.document.Body)
+	byParaID := make(map[string]int)
+	for i, c := range e.comments {
+		if c.paraID != "" {
+			byParaID[c.paraID] = i
+		}
+	}
+
+	comments := make([]*Comment, len(e.comments))
+	parents := make([]int, len(e.comments))
+	for i, c := range e.comments {
+		id := c.comment.ID
+		end, ok := e.commentEnds[id]
+		start, hasStart := e.commentStarts[id]
+		if !ok {
+			end = start
+		}
+		if !hasStart {
+			start = end
+		}
+		c.comment.anchor(body, start, end)
+
+		parents[i] = -1
+		if parent, ok := byParaID[e.commentParents[c.paraID]]; ok && c.paraID != "" {
+			parents[i] = parent
+		}
+		comments[i] = c.comment
+	}
+	e.document.comments = threadComments(comments, parents)
+}
+
+// buildNotes lists the footnotes and endnotes in the order they are
+// referenced from the body
+func (e *OpenOfficeExtractor) buildNotes() {
+	for _, ref := range e.noteRefs {
+		note := &Note{ID: ref.id, Text: e.noteTexts[ref.key], Offset: ref.offset}
+		if strings.HasPrefix(ref.key, "endnote:") {
+			e.document.endnotes = append(e.document.endnotes, note)
+		} else {
+			e.document.footnotes = append(e.document.footnotes, note)
+		}
+	}
+}
+
+// readProperties reads one of the document property parts into the document
+// metadata. These parts are flat lists of named values, so each element's
+// text is taken as it closes.
+func (e *OpenOfficeExtractor) readProperties(decoder *xml.Decoder, typ string) error {
+	meta := &e.document.Metadata
+	var text strings.Builder
+	var property string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			text.Reset()
+			if typ == customPropertiesType && t.Name.Local == "property" {
+				property = attrValue(t, "name")
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			text.Reset()
+			switch typ {
+			case corePropertiesType:
+				setCoreProperty(meta, t.Name.Local, value)
+			case extendedPropertiesType:
+				setExtendedProperty(meta, t.Name.Local, value)
+			case customPropertiesType:
+				if t.Name.Local == "property" {
+					property = ""
+				} else if property != "" {
+					meta.setCustom(property, value)
+				}
+			}
+		}
+	}
+}
+
+func setCoreProperty(meta *Metadata, name, value string) {
+	switch name {
+	case "title":
+		meta.Title = value
+	case "subject":
+		meta.Subject = value
+	case "creator":
+		meta.Author = value
+	case "keywords":
+		meta.Keywords = value
+	case "description":
+		meta.Comments = value
+	case "category":
+		meta.Category = value
+	case "lastModifiedBy":
+		meta.LastModifiedBy = value
+	case "revision":
+		meta.Revision = value
+	case "created":
+		meta.Created, _ = time.Parse(time.RFC3339, value)
+	case "modified":
+		meta.Modified, _ = time.Parse(time.RFC3339, value)
+	}
+}
 
-		// fmt.Printf("Current pieces: %s\n", string(joinRunes(e.pieces)))
+func setExtendedProperty(meta *Metadata, name, value string) {
+	switch name {
+	case "Pages":
+		meta.Pages = parseCount(value)
+	case "Words":
+		meta.Words = parseCount(value)
+	case "Characters":
+		meta.Characters = parseCount(value)
+	case "Company":
+		meta.Company = value
+	case "Template":
+		meta.Template = value
+	case "Application":
+		meta.Application = value
 	}
 }
 
-// Helper function to join rune slices
-func joinRunes(pieces [][]rune) []rune {
-	var total int
-	for _, p := range pieces {
-		total += len(p)
+// readStyles reads the names, base styles and outline levels of the paragraph
+// styles in the styles part
+func (e *OpenOfficeExtractor) readStyles(decoder *xml.Decoder) error {
+	var style *docxStyle
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !e.isWordMLElement(t.Name) {
+				continue
+			}
+			switch t.Name.Local {
+			case "style":
+				style = nil
+				if attrValue(t, "type") == "paragraph" {
+					style = &docxStyle{level: -1}
+					e.styles[attrValue(t, "styleId")] = style
+					if def := attrValue(t, "default"); def == "1" || def == "on" {
+						e.defaultStyle = attrValue(t, "styleId")
+					}
+				}
+			case "name":
+				if style != nil {
+					style.name = attrValue(t, "val")
+					// Built-in heading styles are stored in lower case
+					if level := headingStyleLevel(style.name); level > 0 && strings.HasPrefix(style.name, "heading") {
+						style.name = "Heading " + strconv.Itoa(level)
+					}
+				}
+			case "basedOn":
+				if style != nil {
+					style.basedOn = attrValue(t, "val")
+				}
+			case "outlineLvl":
+				if style != nil {
+					if level, err := strconv.Atoi(attrValue(t, "val")); err == nil {
+						style.level = level
+					}
+				}
+			case "numId", "ilfo":
+				if style != nil {
+					style.numID = attrValue(t, "val")
+				}
+			case "ilvl":
+				if style != nil {
+					style.ilvl, _ = strconv.Atoi(attrValue(t, "val"))
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "style":
+				style = nil
+			case "styles":
+				return nil
+			}
+		}
 	}
+}
 
-	result := make([]rune, 0, total)
-	for _, p := range pieces {
-		result = append(result, p...)
+// buildParagraphStyles fills in the style name, outline level and list
+// numbering of each paragraph of the body. Paragraphs without a style have the
+// default one. An outline level or list set on the paragraph wins over the one
+// of its style, which may come from a base style.
+func (e *OpenOfficeExtractor) buildParagraphStyles() {
+	numbering := newListNumbering()
+	for _, para := range e.paragraphs {
+		p := para.paragraph
+		if para.style == "" {
+			para.style = e.defaultStyle
+		}
+		styles := e.styleChain(para.style)
+		if len(styles) > 0 {
+			p.Style = styles[0].name
+		} else {
+			p.Style = para.style
+		}
+
+		p.Level = headingStyleLevel(p.Style)
+		if para.level >= 0 {
+			p.Level = outlineLevel(para.level)
+		} else {
+			for _, style := range styles {
+				if style.level >= 0 {
+					p.Level = outlineLevel(style.level)
+					break
+				}
+			}
+		}
+
+		numID, ilvl := para.numID, para.ilvl
+		for _, style := range styles {
+			if numID != "" {
+				break
+			}
+			numID = style.numID
+			if ilvl < 0 {
+				ilvl = style.ilvl
+			}
+		}
+		num := e.nums[numID]
+		if num == nil || e.abstractNums[num.abstractID] == nil {
+			continue
+		}
+		key := "abstract:" + num.abstractID
+		if numbering.first(numID) {
+			for level, start := range num.starts {
+				numbering.restart(key, level, start)
+			}
+		}
+		p.List = numbering.next(key, e.abstractNums[num.abstractID], ilvl)
+	}
+}
+
+// styleChain returns a paragraph style followed by the styles it is based on
+func (e *OpenOfficeExtractor) styleChain(id string) []*docxStyle {
+	var chain []*docxStyle
+	for style := e.styles[id]; style != nil && len(chain) <= len(e.styles); style = e.styles[style.basedOn] {
+		chain = append(chain, style)
+	}
+	return chain
+}
+
+// readNumbering reads the list definitions and list instances of the
+// numbering part
+func (e *OpenOfficeExtractor) readNumbering(decoder *xml.Decoder) error {
+	var def *listDefinition
+	var num *docxNum
+	var level *listLevel
+	override := -1
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !e.isWordMLElement(t.Name) {
+				continue
+			}
+			val := attrValue(t, "val")
+			switch t.Name.Local {
+			case "abstractNum":
+				def = newListDefinition()
+				e.abstractNums[attrValue(t, "abstractNumId")] = def
+			case "listDef":
+				def = newListDefinition()
+				e.abstractNums[attrValue(t, "listDefId")] = def
+			case "num":
+				num = &docxNum{starts: make(map[int]int)}
+				e.nums[attrValue(t, "numId")] = num
+			case "list":
+				num = &docxNum{starts: make(map[int]int)}
+				e.nums[attrValue(t, "ilfo")] = num
+			case "abstractNumId", "ilst":
+				if num != nil {
+					num.abstractID = val
+				}
+			case "lvlOverride":
+				override, _ = strconv.Atoi(attrValue(t, "ilvl"))
+			case "startOverride":
+				if start, err := strconv.Atoi(val); err == nil && num != nil && override >= 0 {
+					num.starts[override] = start
+				}
+			case "lvl":
+				ilvl, err := strconv.Atoi(attrValue(t, "ilvl"))
+				if def != nil && err == nil && ilvl >= 0 && ilvl < len(def.levels) {
+					level = &def.levels[ilvl]
+				}
+			case "start":
+				if start, err := strconv.Atoi(val); err == nil && level != nil {
+					level.start = start
+				}
+			case "numFmt":
+				if level != nil {
+					level.format = val
+				}
+			case "nfc":
+				if n, err := strconv.Atoi(val); err == nil && level != nil {
+					if format, ok := listFormats[byte(n)]; ok {
+						level.format = format
+					}
+				}
+			case "lvlText":
+				if level != nil {
+					level.text = val
+				}
+			case "lvlRestart":
+				if restart, err := strconv.Atoi(val); err == nil && level != nil {
+					level.restart = restart
+				}
+			case "isLgl":
+				if level != nil {
+					level.legal = val != "0" && val != "false" && val != "off"
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "abstractNum", "listDef":
+				def = nil
+			case "num", "list":
+				num = nil
+			case "lvlOverride":
+				override = -1
+			case "lvl":
+				level = nil
+			case "numbering", "lists":
+				return nil
+			}
+		}
+	}
+}
+
+// openField starts a field inside any fields already open
+func (e *OpenOfficeExtractor) openField(field *docxField) {
+	field.depth, field.order = len(e.fields), e.fieldCount
+	e.fieldCount++
+	e.fields = append(e.fields, field)
+}
+
+// closeField ends the innermost open field. A field in the body is recorded
+// along with its result, and a hyperlink field as a link covering the result.
+func (e *OpenOfficeExtractor) closeField() {
+	n := len(e.fields)
+	if n == 0 {
+		return
+	}
+	field := e.fields[n-1]
+	e.fields = e.fields[:n-1]
+	if !e.inDocument || len(e.storyStack) > 0 {
+		return
+	}
+	if !field.separated {
+		field.start = e.story.offset()
+	}
+	result := parseField(field.instruction.String())
+	result.Depth, result.begin = field.depth, field.order
+	result.Offset, result.Length = field.start, e.story.offset()-field.start
+	e.bodyFields = append(e.bodyFields, result)
+	if link, ok := fieldHyperlink(result); ok && field.separated {
+		e.links = append(e.links, &docxLink{link: link})
+	}
+}
+
+// buildFields puts the fields of the body in order and fills in their results
+func (e *OpenOfficeExtractor) buildFields() {
+	sortFields(e.bodyFields)
+	setFieldResults(e.bodyFields, []rune(e.document.Body))
+	e.document.fields = e.bodyFields
+}
+
+// buildHyperlinks looks up the targets of the links in the body, which are
+// held in the relationships of the main document part, and writes the
+// instruction of the fields made for them
+func (e *OpenOfficeExtractor) buildHyperlinks() {
+	if len(e.links) == 0 {
+		return
+	}
+	rels := e.mainRelationships()
+	links := make([]*Hyperlink, len(e.links))
+	for i, l := range e.links {
+		if l.relID != "" {
+			l.link.URL = rels[l.relID].Target
+		}
+		if l.field != nil {
+			parsed := parseField(l.link.instruction())
+			l.field.Instruction, l.field.Arguments, l.field.Switches = parsed.Instruction, parsed.Arguments, parsed.Switches
+		}
+		links[i] = l.link
+	}
+	setHyperlinkText(links, []rune(e.document.Body))
+	e.document.hyperlinks = links
+}
+
+// mainRelationships returns the relationships of the main document part
+func (e *OpenOfficeExtractor) mainRelationships() map[string]Relationship {
+	return e.relationshipsOf(e.mainPart)
+}
+
+// relationshipsOf returns the relationships of a part
+func (e *OpenOfficeExtractor) relationshipsOf(part string) map[string]Relationship {
+	return e.partRelationships[path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")]
+}
+
+// relationshipTarget returns the name of the part a relationship of a part
+// points to. Targets are relative to the part, unless they are absolute.
+func relationshipTarget(part string, rel Relationship) string {
+	if strings.HasPrefix(rel.Target, "/") {
+		return strings.TrimPrefix(rel.Target, "/")
+	}
+	return path.Join(path.Dir(part), rel.Target)
+}
+
+// readPart reads the contents of a part, within the limits
+func (e *OpenOfficeExtractor) readPart(name string) ([]byte, error) {
+	f := e.files[name]
+	if f == nil {
+		return nil, &ExtractError{Part: name, Offset: -1, Err: errMissingPart}
+	}
+	rc, err := e.limits.openPart(name, f)
+	if err != nil {
+		return nil, partError(name, -1, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, partError(name, -1, err)
+}
+
+// addImage records a picture of the body, unless it is the preview of an
+// embedded object, a repeat within alternate content, or hidden by the
+// revision mode. A picture in a textbox is anchored where the textbox is.
+func (e *OpenOfficeExtractor) addImage(relID, altText string) {
+	if relID == "" || !e.inDocument || e.objects > 0 || e.fallbacks > 0 || e.hiddenRevisions > 0 {
+		return
+	}
+	offset := e.story.offset()
+	if len(e.storyStack) > 0 {
+		offset = e.storyStack[0].offset()
+	}
+	e.images = append(e.images, docxImage{relID: relID, altText: altText, offset: offset})
+}
+
+// buildImages reads the media parts of the pictures in the body. Their
+// targets are relative to the main document part, and their content types
+// are given for the part or by extension in the content types part.
+func (e *OpenOfficeExtractor) buildImages() {
+	rels := e.mainRelationships()
+	media := make(map[string][]byte)
+	for _, img := range e.images {
+		if data, name, ok := e.binDataImage(img.relID); ok {
+			e.document.images = append(e.document.images, &Image{
+				Name:        name,
+				ContentType: odfImageTypes[strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))],
+				Data:        data,
+				AltText:     img.altText,
+				Offset:      img.offset,
+			})
+			continue
+		}
+		rel, ok := rels[img.relID]
+		if !ok {
+			continue
+		}
+		name := relationshipTarget(e.mainPart, rel)
+		data, ok := media[name]
+		if !ok {
+			var err error
+			if data, err = e.readPart(name); err != nil {
+				continue
+			}
+			media[name] = data
+		}
+		contentType, ok := e.overrides[name]
+		if !ok {
+			contentType = e.defaults[strings.TrimPrefix(path.Ext(name), ".")]
+		}
+		e.document.images = append(e.document.images, &Image{
+			Name:        path.Base(name),
+			ContentType: contentType,
+			Data:        data,
+			AltText:     img.altText,
+			Offset:      img.offset,
+		})
+	}
+}
+
+// buildEmbeddedObjects reads the embedded object parts. Objects are listed in
+// the order they are used, followed by the parts of the embeddings folder
+// that are not used. Objects stored as compound files are read for their
+// ProgID and any file they package, and are kept as they are when they cannot
+// be read.
+func (e *OpenOfficeExtractor) buildEmbeddedObjects() {
+	var names []string
+	progIDs := make(map[string]string)
+	for _, ref := range e.objectRefs {
+		rel, ok := e.relationshipsOf(ref.part)[ref.relID]
+		if !ok {
+			continue
+		}
+		name := relationshipTarget(ref.part, rel)
+		if _, seen := progIDs[name]; !seen {
+			names = append(names, name)
+		}
+		progIDs[name] = ref.progID
+	}
+	var unused []string
+	embeddings := path.Join(path.Dir(e.mainPart), "embeddings") + "/"
+	for name := range e.files {
+		if _, seen := progIDs[name]; !seen && strings.HasPrefix(name, embeddings) && !strings.HasSuffix(name, "/") {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+
+	for _, name := range append(names, unused...) {
+		data, err := e.readPart(name)
+		if err != nil {
+			continue
+		}
+		object := &EmbeddedObject{ProgID: progIDs[name], Name: path.Base(name), Data: data}
+		if bytes.HasPrefix(data, compoundSignature) {
+			if entries, err := readCompoundFile(e.limits, bytes.NewReader(data)); err == nil {
+				object, _ = compoundObject(object.Name, object.ProgID, entries, data)
+			}
+		}
+		e.document.objects = append(e.document.objects, object)
+	}
+}
+
+// attrValue returns the value of the attribute with the given local name, or
+// an empty string when the element does not have it
+func attrValue(se xml.StartElement, local string) string {
+	for _, attr := range se.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
 	}
-	return result
+	return ""
 }
@@ -0,0 +1,83 @@
+package word_extractor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Heading is a heading paragraph in the outline of the document, with the
+// headings of its subsections
+type Heading struct {
+	// Level is the outline level of the heading, from 1 to 9
+	Level int
+	// Text is the heading text, and Style the name of its paragraph style
+	Text  string
+	Style string
+	// Offset is the character (rune) offset of the heading within Body, and
+	// Length the length of its text in characters
+	Offset int
+	Length int
+	// End is the offset within Body where the section under the heading ends:
+	// the start of the next heading at the same or a higher level, or the end
+	// of the body
+	End      int
+	Children []*Heading
+}
+
+// Outline returns the headings of the body as a tree, each heading holding the
+// headings of its subsections. Headings are the paragraphs with an outline
+// level, set directly or by their style, such as the built-in "heading 1" to
+// "heading 9" styles.
+func (d *Document) Outline() []*Heading {
+	var roots []*Heading
+	var open []*Heading
+	end := len([]rune(d.Body))
+	for _, p := range d.Paragraphs() {
+		if p.Level == 0 {
+			continue
+		}
+		heading := &Heading{
+			Level:  p.Level,
+			Text:   p.Text,
+			Style:  p.Style,
+			Offset: p.Offset,
+			Length: len([]rune(p.Text)),
+			End:    end,
+		}
+		for len(open) > 0 && open[len(open)-1].Level >= heading.Level {
+			open[len(open)-1].End = heading.Offset
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			roots = append(roots, heading)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, heading)
+		}
+		open = append(open, heading)
+	}
+	return roots
+}
+
+// outlineLevel turns a stored outline level, from 0 for level 1 to 8 for
+// level 9, into a heading level. Level 9 and above mark body text.
+func outlineLevel(stored int) int {
+	if stored < 0 || stored > 8 {
+		return 0
+	}
+	return stored + 1
+}
+
+// headingStyleLevel returns the level of a built-in heading style from its
+// name, such as "heading 2", or zero for any other style
+func headingStyleLevel(name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "heading") {
+		return 0
+	}
+	level, err := strconv.Atoi(strings.TrimSpace(name[len("heading"):]))
+	if err != nil || level < 1 || level > 9 {
+		return 0
+	}
+	return level
+}
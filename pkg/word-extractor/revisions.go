@@ -0,0 +1,85 @@
+package word_extractor
+
+import (
+	"time"
+)
+
+// RevisionMode selects how tracked changes appear in the extracted text
+type RevisionMode int
+
+const (
+	// AcceptRevisions shows the document with every tracked change accepted.
+	// Insertions are kept and deletions are dropped. This is the default.
+	AcceptRevisions RevisionMode = iota
+	// RejectRevisions shows the original document, with every tracked change
+	// rejected. Deletions are kept and insertions are dropped.
+	RejectRevisions
+	// ShowRevisions keeps both insertions and deletions, marked up inline as
+	// {+inserted text+} and [-deleted text-]
+	ShowRevisions
+)
+
+// Markers written around tracked changes by ShowRevisions
+const (
+	insertionStart = "{+"
+	insertionEnd   = "+}"
+	deletionStart  = "[-"
+	deletionEnd    = "-]"
+)
+
+// RevisionType is the kind of a tracked change
+type RevisionType int
+
+const (
+	Insertion RevisionType = iota
+	Deletion
+)
+
+func (t RevisionType) String() string {
+	if t == Deletion {
+		return "deletion"
+	}
+	return "insertion"
+}
+
+// Revision is a tracked change in the document body
+type Revision struct {
+	Type   RevisionType
+	Author string
+	Date   time.Time
+	// Text is the inserted or deleted text, whether or not it is shown in Body
+	Text string
+	// Offset is the character (rune) offset within Body where the revision
+	// starts, or would start when its text is not shown
+	Offset int
+}
+
+// shows tells whether text of the given revision type is kept in this mode
+func (m RevisionMode) shows(t RevisionType) bool {
+	switch m {
+	case RejectRevisions:
+		return t == Deletion
+	case ShowRevisions:
+		return true
+	default:
+		return t == Insertion
+	}
+}
+
+// markers returns the text written before and after a revision of the given
+// type, which is empty unless revisions are shown inline
+func (m RevisionMode) markers(t RevisionType) (string, string) {
+	if m != ShowRevisions {
+		return "", ""
+	}
+	if t == Deletion {
+		return deletionStart, deletionEnd
+	}
+	return insertionStart, insertionEnd
+}
+
+// sameRevision tells whether two revisions come from the same change, with the
+// same type, author and date
+func sameRevision(a, b Revision) bool {
+	return a.Type == b.Type && a.Author == b.Author && a.Date.Equal(b.Date)
+}
@@ -0,0 +1,1433 @@
+package word_extractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// RtfExtractor extracts Rich Text Format documents, which Word reads and
+// writes as an interchange format and which are often saved as .doc files
+type RtfExtractor struct {
+	// Options configures how documents are extracted
+	Options ExtractOptions
+
+	document *Document
+	states   []*rtfState
+	limits   *limiter
+
+	// pending holds text bytes that are not decoded yet, skip the number of
+	// characters still to skip after a Unicode character, and surrogate the
+	// first half of a surrogate pair
+	pending   []byte
+	skip      int
+	surrogate rune
+	ignorable bool
+
+	codePage    int
+	defaultFont int
+	fontDef     int
+	fonts       map[int]int
+	styles      map[int]*rtfStyle
+	style       *rtfStyle
+	authors     []string
+	property    string
+	numbering   *listNumbering
+
+	body            *rtfStory
+	footnotes       strings.Builder
+	endnotes        strings.Builder
+	headers         strings.Builder
+	footers         strings.Builder
+	annotations     strings.Builder
+	textboxes       []string
+	headerTextboxes []string
+
+	comments      []*rtfComment
+	commentStarts map[string]int
+	commentEnds   map[string]int
+	atnID         string
+	atnAuthor     string
+
+	fieldCount int
+	fieldDepth int
+
+	row  []cellFormat
+	cell cellFormat
+	left int
+}
+
+// rtfDestination tells what becomes of the text of a group
+type rtfDestination int
+
+const (
+	// rtfText is written to the story of the group
+	rtfText rtfDestination = iota
+	// rtfDiscard is dropped, though the control words of the group still apply
+	rtfDiscard
+	// rtfSkip drops the group with everything in it
+	rtfSkip
+	// rtfValue is collected and handed to the group when it closes
+	rtfValue
+	// rtfData is the hexadecimal data of a picture or object
+	rtfData
+	// rtfInstruction is the instruction of a field
+	rtfInstruction
+	// rtfFontTable and rtfStylesheet define fonts and styles, and
+	// rtfStyleName is the name of a style
+	rtfFontTable
+	rtfStylesheet
+	rtfStyleName
+)
+
+// rtfState is the state of a group. Each group starts with the state of the
+// group it is in, and the state is restored when the group closes.
+type rtfState struct {
+	dest  rtfDestination
+	story *rtfStory
+	// main is set in the body and its textboxes, header in headers and
+	// footers, object in embedded objects and info in document properties
+	main, header, object, info bool
+
+	uc   int
+	font int
+	para rtfParagraph
+
+	// Tracked changes: the authors index the revision table, and the dates
+	// are packed as DTTM values
+	revised, deleted        bool
+	revAuthor, revAuthorDel int
+	revDate, revDateDel     uint32
+
+	value    *strings.Builder
+	data     *rtfHexData
+	field    *rtfField
+	note     *rtfNote
+	comment  *rtfComment
+	shape    *rtfShape
+	prop     *rtfShapeProperty
+	picture  *rtfPicture
+	embedded *rtfObject
+	date     *rtfDate
+
+	// upr holds the ANSI and Unicode versions of the same text, of which
+	// only the Unicode version is kept
+	upr, uprSkipped bool
+
+	// close is called when the group closes
+	close func(s *rtfState)
+}
+
+// rtfParagraph holds the paragraph properties of a group. itap is the table
+// nesting level, style the style number and level the outline level, or -1
+// when it is not set. list and ilvl are the list and level of a list item.
+type rtfParagraph struct {
+	inTable bool
+	itap    int
+	style   int
+	level   int
+	list    int
+	ilvl    int
+}
+
+// depth returns the number of tables the paragraph is nested in
+func (p rtfParagraph) depth() int {
+	if !p.inTable {
+		return 0
+	}
+	if p.itap < 1 {
+		return 1
+	}
+	return p.itap
+}
+
+// revisionMark returns the tracked change of the text of the group, or nil
+// when it is not revised
+func (s *rtfState) revisionMark() *RevisionMark {
+	if !s.revised && !s.deleted {
+		return nil
+	}
+	return &RevisionMark{
+		Inserted:     s.revised,
+		Deleted:      s.deleted,
+		InsertAuthor: s.revAuthor,
+		InsertDate:   parseDTTM(s.revDate),
+		DeleteAuthor: s.revAuthorDel,
+		DeleteDate:   parseDTTM(s.revDateDel),
+	}
+}
+
+// rtfStory is a story being written, with the tracked changes it passes
+// through. started is set once the current paragraph has text, and label
+// holds its list label.
+type rtfStory struct {
+	*storyBuilder
+	tracker *revisionTracker
+	started bool
+	label   string
+}
+
+// rtfStyle is a paragraph style from the stylesheet, with the style it is
+// based on and its outline level, which are -1 when not set
+type rtfStyle struct {
+	number    int
+	name      strings.Builder
+	basedOn   int
+	level     int
+	paragraph bool
+}
+
+// rtfField is a field that is being read. visible is set when its result
+// is shown, and main when it is a field of the body.
+type rtfField struct {
+	instruction strings.Builder
+	visible     bool
+	main        bool
+	hasResult   bool
+	resultStart int
+	depth       int
+	begin       int
+}
+
+// rtfNote is a footnote or endnote that is being read
+type rtfNote struct {
+	endnote bool
+}
+
+// rtfComment is an annotation, with the offset of its reference in the body
+type rtfComment struct {
+	comment *Comment
+	ref     string
+	offset  int
+}
+
+// rtfShape is a shape or picture, with the pictures it draws, which are
+// given its description once it is read
+type rtfShape struct {
+	altText string
+	images  []*Image
+}
+
+// rtfShapeProperty is a named property of a shape
+type rtfShapeProperty struct {
+	name string
+}
+
+// rtfPicture is a picture that is being read
+type rtfPicture struct {
+	contentType string
+	dib         bool
+}
+
+// rtfObject is an embedded object that is being read
+type rtfObject struct {
+	class string
+	data  []byte
+}
+
+// rtfDate is a date of the document properties, written as separate values
+type rtfDate struct {
+	year, month, day, hour, minute, second int
+}
+
+// rtfHexData decodes the hexadecimal data of a picture or object
+type rtfHexData struct {
+	bytes []byte
+	high  byte
+	half  bool
+}
+
+func (d *rtfHexData) writeHex(c byte) {
+	var value byte
+	switch {
+	case c >= '0' && c <= '9':
+		value = c - '0'
+	case c >= 'a' && c <= 'f':
+		value = c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		value = c - 'A' + 10
+	default:
+		return
+	}
+	if d.half {
+		d.bytes = append(d.bytes, d.high<<4|value)
+	} else {
+		d.high = value
+	}
+	d.half = !d.half
+}
+
+// rtfSpecialChars gives the text of the control words that stand for a
+// character
+var rtfSpecialChars = map[string]string{
+	"tab":       "\t",
+	"line":      "\n",
+	"page":      "\n",
+	"emdash":    "\u2014",
+	"endash":    "\u2013",
+	"emspace":   "\u2003",
+	"enspace":   "\u2002",
+	"qmspace":   "\u2005",
+	"bullet":    "\u2022",
+	"lquote":    "\u2018",
+	"rquote":    "\u2019",
+	"ldblquote": "\u201C",
+	"rdblquote": "\u201D",
+	"zwj":       "\u200D",
+	"zwnj":      "\u200C",
+	"zwbo":      "\u200B",
+	"ltrmark":   "\u200E",
+	"rtlmark":   "\u200F",
+}
+
+// rtfSkippedDestinations lists the destinations whose content is not text of
+// the document. Destinations marked as ignorable are skipped too when they
+// are not known.
+var rtfSkippedDestinations = map[string]bool{
+	"colortbl":          true,
+	"filetbl":           true,
+	"listtable":         true,
+	"listoverridetable": true,
+	"rsidtbl":           true,
+	"bkmkstart":         true,
+	"bkmkend":           true,
+	"blipuid":           true,
+	"nonshppict":        true,
+	"shprslt":           true,
+	"nonesttables":      true,
+	"pn":                true,
+	"pntxta":            true,
+	"pntxtb":            true,
+	"xe":                true,
+	"tc":                true,
+	"txe":               true,
+	"rxe":               true,
+	"ftnsep":            true,
+	"ftnsepc":           true,
+	"ftncn":             true,
+	"aftnsep":           true,
+	"aftnsepc":          true,
+	"aftncn":            true,
+	"printim":           true,
+	"buptim":            true,
+	"atnicn":            true,
+}
+
+// NewRtfExtractor creates a new instance of RtfExtractor
+func NewRtfExtractor() *RtfExtractor {
+	return &RtfExtractor{}
+}
+
+// Extract implements the DocumentExtractor interface
+func (r *RtfExtractor) Extract(reader io.ReadSeeker) (*Document, error) {
+	return r.ExtractContext(context.Background(), reader)
+}
+
+// ExtractContext extracts a document like Extract, and stops with the error
+// of the context once it is done
+func (r *RtfExtractor) ExtractContext(ctx context.Context, reader io.ReadSeeker) (*Document, error) {
+	return r.extractLimited(newLimiter(ctx, r.Options.Limits), reader)
+}
+
+// extractLimited extracts a document within the limiter of the extraction it
+// is part of
+func (r *RtfExtractor) extractLimited(limits *limiter, reader io.ReadSeeker) (*Document, error) {
+	r.limits = limits
+	if err := r.limits.checkInput(reader); err != nil {
+		return nil, err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(`{\rtf`)) {
+		return nil, fmt.Errorf("%w: missing RTF header", ErrUnsupportedFormat)
+	}
+
+	r.document = NewDocument()
+	r.document.Format = FormatRtf
+	r.pending, r.skip, r.surrogate, r.ignorable = nil, 0, 0, false
+	r.codePage, r.defaultFont, r.fontDef = 1252, 0, 0
+	r.fonts, r.styles, r.style = make(map[int]int), make(map[int]*rtfStyle), nil
+	r.authors, r.property, r.numbering = nil, "", newListNumbering()
+	r.footnotes.Reset()
+	r.endnotes.Reset()
+	r.headers.Reset()
+	r.footers.Reset()
+	r.annotations.Reset()
+	r.textboxes, r.headerTextboxes = nil, nil
+	r.comments, r.commentStarts, r.commentEnds = nil, make(map[string]int), make(map[string]int)
+	r.atnID, r.atnAuthor = "", ""
+	r.fieldCount, r.fieldDepth = 0, 0
+	r.row, r.cell, r.left = nil, cellFormat{}, 0
+
+	r.body = r.newStory()
+	r.states = []*rtfState{{dest: rtfText, story: r.body, main: true, uc: 1, para: rtfParagraph{level: -1}}}
+	if err := r.parse(data); err != nil {
+		return nil, err
+	}
+	r.finish()
+	if err := r.limits.checkOutput(r.document); err != nil {
+		return nil, err
+	}
+	return r.document, nil
+}
+
+func (r *RtfExtractor) newStory() *rtfStory {
+	return &rtfStory{
+		storyBuilder: newStoryBuilder(r.limits),
+		tracker:      &revisionTracker{mode: r.Options.Revisions, authors: r.authors},
+	}
+}
+
+func (r *RtfExtractor) state() *rtfState {
+	return r.states[len(r.states)-1]
+}
+
+// parse reads the groups, control words and text of a document. The
+// deadline and how deep the groups are nested are checked as each group
+// opens, as each group holds a copy of the state of its parent.
+func (r *RtfExtractor) parse(data []byte) error {
+	for i := 0; i < len(data); {
+		switch c := data[i]; c {
+		case '{':
+			if err := r.limits.check(); err != nil {
+				return err
+			}
+			if err := r.limits.checkNesting(len(r.states)); err != nil {
+				return err
+			}
+			r.push()
+			i++
+		case '}':
+			r.pop()
+			i++
+		case '\\':
+			i = r.readControl(data, i+1)
+		case '\r', '\n':
+			i++
+		default:
+			r.writeByte(c, false)
+			i++
+		}
+	}
+	return nil
+}
+
+// readControl reads the control word or symbol that starts at i, after its
+// backslash, and returns the position after it
+func (r *RtfExtractor) readControl(data []byte, i int) int {
+	if i >= len(data) {
+		return i
+	}
+	isLetter := func(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	if !isLetter(data[i]) {
+		switch c := data[i]; c {
+		case '\'':
+			var hex rtfHexData
+			for j := i + 1; j < i+3 && j < len(data); j++ {
+				hex.writeHex(data[j])
+			}
+			if len(hex.bytes) == 1 {
+				r.writeByte(hex.bytes[0], true)
+			}
+			return min(i+3, len(data))
+		case '\\', '{', '}':
+			r.writeByte(c, true)
+		case '\r', '\n':
+			r.controlWord("par", 0, false)
+		case '~':
+			r.special(" ")
+		case '_':
+			r.special("‑")
+		case '-':
+			r.special("")
+		case '*':
+			r.flush()
+			r.ignorable = true
+		}
+		return i + 1
+	}
+
+	start := i
+	for i < len(data) && isLetter(data[i]) && i-start < 32 {
+		i++
+	}
+	word := string(data[start:i])
+	param, hasParam := 0, false
+	negative := i+1 < len(data) && data[i] == '-' && isDigit(data[i+1])
+	if negative {
+		i++
+	}
+	digits := i
+	for i < len(data) && isDigit(data[i]) && i-digits < 10 {
+		i++
+	}
+	if i > digits {
+		value, _ := strconv.ParseInt(string(data[digits:i]), 10, 64)
+		if negative {
+			value = -value
+		}
+		param, hasParam = int(value), true
+	}
+	if i < len(data) && data[i] == ' ' {
+		i++
+	}
+
+	// Binary data follows its control word directly
+	if word == "bin" && hasParam {
+		end := i + max(param, 0)
+		if end > len(data) {
+			end = len(data)
+		}
+		r.flush()
+		if state := r.state(); state.dest == rtfData {
+			state.data.bytes = append(state.data.bytes, data[i:end]...)
+		}
+		return end
+	}
+	r.controlWord(word, param, hasParam)
+	return i
+}
+
+// writeByte adds a byte of text, which is decoded with the code page of the
+// current font once the text ends. Escaped bytes are never picture data.
+func (r *RtfExtractor) writeByte(c byte, escaped bool) {
+	state := r.state()
+	switch state.dest {
+	case rtfSkip:
+		return
+	case rtfData:
+		if !escaped {
+			state.data.writeHex(c)
+		}
+		return
+	}
+	if r.skip > 0 {
+		r.skip--
+		return
+	}
+	r.pending = append(r.pending, c)
+}
+
+// special writes the text of a control symbol
+func (r *RtfExtractor) special(text string) {
+	r.flush()
+	if r.skip > 0 {
+		r.skip--
+		return
+	}
+	if text != "" {
+		r.text(text)
+	}
+}
+
+// flush decodes the pending text bytes
+func (r *RtfExtractor) flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+	state := r.state()
+	codePage := r.codePage
+	if fontCodePage := r.fonts[state.font]; fontCodePage != 0 {
+		codePage = fontCodePage
+	}
+	text := decodeCodePage(r.pending, codePage)
+	r.pending = r.pending[:0]
+	r.text(text)
+}
+
+// text hands decoded text to the destination of the current group
+func (r *RtfExtractor) text(text string) {
+	state := r.state()
+	switch state.dest {
+	case rtfText:
+		r.write(state, text)
+	case rtfValue:
+		state.value.WriteString(text)
+	case rtfInstruction:
+		if state.field != nil {
+			state.field.instruction.WriteString(text)
+		}
+	case rtfStyleName:
+		if r.style != nil {
+			r.style.name.WriteString(text)
+		}
+	}
+}
+
+func (r *RtfExtractor) push() {
+	r.flush()
+	r.skip, r.ignorable = 0, false
+	parent := r.state()
+	child := *parent
+	child.close = nil
+	child.upr, child.uprSkipped = false, false
+	switch {
+	case parent.dest == rtfSkip:
+	case parent.upr && !parent.uprSkipped:
+		// The first group of an upr holds the ANSI version of the text
+		parent.uprSkipped = true
+		child.dest = rtfSkip
+	case parent.dest == rtfStylesheet:
+		style := &rtfStyle{basedOn: -1, level: -1, paragraph: true}
+		r.style = style
+		child.dest = rtfStyleName
+		child.close = func(*rtfState) {
+			if style.paragraph {
+				r.styles[style.number] = style
+			}
+		}
+	}
+	r.states = append(r.states, &child)
+}
+
+func (r *RtfExtractor) pop() {
+	r.flush()
+	r.skip, r.ignorable = 0, false
+	if len(r.states) <= 1 {
+		return
+	}
+	state := r.state()
+	r.states = r.states[:len(r.states)-1]
+	if state.close != nil && state.dest != rtfSkip {
+		state.close(state)
+	}
+}
+
+// collect makes the current group collect its text and hand it over when
+// the group closes
+func (r *RtfExtractor) collect(state *rtfState, done func(s *rtfState, value string)) {
+	state.dest = rtfValue
+	state.value = &strings.Builder{}
+	state.close = func(s *rtfState) {
+		done(s, strings.TrimSpace(s.value.String()))
+	}
+}
+
+// newStoryState starts a story of its own in the current group
+func (r *RtfExtractor) newStoryState(state *rtfState) *rtfStory {
+	story := r.newStory()
+	state.story, state.dest = story, rtfText
+	state.para = rtfParagraph{level: -1}
+	state.field = nil
+	return story
+}
+
+// controlWord handles a control word with its parameter
+func (r *RtfExtractor) controlWord(word string, param int, hasParam bool) {
+	state := r.state()
+	if state.dest == rtfSkip {
+		r.ignorable = false
+		return
+	}
+	r.flush()
+	if r.skip > 0 {
+		r.skip--
+		return
+	}
+	if r.ignorable {
+		r.ignorable = false
+		if !r.destination(state, word) {
+			state.dest = rtfSkip
+		}
+		return
+	}
+	if r.destination(state, word) {
+		return
+	}
+	on := !hasParam || param != 0
+
+	switch word {
+	case "ansi":
+		r.codePage = 1252
+	case "mac":
+		r.codePage = 10000
+	case "pc":
+		r.codePage = 437
+	case "pca":
+		r.codePage = 850
+	case "ansicpg":
+		r.codePage = param
+	case "deff":
+		r.defaultFont, state.font = param, param
+	case "f":
+		if state.dest == rtfFontTable {
+			r.fontDef = param
+		} else {
+			state.font = param
+		}
+	case "fcharset":
+		if state.dest == rtfFontTable {
+			r.fonts[r.fontDef] = charsetCodePages[param]
+		}
+	case "cpg":
+		if state.dest == rtfFontTable && param != 0 {
+			r.fonts[r.fontDef] = param
+		}
+	case "uc":
+		state.uc = max(param, 0)
+	case "u":
+		r.unicode(state, param)
+	case "plain":
+		state.font = r.defaultFont
+		state.revised, state.deleted = false, false
+
+	case "pard":
+		state.para = rtfParagraph{level: -1}
+	case "intbl":
+		state.para.inTable = on
+	case "itap":
+		state.para.itap = param
+	case "s":
+		if state.dest == rtfStyleName && r.style != nil {
+			r.style.number = param
+		} else {
+			state.para.style = param
+		}
+	case "cs", "ds", "ts":
+		if state.dest == rtfStyleName && r.style != nil {
+			r.style.paragraph = false
+		}
+	case "sbasedon":
+		if state.dest == rtfStyleName && r.style != nil {
+			r.style.basedOn = param
+		}
+	case "outlinelevel":
+		if state.dest == rtfStyleName && r.style != nil {
+			r.style.level = param
+		} else {
+			state.para.level = param
+		}
+	case "ls":
+		state.para.list = param
+	case "ilvl":
+		state.para.ilvl = param
+
+	case "revised":
+		state.revised = on
+	case "deleted":
+		state.deleted = on
+	case "revauth":
+		state.revAuthor = param
+	case "revauthdel":
+		state.revAuthorDel = param
+	case "revdttm":
+		state.revDate = uint32(param)
+	case "revdttmdel":
+		state.revDateDel = uint32(param)
+
+	case "par", "sect":
+		if state.dest == rtfText {
+			r.endParagraph(state)
+		}
+	case "cell", "nestcell":
+		if state.dest == rtfText {
+			r.endCell(state)
+		}
+	case "row", "nestrow":
+		if state.dest == rtfText || state.dest == rtfDiscard {
+			r.endRow(state)
+		}
+	case "trowd":
+		r.row, r.cell, r.left = nil, cellFormat{}, 0
+	case "trleft":
+		r.left = param
+	case "clmrg":
+		r.cell.merged = true
+	case "clvmgf":
+		r.cell.vMerge = MergeRestart
+	case "clvmrg":
+		r.cell.vMerge = MergeContinue
+	case "cellx":
+		r.cell.left = r.left
+		if n := len(r.row); n > 0 {
+			r.cell.left = r.row[n-1].right
+		}
+		r.cell.right = param
+		r.row = append(r.row, r.cell)
+		r.cell = cellFormat{}
+
+	case "ftnalt":
+		if state.note != nil {
+			state.note.endnote = true
+		}
+
+	case "emfblip", "pngblip", "jpegblip", "wmetafile", "macpict", "dibitmap":
+		if state.picture != nil {
+			state.picture.contentType = map[string]string{
+				"emfblip":   contentTypeEMF,
+				"pngblip":   contentTypePNG,
+				"jpegblip":  contentTypeJPEG,
+				"wmetafile": contentTypeWMF,
+				"macpict":   contentTypePICT,
+				"dibitmap":  contentTypeBMP,
+			}[word]
+			state.picture.dib = word == "dibitmap"
+		}
+
+	case "version", "nofpages", "nofwords", "nofchars":
+		if state.info {
+			meta := &r.document.Metadata
+			switch word {
+			case "version":
+				meta.Revision = strconv.Itoa(param)
+			case "nofpages":
+				meta.Pages = param
+			case "nofwords":
+				meta.Words = param
+			case "nofchars":
+				meta.Characters = param
+			}
+		}
+	case "yr", "mo", "dy", "hr", "min", "sec":
+		if state.date != nil {
+			switch word {
+			case "yr":
+				state.date.year = param
+			case "mo":
+				state.date.month = param
+			case "dy":
+				state.date.day = param
+			case "hr":
+				state.date.hour = param
+			case "min":
+				state.date.minute = param
+			case "sec":
+				state.date.second = param
+			}
+		}
+
+	default:
+		if text, ok := rtfSpecialChars[word]; ok {
+			r.text(text)
+		}
+	}
+}
+
+// unicode writes a Unicode character, given as a signed 16-bit value, and
+// skips the characters written for readers that do not know Unicode
+func (r *RtfExtractor) unicode(state *rtfState, param int) {
+	if param < 0 {
+		param += 0x10000
+	}
+	c := rune(param)
+	switch {
+	case c >= 0xD800 && c < 0xDC00:
+		r.surrogate = c
+	case utf16.IsSurrogate(c):
+		r.text(string(utf16.DecodeRune(r.surrogate, c)))
+		r.surrogate = 0
+	default:
+		r.text(string(c))
+	}
+	r.skip = state.uc
+}
+
+// destination starts a destination in the current group, returning false
+// when the control word is not one
+func (r *RtfExtractor) destination(state *rtfState, word string) bool {
+	if rtfSkippedDestinations[word] {
+		state.dest = rtfSkip
+		return true
+	}
+	meta := &r.document.Metadata
+
+	switch word {
+	case "fonttbl":
+		state.dest = rtfFontTable
+	case "stylesheet":
+		state.dest = rtfStylesheet
+	case "revtbl":
+		// Authors are listed in order, each ended by a semicolon
+		state.dest = rtfValue
+		state.value = &strings.Builder{}
+		state.close = func(s *rtfState) {
+			r.authors = nil
+			for _, author := range strings.Split(s.value.String(), ";") {
+				r.authors = append(r.authors, strings.TrimSpace(author))
+			}
+			r.body.tracker.authors = r.authors
+		}
+	case "upr":
+		state.upr = true
+	case "ud", "shppict", "result":
+	case "shpinst", "picprop", "nesttableprops":
+		state.dest = rtfDiscard
+	case "sp":
+		state.prop = &rtfShapeProperty{}
+
+	case "info":
+		state.dest, state.info = rtfDiscard, true
+	case "title", "subject", "author", "keywords", "doccomm", "operator", "category", "company":
+		r.collect(state, func(_ *rtfState, value string) {
+			setInfoProperty(meta, word, value)
+		})
+	case "creatim", "revtim":
+		state.dest, state.date = rtfDiscard, &rtfDate{}
+		state.close = func(s *rtfState) {
+			if s.date.year == 0 {
+				return
+			}
+			date := time.Date(s.date.year, time.Month(s.date.month), s.date.day, s.date.hour, s.date.minute, s.date.second, 0, time.UTC)
+			if word == "creatim" {
+				meta.Created = date
+			} else {
+				meta.Modified = date
+			}
+		}
+	case "generator":
+		r.collect(state, func(_ *rtfState, value string) {
+			meta.Application = strings.TrimSpace(strings.TrimSuffix(value, ";"))
+		})
+	case "template":
+		r.collect(state, func(_ *rtfState, value string) {
+			meta.Template = path.Base(strings.ReplaceAll(value, `\`, "/"))
+		})
+	case "userprops":
+		state.dest = rtfDiscard
+	case "propname":
+		r.collect(state, func(_ *rtfState, value string) {
+			r.property = value
+		})
+	case "staticval":
+		r.collect(state, func(_ *rtfState, value string) {
+			if r.property != "" {
+				meta.setCustom(r.property, value)
+			}
+		})
+
+	case "header", "headerl", "headerr", "headerf", "footer", "footerl", "footerr", "footerf":
+		story := r.newStoryState(state)
+		state.main, state.header = false, true
+		state.close = func(*rtfState) {
+			r.finishStory(story)
+			if strings.HasPrefix(word, "footer") {
+				r.footers.WriteString(story.String())
+			} else {
+				r.headers.WriteString(story.String())
+			}
+		}
+	case "footnote":
+		r.startNote(state)
+	case "annotation":
+		r.startAnnotation(state)
+	case "atnid", "atnauthor":
+		r.collect(state, func(_ *rtfState, value string) {
+			if word == "atnid" {
+				r.atnID = value
+			} else {
+				r.atnAuthor = value
+			}
+		})
+	case "atnref":
+		r.collect(state, func(s *rtfState, value string) {
+			if s.comment != nil {
+				s.comment.ref = value
+			}
+		})
+	case "atndate":
+		r.collect(state, func(s *rtfState, value string) {
+			if date, err := strconv.ParseInt(value, 10, 64); err == nil && s.comment != nil {
+				s.comment.comment.Date = parseDTTM(uint32(date))
+			}
+		})
+	case "atrfstart", "atrfend":
+		offset := -1
+		if state.story == r.body {
+			offset = r.body.offset()
+		}
+		r.collect(state, func(_ *rtfState, value string) {
+			if offset < 0 {
+				return
+			}
+			if word == "atrfstart" {
+				r.commentStarts[value] = offset
+			} else {
+				r.commentEnds[value] = offset
+			}
+		})
+
+	case "field":
+		r.startField(state)
+	case "fldinst":
+		state.dest = rtfInstruction
+	case "fldrslt":
+		field := state.field
+		if field == nil || !field.visible {
+			state.dest = rtfDiscard
+			break
+		}
+		state.dest = rtfText
+		field.hasResult = true
+		if field.main {
+			r.beginParagraph(state)
+			field.resultStart = r.body.offset()
+		}
+
+	case "listtext", "pntext":
+		r.collect(state, func(s *rtfState, value string) {
+			if s.story != nil {
+				s.story.label = value
+			}
+		})
+
+	case "shp":
+		shape := &rtfShape{}
+		state.shape = shape
+		state.close = func(*rtfState) {
+			shape.describe()
+		}
+	case "sn":
+		r.collect(state, func(s *rtfState, value string) {
+			if s.prop != nil {
+				s.prop.name = value
+			}
+		})
+	case "sv":
+		r.collect(state, func(s *rtfState, value string) {
+			if s.prop != nil && s.prop.name == "wzDescription" && s.shape != nil {
+				s.shape.altText = value
+			}
+		})
+	case "shptxt":
+		story := r.newStoryState(state)
+		state.close = func(s *rtfState) {
+			r.finishStory(story)
+			if text := story.String(); text != "" && s.header {
+				r.headerTextboxes = append(r.headerTextboxes, text)
+			} else if text != "" {
+				r.textboxes = append(r.textboxes, text)
+			}
+		}
+	case "pict":
+		r.startPicture(state)
+
+	case "object":
+		object := &rtfObject{}
+		state.object, state.embedded = true, object
+		state.close = func(*rtfState) {
+			r.addObject(object)
+		}
+	case "objclass":
+		r.collect(state, func(s *rtfState, value string) {
+			if s.embedded != nil {
+				s.embedded.class = value
+			}
+		})
+	case "objdata":
+		state.dest, state.data = rtfData, &rtfHexData{}
+		state.close = func(s *rtfState) {
+			if s.embedded != nil {
+				s.embedded.data = s.data.bytes
+			}
+		}
+
+	default:
+		return false
+	}
+	return true
+}
+
+// setInfoProperty sets a document property from the information group
+func setInfoProperty(meta *Metadata, name, value string) {
+	switch name {
+	case "title":
+		meta.Title = value
+	case "subject":
+		meta.Subject = value
+	case "author":
+		meta.Author = value
+	case "keywords":
+		meta.Keywords = value
+	case "doccomm":
+		meta.Comments = value
+	case "operator":
+		meta.LastModifiedBy = value
+	case "category":
+		meta.Category = value
+	case "company":
+		meta.Company = value
+	}
+}
+
+// beginParagraph opens the tables and cell that hold the current paragraph
+// once it has content
+func (r *RtfExtractor) beginParagraph(state *rtfState) {
+	story := state.story
+	if story == nil || story.started {
+		return
+	}
+	depth := state.para.depth()
+	story.setDepth(depth)
+	if depth > 0 {
+		story.ensureCell()
+	}
+	story.startParagraph()
+	story.started = true
+}
+
+// write writes text to the story of a group, following its tracked changes
+func (r *RtfExtractor) write(state *rtfState, text string) {
+	story := state.story
+	if story == nil {
+		return
+	}
+	r.beginParagraph(state)
+	mark := state.revisionMark()
+	if !sameRevisionMark(mark, story.tracker.current) {
+		story.write(story.tracker.leave())
+		story.write(story.tracker.enter(mark, story.offset()))
+	}
+	story.tracker.collect(text)
+	if story.tracker.shows(mark) {
+		story.write(text)
+	}
+}
+
+// endParagraph ends a paragraph. A paragraph mark hidden by the revision
+// mode joins its paragraph to the next.
+func (r *RtfExtractor) endParagraph(state *rtfState) {
+	story := state.story
+	if story == nil {
+		return
+	}
+	r.beginParagraph(state)
+	story.write(story.tracker.leave())
+	if !story.tracker.shows(state.revisionMark()) {
+		return
+	}
+	r.setParagraphStyle(story, story.storyBuilder.endParagraph(), state.para)
+	story.started = false
+}
+
+func (r *RtfExtractor) endCell(state *rtfState) {
+	story := state.story
+	if story == nil {
+		return
+	}
+	r.beginParagraph(state)
+	story.write(story.tracker.leave())
+	r.setParagraphStyle(story, story.storyBuilder.endParagraph(), state.para)
+	story.storyBuilder.endCell()
+	story.started = false
+}
+
+// endRow ends a table row, with the cells defined for it
+func (r *RtfExtractor) endRow(state *rtfState) {
+	story := state.story
+	if story == nil {
+		return
+	}
+	story.write(story.tracker.leave())
+	if depth := state.para.depth(); depth > 0 {
+		story.setDepth(depth)
+	}
+	story.formatRow(r.row)
+	story.storyBuilder.endRow()
+	story.started = false
+}
+
+// setParagraphStyle sets the style, outline level and list numbering of a
+// paragraph of the body
+func (r *RtfExtractor) setParagraphStyle(story *rtfStory, p *Paragraph, para rtfParagraph) {
+	label := story.label
+	story.label = ""
+	if story != r.body {
+		return
+	}
+
+	var styles []*rtfStyle
+	for number := para.style; len(styles) < 10; {
+		style := r.styles[number]
+		if style == nil {
+			break
+		}
+		styles = append(styles, style)
+		if style.basedOn < 0 || style.basedOn == number {
+			break
+		}
+		number = style.basedOn
+	}
+	if len(styles) > 0 {
+		p.Style = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(styles[0].name.String()), ";"))
+	}
+	p.Level = headingStyleLevel(p.Style)
+	if para.level >= 0 {
+		p.Level = outlineLevel(para.level)
+	} else {
+		for _, style := range styles {
+			if style.level >= 0 {
+				p.Level = outlineLevel(style.level)
+				break
+			}
+		}
+	}
+
+	// Lists are numbered as written by the list text before each item
+	if label == "" {
+		return
+	}
+	item := r.numbering.next("ls:"+strconv.Itoa(para.list), newListDefinition(), para.ilvl)
+	item.Label = label
+	item.Bullet = strings.IndexFunc(label, func(c rune) bool {
+		return unicode.IsLetter(c) || unicode.IsDigit(c)
+	}) < 0
+	if item.Bullet {
+		item.Label = bulletLabel(label)
+	}
+	p.List = item
+}
+
+// finishStory closes a story other than the body
+func (r *RtfExtractor) finishStory(story *rtfStory) {
+	story.write(story.tracker.leave())
+	story.finish()
+}
+
+// startNote starts a footnote or endnote, whose reference is where the note
+// is in the body
+func (r *RtfExtractor) startNote(state *rtfState) {
+	offset := -1
+	if state.story == r.body && state.dest == rtfText {
+		r.beginParagraph(state)
+		offset = r.body.offset()
+	}
+	story := r.newStoryState(state)
+	state.main = false
+	state.note = &rtfNote{}
+	state.close = func(s *rtfState) {
+		r.finishStory(story)
+		text := story.String()
+		if s.note.endnote {
+			r.endnotes.WriteString(text)
+		} else {
+			r.footnotes.WriteString(text)
+		}
+		if offset < 0 {
+			return
+		}
+
+		doc := r.document
+		note := &Note{Text: strings.TrimSpace(text), Offset: offset}
+		if s.note.endnote {
+			doc.endnotes = append(doc.endnotes, note)
+			note.ID = strconv.Itoa(len(doc.endnotes))
+		} else {
+			doc.footnotes = append(doc.footnotes, note)
+			note.ID = strconv.Itoa(len(doc.footnotes))
+		}
+		if r.Options.NoteMarkers && r.body.offset() == offset {
+			number, _ := strconv.Atoi(note.ID)
+			r.body.write(noteMarker(s.note.endnote, number))
+		}
+	}
+}
+
+// startAnnotation starts a comment, made by the author given before it
+func (r *RtfExtractor) startAnnotation(state *rtfState) {
+	offset := -1
+	if state.story == r.body && state.dest == rtfText {
+		r.beginParagraph(state)
+		offset = r.body.offset()
+	}
+	comment := &rtfComment{comment: &Comment{Author: r.atnAuthor, Initials: r.atnID}, offset: offset}
+	r.atnID, r.atnAuthor = "", ""
+	story := r.newStoryState(state)
+	state.main = false
+	state.comment = comment
+	state.close = func(*rtfState) {
+		r.finishStory(story)
+		text := story.String()
+		r.annotations.WriteString(text)
+		comment.comment.Text = strings.TrimSpace(text)
+		if offset >= 0 {
+			r.comments = append(r.comments, comment)
+		}
+	}
+}
+
+// startField starts a field. Fields in the instruction of another field are
+// not shown, and only the fields of the body are listed.
+func (r *RtfExtractor) startField(state *rtfState) {
+	field := &rtfField{
+		visible: state.dest == rtfText,
+		depth:   r.fieldDepth,
+		begin:   r.fieldCount,
+	}
+	field.main = field.visible && state.story == r.body
+	r.fieldCount++
+	r.fieldDepth++
+	state.field = field
+	state.close = func(*rtfState) {
+		r.fieldDepth--
+		if !field.main {
+			return
+		}
+		f := parseField(field.instruction.String())
+		f.Depth, f.begin = field.depth, field.begin
+		f.Offset = r.body.offset()
+		if field.hasResult {
+			f.Offset = field.resultStart
+		}
+		f.Length = r.body.offset() - f.Offset
+		r.document.fields = append(r.document.fields, f)
+		if link, ok := fieldHyperlink(f); ok && field.hasResult {
+			r.document.hyperlinks = append(r.document.hyperlinks, link)
+		}
+	}
+}
+
+// startPicture starts a picture. Pictures of the body are listed, except
+// for the pictures shown for embedded objects.
+func (r *RtfExtractor) startPicture(state *rtfState) {
+	keep := state.main && !state.object
+	picture := &rtfPicture{}
+	offset := r.body.offset()
+	shape := state.shape
+	own := shape == nil
+	if own {
+		shape = &rtfShape{}
+		state.shape = shape
+	}
+	state.dest, state.data, state.picture = rtfData, &rtfHexData{}, picture
+	state.close = func(s *rtfState) {
+		data := s.data.bytes
+		if keep && picture.contentType != "" && len(data) > 0 {
+			if picture.dib {
+				data = bitmapFile(data)
+			}
+			image := &Image{
+				Name:        imageName(len(r.document.images)+1, picture.contentType),
+				ContentType: picture.contentType,
+				Data:        data,
+				Offset:      offset,
+			}
+			r.document.images = append(r.document.images, image)
+			shape.images = append(shape.images, image)
+		}
+		if own {
+			shape.describe()
+		}
+	}
+}
+
+// describe gives the pictures of a shape its description
+func (s *rtfShape) describe() {
+	for _, image := range s.images {
+		if image.AltText == "" {
+			image.AltText = s.altText
+		}
+	}
+}
+
+// addObject lists an embedded object. Objects are stored in their OLE 1
+// form, whose native data is the compound file of the object, or the file
+// packaged as an object.
+func (r *RtfExtractor) addObject(object *rtfObject) {
+	class, native, ok := readOle1Object(object.data)
+	if !ok {
+		return
+	}
+	progID := object.class
+	if progID == "" {
+		progID = class
+	}
+	name := "oleObject" + strconv.Itoa(len(r.document.objects)+1) + ".bin"
+
+	if class == "Package" {
+		prefixed := binary.LittleEndian.AppendUint32(nil, uint32(len(native)))
+		if fileName, data, ok := packagedFile(append(prefixed, native...)); ok {
+			r.document.objects = append(r.document.objects, &EmbeddedObject{ProgID: "Package", Name: fileName, Data: data})
+			return
+		}
+	}
+	if bytes.HasPrefix(native, compoundSignature) {
+		if entries, err := readCompoundFile(r.limits, bytes.NewReader(native)); err == nil {
+			if embedded, err := compoundObject(name, progID, entries, native); err == nil {
+				r.document.objects = append(r.document.objects, embedded)
+				return
+			}
+		}
+	}
+	r.document.objects = append(r.document.objects, &EmbeddedObject{ProgID: progID, Name: name, Data: native})
+}
+
+// readOle1Object reads an embedded OLE 1 object: its version and format,
+// its class, topic and item names, then its native data
+func readOle1Object(data []byte) (string, []byte, bool) {
+	if len(data) < 8 || binary.LittleEndian.Uint32(data[4:]) != 2 {
+		return "", nil, false
+	}
+	class, offset, ok := readLengthPrefixedString(data, 8)
+	for i := 0; i < 2 && ok; i++ {
+		_, offset, ok = readLengthPrefixedString(data, offset)
+	}
+	if !ok || offset+4 > len(data) {
+		return "", nil, false
+	}
+	size := int(binary.LittleEndian.Uint32(data[offset:]))
+	offset += 4
+	if size < 0 || size > len(data)-offset {
+		size = len(data) - offset
+	}
+	return class, data[offset : offset+size], true
+}
+
+// finish closes the groups left open and fills in the document
+func (r *RtfExtractor) finish() {
+	for len(r.states) > 1 {
+		r.pop()
+	}
+	r.flush()
+	body := r.body
+	body.write(body.tracker.leave())
+	body.finish()
+
+	doc := r.document
+	doc.Body = body.String()
+	doc.Blocks = body.Blocks()
+	doc.revisions = body.tracker.found
+	doc.Footnotes = r.footnotes.String()
+	doc.Endnotes = r.endnotes.String()
+	doc.Headers = r.headers.String()
+	doc.Footers = r.footers.String()
+	doc.Annotations = r.annotations.String()
+	if len(r.textboxes) > 0 {
+		doc.Textboxes = strings.Join(r.textboxes, "\n") + "\n"
+	}
+	if len(r.headerTextboxes) > 0 {
+		doc.HeaderTextboxes = strings.Join(r.headerTextboxes, "\n") + "\n"
+	}
+
+	text := []rune(doc.Body)
+	for i, c := range r.comments {
+		c.comment.ID = strconv.Itoa(i)
+		end, ok := r.commentEnds[c.ref]
+		if !ok || c.ref == "" {
+			end = c.offset
+		}
+		start, ok := r.commentStarts[c.ref]
+		if !ok || c.ref == "" {
+			start = end
+		}
+		c.comment.anchor(text, start, end)
+		doc.comments = append(doc.comments, c.comment)
+	}
+	sortFields(doc.fields)
+	setFieldResults(doc.fields, text)
+	setHyperlinkText(doc.hyperlinks, text)
+}
@@ -0,0 +1,326 @@
+package word_extractor
+
+import (
+	"sort"
+	"strings"
+)
+
+// storyBuilder accumulates the text of a single story (the body, the
+// footnotes, a header, a textbox...) together with its paragraph and table
+// structure.
+//
+// The rendered text keeps the layout both extractors have always produced:
+// every paragraph ends with a newline, the last paragraph of a table cell ends
+// with a tab instead, and every table row ends with a newline.
+type storyBuilder struct {
+	text      []rune
+	blocks    []Block
+	paraStart int
+	tables    []*tableFrame
+	// limits counts the characters written. Text is no longer added once
+	// they go over the limit.
+	limits *limiter
+}
+
+// tableFrame tracks a table that is still open while building a story
+type tableFrame struct {
+	table      *Table
+	row        []Cell
+	inRow      bool
+	cell       *Cell
+	cellStart  int
+	cellFolded bool
+	formats    map[int][]cellFormat
+}
+
+// cellFormat describes the layout of a table cell for formats where it is
+// only known once the whole row has been read
+type cellFormat struct {
+	// left and right are the cell boundaries, in twips
+	left, right int
+	vMerge      VerticalMerge
+	// merged is set when the cell is merged into the cell to its left
+	merged bool
+}
+
+func newStoryBuilder(limits *limiter) *storyBuilder {
+	return &storyBuilder{limits: limits}
+}
+
+// String returns the rendered text of the story
+func (b *storyBuilder) String() string {
+	return string(b.text)
+}
+
+// Blocks returns the paragraphs and tables added to the story so far
+func (b *storyBuilder) Blocks() []Block {
+	return b.blocks
+}
+
+// offset returns the current length of the rendered text in runes
+func (b *storyBuilder) offset() int {
+	return len(b.text)
+}
+
+func (b *storyBuilder) write(s string) {
+	runes := []rune(s)
+	if b.limits.addOutput(len(runes)) != nil {
+		return
+	}
+	b.text = append(b.text, runes...)
+}
+
+func (b *storyBuilder) writeRune(r rune) {
+	if b.limits.addOutput(1) != nil {
+		return
+	}
+	b.text = append(b.text, r)
+}
+
+// startParagraph marks the start of a new paragraph at the current offset
+func (b *storyBuilder) startParagraph() {
+	b.paraStart = len(b.text)
+}
+
+// endParagraph closes the current paragraph and terminates it with a newline
+func (b *storyBuilder) endParagraph() *Paragraph {
+	if b.paraStart > len(b.text) {
+		b.paraStart = len(b.text)
+	}
+	p := &Paragraph{Text: string(b.text[b.paraStart:]), Offset: b.paraStart}
+	b.limits.addOutput(1)
+	b.text = append(b.text, '\n')
+	b.paraStart = len(b.text)
+	b.add(Block{Paragraph: p})
+	return p
+}
+
+// add appends a finished block to the innermost open cell, or to the story
+// itself when no table is open
+func (b *storyBuilder) add(block Block) {
+	f := b.top()
+	if f == nil {
+		b.blocks = append(b.blocks, block)
+		return
+	}
+	if f.cell == nil {
+		b.startCell()
+	}
+	f.cell.Blocks = append(f.cell.Blocks, block)
+}
+
+func (b *storyBuilder) top() *tableFrame {
+	if len(b.tables) == 0 {
+		return nil
+	}
+	return b.tables[len(b.tables)-1]
+}
+
+// depth returns the number of tables currently open
+func (b *storyBuilder) depth() int {
+	return len(b.tables)
+}
+
+// setDepth opens or closes tables until exactly depth tables are open. This
+// is used when nesting is only known from paragraph properties.
+func (b *storyBuilder) setDepth(depth int) {
+	for len(b.tables) > depth {
+		b.endTable()
+	}
+	for len(b.tables) < depth {
+		b.startTable()
+	}
+}
+
+// ensureCell opens a row and cell in the innermost table unless one is
+// already open
+func (b *storyBuilder) ensureCell() {
+	if f := b.top(); f == nil || f.cell == nil {
+		b.startCell()
+	}
+}
+
+func (b *storyBuilder) startTable() {
+	if f := b.top(); f != nil && f.cell == nil {
+		b.startCell()
+	}
+	b.tables = append(b.tables, &tableFrame{table: &Table{}})
+}
+
+func (b *storyBuilder) endTable() {
+	f := b.top()
+	if f == nil {
+		return
+	}
+	if f.cell != nil {
+		b.endCell()
+	}
+	if len(f.row) > 0 {
+		f.table.Rows = append(f.table.Rows, f.row)
+	}
+	if f.formats != nil {
+		applyCellFormats(f.table, f.formats)
+	}
+	b.tables = b.tables[:len(b.tables)-1]
+	b.add(Block{Table: f.table})
+}
+
+// applyCellFormats sets the spans and merges of a table from the cell
+// formats of its rows. Spans are counted against a grid made from every cell
+// boundary in the table, and cells merged into their left neighbour are
+// folded away.
+func applyCellFormats(table *Table, formats map[int][]cellFormat) {
+	edges := make(map[int]bool)
+	for _, row := range formats {
+		for _, format := range row {
+			edges[format.left] = true
+			edges[format.right] = true
+		}
+	}
+	grid := make([]int, 0, len(edges))
+	for edge := range edges {
+		grid = append(grid, edge)
+	}
+	sort.Ints(grid)
+
+	for r, row := range table.Rows {
+		rowFormats, ok := formats[r]
+		if !ok {
+			continue
+		}
+		folded := make([]Cell, 0, len(row))
+		for i, cell := range row {
+			if i < len(rowFormats) {
+				format := rowFormats[i]
+				first := sort.SearchInts(grid, format.left)
+				last := sort.SearchInts(grid, format.right)
+				if last > first {
+					cell.GridSpan = last - first
+				}
+				cell.VMerge = format.vMerge
+				if format.merged && len(folded) > 0 {
+					folded[len(folded)-1].GridSpan += cell.GridSpan
+					continue
+				}
+			}
+			folded = append(folded, cell)
+		}
+		table.Rows[r] = folded
+	}
+}
+
+// formatRow records the layout of the cells of the current row, to be
+// applied when the table is closed
+func (b *storyBuilder) formatRow(formats []cellFormat) {
+	f := b.top()
+	if f == nil || len(formats) == 0 {
+		return
+	}
+	if f.formats == nil {
+		f.formats = make(map[int][]cellFormat)
+	}
+	f.formats[len(f.table.Rows)] = formats
+}
+
+func (b *storyBuilder) startRow() {
+	f := b.top()
+	if f == nil {
+		b.startTable()
+		f = b.top()
+	}
+	if f.inRow {
+		b.endRow()
+	}
+	f.inRow = true
+	f.row = nil
+}
+
+// endRow closes the current row and terminates it with a newline
+func (b *storyBuilder) endRow() {
+	if f := b.top(); f != nil {
+		if f.cell != nil {
+			b.endCell()
+		}
+		f.table.Rows = append(f.table.Rows, f.row)
+		f.row = nil
+		f.inRow = false
+	}
+	b.limits.addOutput(1)
+	b.text = append(b.text, '\n')
+	b.paraStart = len(b.text)
+}
+
+func (b *storyBuilder) startCell() {
+	f := b.top()
+	if f == nil {
+		b.startTable()
+		f = b.top()
+	}
+	if f.cell != nil {
+		b.endCell()
+	}
+	f.inRow = true
+	f.cell = &Cell{GridSpan: 1}
+	f.cellStart = len(b.text)
+	f.cellFolded = false
+	b.paraStart = len(b.text)
+}
+
+// setGridSpan sets the number of grid columns covered by the current cell
+func (b *storyBuilder) setGridSpan(span int) {
+	if f := b.top(); f != nil && f.cell != nil && span > 0 {
+		f.cell.GridSpan = span
+	}
+}
+
+// setVMerge sets the vertical merge state of the current cell
+func (b *storyBuilder) setVMerge(merge VerticalMerge) {
+	if f := b.top(); f != nil && f.cell != nil {
+		f.cell.VMerge = merge
+	}
+}
+
+// foldCell marks the current cell as merged into the cell to its left
+func (b *storyBuilder) foldCell() {
+	if f := b.top(); f != nil && f.cell != nil {
+		f.cellFolded = true
+	}
+}
+
+// endCell closes the current cell, turning the newline that ended its last
+// paragraph into a tab
+func (b *storyBuilder) endCell() {
+	f := b.top()
+	start := 0
+	if f != nil && f.cell != nil {
+		start = f.cellStart
+	}
+	if n := len(b.text); n > start && b.text[n-1] == '\n' {
+		b.text[n-1] = '\t'
+	} else {
+		b.limits.addOutput(1)
+		b.text = append(b.text, '\t')
+	}
+	b.paraStart = len(b.text)
+	if f == nil || f.cell == nil {
+		return
+	}
+	f.cell.Text = string(b.text[f.cellStart : len(b.text)-1])
+	if f.cellFolded && len(f.row) > 0 {
+		f.row[len(f.row)-1].GridSpan += f.cell.GridSpan
+	} else {
+		f.row = append(f.row, *f.cell)
+	}
+	f.cell = nil
+	f.cellFolded = false
+}
+
+// finish closes anything left open at the end of the story. A trailing
+// paragraph without a paragraph mark is kept, but not given a newline.
+func (b *storyBuilder) finish() {
+	if b.paraStart < len(b.text) && strings.TrimSpace(string(b.text[b.paraStart:])) != "" {
+		b.add(Block{Paragraph: &Paragraph{Text: string(b.text[b.paraStart:]), Offset: b.paraStart}})
+		b.paraStart = len(b.text)
+	}
+	b.setDepth(0)
+}
@@ -0,0 +1,96 @@
+package word_extractor
+
+import "encoding/binary"
+
+// structReader reads the fields of a structure of a stream, such as the FIB
+// or a PLC, checking that each is within the structure. A field outside it
+// reads as zero, and the first is kept as an ErrCorrupt error that names the
+// structure. Readers of the structures within a structure share its error.
+type structReader struct {
+	stream string
+	name   string
+	data   []byte
+	// base is the offset of the structure in the stream
+	base int
+	errp *error
+}
+
+// newStructReader reads a structure that is a whole stream, or starts it
+func newStructReader(stream, name string, data []byte) *structReader {
+	return &structReader{stream: stream, name: name, data: data, errp: new(error)}
+}
+
+// err returns the first error of the reader, or of the readers that share
+// its error
+func (r *structReader) err() error {
+	return *r.errp
+}
+
+// fail records that a structure is not within the data it is read from
+func (r *structReader) fail(name string, offset int) {
+	if *r.errp == nil {
+		*r.errp = corruptError(r.stream, r.base+offset, "invalid %s", name)
+	}
+}
+
+// has tells whether size bytes at an offset are within the structure. When
+// they are not, or an error was found before, nothing more is read.
+func (r *structReader) has(offset, size int) bool {
+	if *r.errp != nil {
+		return false
+	}
+	if offset < 0 || size < 0 || offset > len(r.data)-size {
+		r.fail(r.name, offset)
+		return false
+	}
+	return true
+}
+
+// size returns the size of the structure
+func (r *structReader) size() int {
+	return len(r.data)
+}
+
+func (r *structReader) uint8(offset int) uint8 {
+	if !r.has(offset, 1) {
+		return 0
+	}
+	return r.data[offset]
+}
+
+func (r *structReader) uint16(offset int) uint16 {
+	if !r.has(offset, 2) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(r.data[offset:])
+}
+
+func (r *structReader) uint32(offset int) uint32 {
+	if !r.has(offset, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(r.data[offset:])
+}
+
+// bytes returns size bytes at an offset of the structure
+func (r *structReader) bytes(offset, size int) []byte {
+	if !r.has(offset, size) {
+		return nil
+	}
+	return r.data[offset : offset+size]
+}
+
+// sub returns a reader of a structure within this one, of a given size at an
+// offset. When it is not within this one, the error names the structure.
+func (r *structReader) sub(name string, offset, size int) *structReader {
+	s := &structReader{stream: r.stream, name: name, base: r.base + offset, errp: r.errp}
+	if *r.errp != nil {
+		return s
+	}
+	if offset < 0 || size < 0 || offset > len(r.data)-size {
+		r.fail(name, offset)
+		return s
+	}
+	s.data = r.data[offset : offset+size]
+	return s
+}
@@ -2,58 +2,214 @@ package word_extractor
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // WordExtractor is the main struct for extracting content from Word documents
-type WordExtractor struct{}
+type WordExtractor struct {
+	// Options configures how documents are extracted
+	Options ExtractOptions
+}
+
+// ExtractOptions contains configuration applied while a document is extracted
+type ExtractOptions struct {
+	// Revisions selects how tracked changes appear in the extracted text
+	Revisions RevisionMode
+	// NoteMarkers writes a marker into the body at each note reference,
+	// numbered in order: [^1] for footnotes and [^e1] for endnotes
+	NoteMarkers bool
+	// EmbeddedDocuments extracts the Word documents embedded as objects, with
+	// these same options, and appends their body text to Body. Documents
+	// nested more than eight levels deep are left out.
+	EmbeddedDocuments bool
+	// Password decrypts password protected documents. Without it, they fail
+	// with ErrEncrypted.
+	Password string
+	// Limits bounds the resources used to extract a document. Documents that
+	// go over them fail with a *LimitError.
+	Limits Limits
+}
 
 // NewWordExtractor creates a new instance of WordExtractor
 func NewWordExtractor() *WordExtractor {
 	return &WordExtractor{}
 }
 
-// Extract processes the given source (either filename or byte slice) and extracts the document content
+// Extract processes the given source (either filename or byte slice) and
+// extracts the document content. ExtractFile, ExtractReader and
+// ExtractReaderAt take the other kinds of input.
 func (w *WordExtractor) Extract(source interface{}) (*Document, error) {
-	var reader io.ReadSeeker
-	var closer io.Closer
+	return w.ExtractContext(context.Background(), source)
+}
 
+// ExtractContext extracts a source like Extract, and stops once the context
+// is done, returning its error. The context is checked as pieces, property
+// pages and characters of .doc files, XML tokens of packages, groups of RTF
+// files and the keys of encrypted packages are read, so it stops soon after.
+// ExtractFileContext, ExtractReaderContext and ExtractReaderAtContext take
+// the other kinds of input.
+func (w *WordExtractor) ExtractContext(ctx context.Context, source interface{}) (*Document, error) {
+	limits := newLimiter(ctx, w.Options.Limits)
 	switch s := source.(type) {
 	case []byte:
-		reader = bytes.NewReader(s)
+		return w.extractReader(limits, bytes.NewReader(s))
 	case string:
-		// Get absolute path
-		absPath, err := filepath.Abs(s)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		return w.extractPath(limits, s)
+	}
+	return nil, errors.New("source must be either a filename string or byte slice")
+}
+
+// extractPath opens the file at a path and extracts it
+func (w *WordExtractor) extractPath(limits *limiter, path string) (*Document, error) {
+	// Get absolute path
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	// Open file with explicit read permissions
+	file, err := os.OpenFile(absPath, os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	return w.extractFile(limits, file)
+}
+
+// ExtractFile extracts the document held by an open file, from its start.
+// Files that cannot seek, such as pipes, are read as ExtractReader reads
+// them. The file is not closed.
+func (w *WordExtractor) ExtractFile(file *os.File) (*Document, error) {
+	return w.ExtractFileContext(context.Background(), file)
+}
+
+// ExtractFileContext extracts an open file like ExtractFile, and stops once
+// the context is done
+func (w *WordExtractor) ExtractFileContext(ctx context.Context, file *os.File) (*Document, error) {
+	return w.extractFile(newLimiter(ctx, w.Options.Limits), file)
+}
+
+func (w *WordExtractor) extractFile(limits *limiter, file *os.File) (*Document, error) {
+	if _, err := file.Seek(0, io.SeekCurrent); err != nil {
+		return w.extractCopy(limits, file)
+	}
+	return w.extractReader(limits, file)
+}
+
+// ExtractReaderAt extracts the document of the given size that is read at
+// offsets of r, such as an object of a store that serves ranges
+func (w *WordExtractor) ExtractReaderAt(r io.ReaderAt, size int64) (*Document, error) {
+	return w.ExtractReaderAtContext(context.Background(), r, size)
+}
+
+// ExtractReaderAtContext extracts a document like ExtractReaderAt, and stops
+// once the context is done
+func (w *WordExtractor) ExtractReaderAtContext(ctx context.Context, r io.ReaderAt, size int64) (*Document, error) {
+	return w.extractReader(newLimiter(ctx, w.Options.Limits), io.NewSectionReader(r, 0, size))
+}
+
+// ExtractReader extracts the document read by r. Readers that can seek, such
+// as a *bytes.Reader or a multipart.File, are read in place from their start.
+// Others, such as an HTTP body or an *os.File that is a pipe, are first
+// copied to a temporary file, which is removed once the document is
+// extracted. Only MaxInputSize bytes of them are copied when it is set.
+func (w *WordExtractor) ExtractReader(r io.Reader) (*Document, error) {
+	return w.ExtractReaderContext(context.Background(), r)
+}
+
+// ExtractReaderContext extracts a document like ExtractReader, and stops
+// once the context is done. A reader that is copied stops being copied too.
+func (w *WordExtractor) ExtractReaderContext(ctx context.Context, r io.Reader) (*Document, error) {
+	limits := newLimiter(ctx, w.Options.Limits)
+	// A reader may have a Seek method that fails, as an *os.File that is a
+	// pipe does
+	if reader, ok := r.(io.ReadSeeker); ok {
+		if _, err := reader.Seek(0, io.SeekCurrent); err == nil {
+			return w.extractReader(limits, reader)
 		}
+	}
+	return w.extractCopy(limits, r)
+}
+
+// extractCopy copies a reader that cannot seek to a temporary file, and
+// extracts the copy
+func (w *WordExtractor) extractCopy(limits *limiter, r io.Reader) (*Document, error) {
+	file, err := os.CreateTemp("", "word-extractor-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-		// Open file with explicit read permissions
-		file, err := os.OpenFile(absPath, os.O_RDONLY, 0644)
+	r = checkedReader{Reader: r, limits: limits}
+	if limit := limits.MaxInputSize; limit > 0 {
+		n, err := io.Copy(file, io.LimitReader(r, limit+1))
 		if err != nil {
-			return nil, fmt.Errorf("failed to open file: %v", err)
+			return nil, fmt.Errorf("failed to copy to temporary file: %w", err)
 		}
-		reader = file
-		closer = file
-	default:
-		return nil, errors.New("source must be either a filename string or byte slice")
+		if n > limit {
+			return nil, partError("", -1, &LimitError{Limit: "MaxInputSize"})
+		}
+	} else if _, err := io.Copy(file, r); err != nil {
+		return nil, fmt.Errorf("failed to copy to temporary file: %w", err)
 	}
+	return w.extractReader(limits, file)
+}
 
-	if closer != nil {
-		defer closer.Close()
+// checkedReader stops reading once the limiter of its call is done
+type checkedReader struct {
+	io.Reader
+	limits *limiter
+}
+
+func (r checkedReader) Read(p []byte) (int, error) {
+	if err := r.limits.check(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
+// extractReader extracts the document read by a reader, which all the
+// Extract methods come to with the limiter of their call
+func (w *WordExtractor) extractReader(limits *limiter, reader io.ReadSeeker) (doc *Document, err error) {
+	// Structures are checked as they are read, so a panic is a bug in the
+	// checks rather than in the caller. It is reported as a corrupt document
+	// so that one bad file cannot bring the caller down.
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, &ExtractError{Offset: -1, Err: fmt.Errorf("%w: %v", ErrCorrupt, r)}
+		}
+	}()
+	return w.extract(limits, reader, 0)
+}
+
+// extract detects the format of a document and extracts it with the
+// extractor for that format. Decrypted packages and embedded documents are
+// extracted within the same limiter, so that the limits and the deadline are
+// those of the whole call. The depth is how many documents hold this one.
+func (w *WordExtractor) extract(limits *limiter, reader io.ReadSeeker, depth int) (*Document, error) {
+	// Documents are read from their start, wherever the reader was left
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := limits.checkInput(reader); err != nil {
+		return nil, partError("", -1, err)
 	}
 
 	// Read first 512 bytes to determine file type
 	buffer := make([]byte, 512)
-	_, err := reader.Read(buffer)
-	if err != nil {
+	n, err := io.ReadFull(reader, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return nil, err
 	}
+	buffer = buffer[:n]
 
 	// Reset reader position
 	_, err = reader.Seek(0, 0)
@@ -63,30 +219,122 @@ func (w *WordExtractor) Extract(source interface{}) (*Document, error) {
 
 	// Check file signature
 	if len(buffer) < 4 {
-		return nil, errors.New("file too small")
+		return nil, fmt.Errorf("%w: file too small", ErrUnsupportedFormat)
 	}
 
-	var extractor DocumentExtractor
+	var extractor limitedExtractor
 
 	// Check for OLE document (0xD0CF)
 	if binary.BigEndian.Uint16(buffer[0:2]) == 0xD0CF {
-		extractor = NewWordOleExtractor()
+		// Password protected packages are kept encrypted in a compound file
+		info, pkg, ok, err := encryptedPackage(limits, reader)
+		if err != nil {
+			return nil, partError("", -1, err)
+		}
+		if ok {
+			if depth >= maxEmbeddedDepth {
+				return nil, corruptError("EncryptedPackage", -1, "encrypted packages nested too deeply")
+			}
+			data, err := decryptPackage(limits, info, pkg, w.Options.Password)
+			switch {
+			case errors.Is(err, ErrEncrypted), errors.Is(err, ErrWrongPassword):
+				return nil, err
+			case err != nil:
+				// Descriptors and keys that cannot be read are corrupt
+				return nil, partError("EncryptionInfo", -1, err)
+			}
+			return w.extract(limits, bytes.NewReader(data), depth+1)
+		}
+		oleExtractor := NewWordOleExtractor()
+		oleExtractor.Options = w.Options
+		extractor = oleExtractor
 	} else if binary.BigEndian.Uint16(buffer[0:2]) == 0x504B {
 		// Check for OpenOffice document (PK signature + specific following bytes)
 		next := binary.BigEndian.Uint16(buffer[2:4])
 		if next == 0x0304 || next == 0x0506 || next == 0x0708 {
-			extractor = NewOpenOfficeExtractor()
+			// OpenDocument files are told apart by their mimetype entry
+			if strings.HasPrefix(openDocumentMediaType(reader), openDocumentTextType) {
+				openDocumentExtractor := NewOpenDocumentExtractor()
+				openDocumentExtractor.Options = w.Options
+				extractor = openDocumentExtractor
+			} else {
+				openOfficeExtractor := NewOpenOfficeExtractor()
+				openOfficeExtractor.Options = w.Options
+				extractor = openOfficeExtractor
+			}
 		}
+	} else if bytes.HasPrefix(buffer, []byte(`{\rtf`)) {
+		rtfExtractor := NewRtfExtractor()
+		rtfExtractor.Options = w.Options
+		extractor = rtfExtractor
+	} else if root, ok := xmlRootElement(reader); ok && isWordXML(root) {
+		// Flat OPC packages and Word 2003 XML documents are single XML files
+		openOfficeExtractor := NewOpenOfficeExtractor()
+		openOfficeExtractor.Options = w.Options
+		extractor = openOfficeExtractor
 	}
 
 	if extractor == nil {
-		return nil, errors.New("unable to read this type of file")
+		return nil, fmt.Errorf("%w: unable to read this type of file", ErrUnsupportedFormat)
 	}
 
-	return extractor.Extract(reader)
+	doc, err := extractor.extractLimited(limits, reader)
+	if err != nil {
+		return nil, partError("", -1, err)
+	}
+	if w.Options.EmbeddedDocuments {
+		if err := w.extractEmbeddedDocuments(limits, doc, depth); err != nil {
+			return nil, err
+		}
+		// The text of the embedded documents counts towards the output
+		if err := limits.checkOutput(doc); err != nil {
+			return nil, partError("", -1, err)
+		}
+	}
+	return doc, nil
+}
+
+// maxEmbeddedDepth is how deeply documents embedded in documents, or held
+// encrypted in them, are extracted
+const maxEmbeddedDepth = 8
+
+// extractEmbeddedDocuments extracts the Word documents embedded in a
+// document and appends them to its body. Objects that cannot be extracted
+// are left out, but going over the limits or the context being done stops
+// the extraction. Documents embedded deeper than maxEmbeddedDepth are left
+// out, so that a chain of nested documents cannot exhaust the stack.
+func (w *WordExtractor) extractEmbeddedDocuments(limits *limiter, doc *Document, depth int) error {
+	if depth >= maxEmbeddedDepth {
+		return nil
+	}
+	for _, object := range doc.objects {
+		if !object.isWordDocument() {
+			continue
+		}
+		embedded, err := w.extract(limits, bytes.NewReader(object.Data), depth+1)
+		if checkErr := limits.check(); checkErr != nil {
+			return partError("", -1, checkErr)
+		}
+		if errors.Is(err, ErrTooLarge) {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+		object.Document = embedded
+		doc.appendDocument(embedded)
+	}
+	return nil
 }
 
 // DocumentExtractor interface defines the contract for different document extractors
 type DocumentExtractor interface {
 	Extract(reader io.ReadSeeker) (*Document, error)
 }
+
+// limitedExtractor is a DocumentExtractor that extracts within the limiter
+// of the call it is part of, as the extractors of this package do
+type limitedExtractor interface {
+	DocumentExtractor
+	extractLimited(limits *limiter, reader io.ReadSeeker) (*Document, error)
+}
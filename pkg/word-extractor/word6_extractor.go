@@ -0,0 +1,647 @@
+package word_extractor
+
+import (
+	"encoding/binary"
+	"sort"
+	"unicode/utf16"
+)
+
+// Word 6.0 and Word 95 files have an older FIB, with an nFib from 101 for
+// Word 6.0 up to the 193 of Word 97. They keep all their tables in the
+// WordDocument stream, their text in a single byte code page, and use one
+// byte sprms.
+const (
+	word6Fib  = 0x0065
+	word97Fib = 0x00C1
+)
+
+// word6FibSize is the size of the part of a Word 6 FIB that is read
+const word6FibSize = 0x0200
+
+// Offsets in the Word 6 FIB of the fc and lcb pairs of its tables
+const (
+	word6Stshf        = 0x0060
+	word6PlcfandRef   = 0x0078
+	word6PlcfandTxt   = 0x0080
+	word6Plcfsed      = 0x0088
+	word6Plcfhdd      = 0x00B0
+	word6PlcfbteChpx  = 0x00B8
+	word6PlcfbtePapx  = 0x00C0
+	word6Dop          = 0x0150
+	word6Clx          = 0x0160
+	word6GrpStAtnOwnr = 0x0178
+	word6SttbfRMark   = 0x01FA
+)
+
+var word6NoteTables = noteTables{fndRef: 0x0068, fndTxt: 0x0070, endRef: 0x01D2, endTxt: 0x01DA}
+
+// word6TableNames names the structures given by the fc and lcb pairs of the
+// Word 6 FIB, by the offset of the pair
+var word6TableNames = map[int]string{
+	word6Stshf:        "STSH",
+	0x0068:            "PlcffndRef",
+	0x0070:            "PlcffndTxt",
+	word6PlcfandRef:   "PlcfandRef",
+	word6PlcfandTxt:   "PlcfandTxt",
+	word6Plcfsed:      "PlcfSed",
+	word6Plcfhdd:      "PlcfHdd",
+	word6PlcfbteChpx:  "PlcBteChpx",
+	word6PlcfbtePapx:  "PlcBtePapx",
+	word6Dop:          "DOP",
+	word6Clx:          "Clx",
+	word6GrpStAtnOwnr: "GrpStAtnOwners",
+	0x01D2:            "PlcfendRef",
+	0x01DA:            "PlcfendTxt",
+	word6SttbfRMark:   "SttbfRMark",
+}
+
+// Word 6 sprms that are read
+const (
+	sprm6PIstd       = 2
+	sprm6PFInTable   = 24
+	sprm6PFTtp       = 25
+	sprm6CFStrikeRM  = 65
+	sprm6CFRMark     = 66
+	sprm6CIbstRMark  = 69
+	sprm6CDttmRMark  = 70
+	sprm6SGprfIhdt   = 153
+	sprm6TDefTable10 = 188
+	sprm6TDefTable   = 190
+)
+
+// Operand sizes of Word 6 sprms that do not have a fixed size
+const (
+	sprm6Variable     = -1 // the operand starts with its size in a byte
+	sprm6VariableWord = -2 // the operand starts with its size in a word
+)
+
+// word6SprmSizes gives the operand size of each Word 6 sprm
+var word6SprmSizes = map[byte]int{
+	2: 2, 3: sprm6Variable, 4: 1, 5: 1, 6: 1, 7: 1, 8: 1, 9: 1, 10: 1, 11: 1,
+	12: sprm6Variable, 13: 1, 14: 1, 15: sprm6Variable, 16: 2, 17: 2, 18: 2, 19: 2,
+	20: 4, 21: 2, 22: 2, 23: sprm6Variable, 24: 1, 25: 1, 26: 2, 27: 2, 28: 2, 29: 1,
+	30: 2, 31: 2, 32: 2, 33: 2, 34: 2, 35: 2, 36: 2, 37: 1, 38: 2, 39: 2,
+	40: 2, 41: 2, 42: 2, 43: 2, 44: 1, 45: 2, 46: 2, 47: 2, 48: 2, 49: 2,
+	50: 1, 51: 1, 53: 1, 54: 1, 55: 1, 56: 1, 57: 1, 58: 1, 59: 2, 60: 2, 61: 1,
+	64: sprm6Variable, 65: 1, 66: 1, 67: 1, 68: sprm6Variable, 69: 2, 70: 4, 71: 1,
+	72: 2, 73: 3, 74: sprm6Variable, 75: 1, 77: sprm6Variable, 79: sprm6Variable,
+	80: 2, 81: sprm6Variable, 82: 0, 83: 0, 85: 1, 86: 1, 87: 1, 88: 1, 89: 1,
+	90: 1, 91: 1, 92: 1, 93: 2, 94: 1, 95: 3, 96: 2, 97: 2, 98: 1, 99: 2,
+	100: 1, 101: 2, 102: 1, 103: sprm6Variable, 104: 1, 105: sprm6Variable,
+	106: sprm6Variable, 107: 2, 108: sprm6Variable, 109: 2, 110: 2, 111: 2,
+	112: 2, 113: 2, 114: 2, 115: 2, 116: 2, 117: 1, 118: 1, 119: 1,
+	120: sprm6Variable, 121: 2, 122: 2, 123: 2, 124: 2,
+	131: 1, 132: 1, 133: sprm6Variable, 136: 3, 137: 3, 138: 1, 139: 1,
+	140: 2, 141: 2, 142: 1, 143: 1, 144: 2, 145: 2, 146: 1, 147: 1, 148: 2, 149: 2,
+	150: 1, 151: 1, 152: 1, 153: 1, 154: 2, 155: 2, 156: 2, 157: 2, 158: 1, 159: 1,
+	160: 2, 161: 2, 162: 1, 163: 0, 164: 2, 165: 2, 166: 2, 167: 2, 168: 2,
+	169: 2, 170: 2, 171: 2,
+	182: 2, 183: 2, 184: 2, 185: 1, 186: 1, 187: 12, 188: sprm6VariableWord,
+	189: 2, 190: sprm6VariableWord, 191: sprm6Variable, 192: 4, 193: 5, 194: 4,
+	195: 2, 196: 4, 197: 2, 198: 2, 199: 5, 200: 4,
+}
+
+// processWord6Sprms calls handler with each sprm of a Word 6 grpprl and its
+// operand. Variable operands are given with their size. It stops at a sprm
+// whose size is not known, as the ones after it cannot be found.
+func processWord6Sprms(grpprl []byte, handler func(sprm byte, operand []byte)) {
+	for offset := 0; offset < len(grpprl); {
+		sprm := grpprl[offset]
+		offset++
+		size, ok := word6SprmSizes[sprm]
+		if !ok {
+			return
+		}
+		switch size {
+		case sprm6Variable:
+			if offset >= len(grpprl) {
+				return
+			}
+			size = int(grpprl[offset]) + 1
+		case sprm6VariableWord:
+			if offset+2 > len(grpprl) {
+				return
+			}
+			size = int(binary.LittleEndian.Uint16(grpprl[offset:])) + 1
+		}
+		if offset+size > len(grpprl) {
+			return
+		}
+		handler(sprm, grpprl[offset:offset+size])
+		offset += size
+	}
+}
+
+// extractWord6Document reads a Word 6.0 or Word 95 document from its
+// WordDocument stream, which also holds its tables
+func (w *WordOleExtractor) extractWord6Document(buffer []byte) (*Document, error) {
+	if len(buffer) < word6FibSize {
+		return nil, corruptError("WordDocument", 0, "truncated FIB")
+	}
+	// Word 6 encryption is not supported
+	if binary.LittleEndian.Uint16(buffer[0x0A:])&fibEncrypted != 0 {
+		return nil, ErrEncrypted
+	}
+	codePage := word6CodePage(buffer)
+	w.tableStream = "WordDocument"
+
+	// The macro text, which comes after the headers, is not read
+	w.boundaries = Boundaries{
+		FcMin:      int(binary.LittleEndian.Uint32(buffer[0x0018:])),
+		CcpText:    int(binary.LittleEndian.Uint32(buffer[0x0034:])),
+		CcpFtn:     int(binary.LittleEndian.Uint32(buffer[0x0038:])),
+		CcpHdd:     int(binary.LittleEndian.Uint32(buffer[0x003C:])),
+		CcpMcr:     int(binary.LittleEndian.Uint32(buffer[0x0040:])),
+		CcpAtn:     int(binary.LittleEndian.Uint32(buffer[0x0044:])),
+		CcpEdn:     int(binary.LittleEndian.Uint32(buffer[0x0048:])),
+		CcpTxbx:    int(binary.LittleEndian.Uint32(buffer[0x004C:])),
+		CcpHdrTxbx: int(binary.LittleEndian.Uint32(buffer[0x0050:])),
+	}
+
+	if err := w.writeWord6RevisionAuthors(buffer, codePage); err != nil {
+		return nil, err
+	}
+	if err := w.writeWord6Comments(buffer, codePage); err != nil {
+		return nil, err
+	}
+	if err := w.writeNotes(buffer, buffer, word6NoteTables); err != nil {
+		return nil, err
+	}
+	if err := w.writeWord6Pieces(buffer, codePage); err != nil {
+		return nil, err
+	}
+	if err := w.writeWord6CharacterProperties(buffer); err != nil {
+		return nil, err
+	}
+	if err := w.writeWord6Styles(buffer, codePage); err != nil {
+		return nil, err
+	}
+	if err := w.writeWord6ParagraphProperties(buffer); err != nil {
+		return nil, err
+	}
+	if err := w.normalizeWord6Headers(buffer); err != nil {
+		return nil, err
+	}
+
+	return w.buildDocument()
+}
+
+// word6CodePage returns the code page of the text of a Word 6 document, from
+// the character set and language in its FIB
+func word6CodePage(buffer []byte) int {
+	lid := binary.LittleEndian.Uint16(buffer[0x0006:])
+	chse := binary.LittleEndian.Uint16(buffer[0x0014:])
+	if chse == 256 {
+		if languageCodePage(lid) == 1251 {
+			return 10007
+		}
+		return 10000
+	}
+	return languageCodePage(lid)
+}
+
+// languageCodePage returns the Windows code page used for a language id
+func languageCodePage(lid uint16) int {
+	switch lid & 0x03FF {
+	case 0x02, 0x19, 0x22, 0x23, 0x2F:
+		return 1251
+	case 0x1A:
+		// Serbian is written in Cyrillic, Croatian in Latin script
+		if lid == 0x0C1A {
+			return 1251
+		}
+		return 1250
+	case 0x05, 0x0E, 0x15, 0x18, 0x1B, 0x1C, 0x24:
+		return 1250
+	case 0x08:
+		return 1253
+	case 0x1F:
+		return 1254
+	case 0x0D:
+		return 1255
+	case 0x01, 0x20, 0x29:
+		return 1256
+	case 0x25, 0x26, 0x27:
+		return 1257
+	case 0x2A:
+		return 1258
+	case 0x1E:
+		return 874
+	case 0x11:
+		return 932
+	case 0x12:
+		return 949
+	case 0x04:
+		if lid == 0x0804 || lid == 0x1004 {
+			return 936
+		}
+		return 950
+	}
+	return 1252
+}
+
+// writeWord6Pieces reads the piece table of a Word 6 document. Documents
+// that were not fast saved have none, and keep their text in one run from
+// fcMin. All text is in the code page of the document.
+func (w *WordOleExtractor) writeWord6Pieces(buffer []byte, codePage int) error {
+	clx, err := w.readFibTable(buffer, buffer, word6Clx)
+	if err != nil {
+		return err
+	}
+	if clx == nil {
+		fcMac := int(binary.LittleEndian.Uint32(buffer[0x001C:]))
+		if fcMac > len(buffer) || fcMac < w.boundaries.FcMin {
+			return corruptError("WordDocument", 0x001C, "text out of range")
+		}
+		if err := w.limits.checkStream("WordDocument", int64(fcMac-w.boundaries.FcMin)); err != nil {
+			return err
+		}
+		w.addWord6Piece(buffer, w.boundaries.FcMin, fcMac-w.boundaries.FcMin, codePage)
+		return nil
+	}
+
+	// Skip the property modifiers before the piece table
+	pos := 0
+	for pos+3 <= len(clx) && clx[pos] == 1 {
+		pos += 3 + int(binary.LittleEndian.Uint16(clx[pos+1:]))
+	}
+	fcClx := int(binary.LittleEndian.Uint32(buffer[word6Clx:]))
+	if pos+5 > len(clx) || clx[pos] != 2 {
+		return corruptError("WordDocument", fcClx+pos, "invalid piece table")
+	}
+	size := int(binary.LittleEndian.Uint32(clx[pos+1:]))
+	plcPcd := clx[pos+5:]
+	if size < 4 || size > len(plcPcd) {
+		return corruptError("WordDocument", fcClx+pos, "invalid piece table")
+	}
+
+	pieces := make([]Piece, (size-4)/12)
+	for i := range pieces {
+		cpStart := int(binary.LittleEndian.Uint32(plcPcd[i*4:]))
+		cpEnd := int(binary.LittleEndian.Uint32(plcPcd[(i+1)*4:]))
+		fc := int(binary.LittleEndian.Uint32(plcPcd[(len(pieces)+1)*4+i*8+2:]))
+		length := cpEnd - cpStart
+		if length < 0 || fc < 0 || fc+length > len(buffer) {
+			return corruptError("WordDocument", fc, "piece %d out of range", i)
+		}
+		pieces[i] = Piece{StartFilePos: fc, TotLength: length, Size: length}
+	}
+
+	// The text of the pieces is only decoded once they are checked
+	if err := w.checkPieces(pieces, "WordDocument"); err != nil {
+		return err
+	}
+	for _, piece := range pieces {
+		if err := w.limits.check(); err != nil {
+			return err
+		}
+		w.addWord6Piece(buffer, piece.StartFilePos, piece.Size, codePage)
+	}
+	return nil
+}
+
+// addWord6Piece adds a piece of 8-bit text, which follows the pieces added
+// before it. Character positions count bytes, so in double byte code pages a
+// character of two bytes takes two positions.
+func (w *WordOleExtractor) addWord6Piece(buffer []byte, fc, length int, codePage int) {
+	piece := Piece{
+		StartFilePos: fc,
+		TotLength:    length,
+		Bpc:          1,
+		Size:         length,
+	}
+	if n := len(w.pieces); n > 0 {
+		piece.StartCp = w.pieces[n-1].EndCp
+		piece.StartStream = w.pieces[n-1].EndStream
+	}
+	if isDoubleByteCodePage(codePage) {
+		piece.Text, piece.offsets = decodeDoubleByte(buffer[fc:fc+length], codePage)
+		piece.Length = length
+	} else {
+		piece.Text = decodeCodePage(buffer[fc:fc+length], codePage)
+		piece.Length = len(utf16.Encode([]rune(piece.Text)))
+	}
+	piece.EndCp = piece.StartCp + piece.Length
+	piece.EndStream = piece.StartStream + piece.Size
+	piece.EndFilePos = piece.StartFilePos + piece.Size
+	w.pieces = append(w.pieces, piece)
+}
+
+// readWord6BinTable returns the FKP pages listed by a Word 6 bin table, whose
+// page numbers are two bytes. The table may list fewer pages than the FIB
+// counts, in which case the others follow on from the last one.
+func (w *WordOleExtractor) readWord6BinTable(buffer []byte, offset int, cpnOffset int) ([]int, error) {
+	plcBte, err := w.readFibTable(buffer, buffer, offset)
+	if err != nil || len(plcBte) < 4 {
+		return nil, err
+	}
+	count := (len(plcBte) - 4) / 6
+	pages := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		pages = append(pages, int(binary.LittleEndian.Uint16(plcBte[(count+1)*4+i*2:])))
+	}
+	cpnBte := int(binary.LittleEndian.Uint16(buffer[cpnOffset:]))
+	for len(pages) > 0 && len(pages) < cpnBte {
+		pages = append(pages, pages[len(pages)-1]+1)
+	}
+	return pages, nil
+}
+
+// word6FkpPage returns an FKP page of the WordDocument stream
+func word6FkpPage(buffer []byte, page int) ([]byte, error) {
+	if page < 0 || (page+1)*512 > len(buffer) {
+		return nil, corruptError("WordDocument", page*512, "invalid FKP page")
+	}
+	return buffer[page*512 : (page+1)*512], nil
+}
+
+// writeWord6CharacterProperties reads the tracked changes from the CHPX FKPs.
+// Word 6 marks both insertions and deletions with one author and date.
+func (w *WordOleExtractor) writeWord6CharacterProperties(buffer []byte) error {
+	pages, err := w.readWord6BinTable(buffer, word6PlcfbteChpx, 0x018E)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := w.limits.check(); err != nil {
+			return err
+		}
+		fkp, err := word6FkpPage(buffer, page)
+		if err != nil {
+			return err
+		}
+		crun := int(fkp[511])
+		for j := 0; j < crun && (crun+1)*4+j < 511; j++ {
+			rgb := int(fkp[(crun+1)*4+j]) * 2
+			if rgb == 0 {
+				continue
+			}
+			cb := int(fkp[rgb])
+			if rgb+1+cb > len(fkp) {
+				return corruptError("WordDocument", page*512+rgb, "invalid CHPX")
+			}
+
+			mark := RevisionMark{
+				StartFilePos: int(binary.LittleEndian.Uint32(fkp[j*4:])),
+				EndFilePos:   int(binary.LittleEndian.Uint32(fkp[(j+1)*4:])),
+			}
+			processWord6Sprms(fkp[rgb+1:rgb+1+cb], func(sprm byte, operand []byte) {
+				switch sprm {
+				case sprm6CFStrikeRM:
+					mark.Deleted = operand[0]&1 == 1
+				case sprm6CFRMark:
+					mark.Inserted = operand[0]&1 == 1
+				case sprm6CIbstRMark:
+					author := int(binary.LittleEndian.Uint16(operand))
+					mark.InsertAuthor, mark.DeleteAuthor = author, author
+				case sprm6CDttmRMark:
+					date := parseDTTM(binary.LittleEndian.Uint32(operand))
+					mark.InsertDate, mark.DeleteDate = date, date
+				}
+			})
+			if mark.Inserted || mark.Deleted {
+				w.revisionMarks = append(w.revisionMarks, mark)
+			}
+		}
+	}
+
+	sort.SliceStable(w.revisionMarks, func(i, j int) bool {
+		return w.revisionMarks[i].StartFilePos < w.revisionMarks[j].StartFilePos
+	})
+	return nil
+}
+
+// writeWord6ParagraphProperties reads the styles and table marks of the
+// paragraphs from the PAPX FKPs. Each run has a seven byte BX, and its PAPX
+// gives its size in words, which include the style index.
+func (w *WordOleExtractor) writeWord6ParagraphProperties(buffer []byte) error {
+	pages, err := w.readWord6BinTable(buffer, word6PlcfbtePapx, 0x0190)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := w.limits.check(); err != nil {
+			return err
+		}
+		fkp, err := word6FkpPage(buffer, page)
+		if err != nil {
+			return err
+		}
+		crun := int(fkp[511])
+		for j := 0; j < crun && (crun+1)*4+j*7 < 511; j++ {
+			rgfc := int(binary.LittleEndian.Uint32(fkp[j*4:]))
+			rgfcNext := int(binary.LittleEndian.Uint32(fkp[(j+1)*4:]))
+			props := ParagraphProperties{StartFilePos: rgfc, EndFilePos: rgfcNext}
+
+			offset := int(fkp[(crun+1)*4+j*7]) * 2
+			if offset > 0 && offset < 511 {
+				size := int(fkp[offset]) * 2
+				if offset+1+size > len(fkp) {
+					return corruptError("WordDocument", page*512+offset, "invalid PAPX")
+				}
+				papx := fkp[offset+1 : offset+1+size]
+				if len(papx) >= 2 {
+					props.istd = int(binary.LittleEndian.Uint16(papx))
+					processWord6Sprms(papx[2:], func(sprm byte, operand []byte) {
+						switch sprm {
+						case sprm6PIstd:
+							props.istd = int(binary.LittleEndian.Uint16(operand))
+						case sprm6PFInTable:
+							props.InTable = operand[0] != 0
+						case sprm6PFTtp:
+							props.Ttp = operand[0] != 0
+							w.replaceSelectedRangeByFilePos(rgfc, rgfcNext, "\n")
+						case sprm6TDefTable, sprm6TDefTable10:
+							// Word 6 TCs are the flags and four borders
+							props.cells = readTableDefinition(operand, 10)
+						}
+					})
+				}
+			}
+			w.paragraphs = append(w.paragraphs, props)
+		}
+	}
+
+	sort.SliceStable(w.paragraphs, func(i, j int) bool {
+		return w.paragraphs[i].StartFilePos < w.paragraphs[j].StartFilePos
+	})
+	return nil
+}
+
+// writeWord6Styles reads the names and base styles of the paragraph styles.
+// Word 6 style names are Pascal strings in the code page of the document,
+// and there are no outline levels or lists, so headings are only known by
+// their built-in style.
+func (w *WordOleExtractor) writeWord6Styles(buffer []byte, codePage int) error {
+	stsh, err := w.readFibTable(buffer, buffer, word6Stshf)
+	if err != nil || len(stsh) < 6 {
+		return err
+	}
+	cbStshi := int(binary.LittleEndian.Uint16(stsh))
+	cstd := int(binary.LittleEndian.Uint16(stsh[2:]))
+	cbStdBase := int(binary.LittleEndian.Uint16(stsh[4:]))
+
+	offset := 2 + cbStshi
+	w.styles = make([]oleStyle, cstd)
+	for i := 0; i < cstd && offset+2 <= len(stsh); i++ {
+		cbStd := int(binary.LittleEndian.Uint16(stsh[offset:]))
+		offset += 2
+		if offset+cbStd > len(stsh) {
+			break
+		}
+		w.styles[i] = oleStyle{base: 0x0FFF}
+		std := stsh[offset : offset+cbStd]
+		offset += cbStd
+		if len(std) < 4 || cbStdBase < 4 || cbStdBase >= len(std) {
+			continue
+		}
+		style := &w.styles[i]
+		style.sti = int(binary.LittleEndian.Uint16(std) & 0x0FFF)
+		style.base = int(binary.LittleEndian.Uint16(std[2:]) >> 4)
+		if cch := int(std[cbStdBase]); cbStdBase+1+cch <= len(std) {
+			style.name = decodeCodePage(std[cbStdBase+1:cbStdBase+1+cch], codePage)
+		}
+	}
+	return nil
+}
+
+// writeWord6RevisionAuthors reads the authors of the tracked changes
+func (w *WordOleExtractor) writeWord6RevisionAuthors(buffer []byte, codePage int) error {
+	sttbf, err := w.readFibTable(buffer, buffer, word6SttbfRMark)
+	if err != nil || len(sttbf) < 2 {
+		return err
+	}
+	w.authors = readWord6Strings(sttbf[2:], codePage)
+	return nil
+}
+
+// readWord6Strings reads a list of Pascal strings, such as the strings of a
+// Word 6 STTBF after its size
+func readWord6Strings(data []byte, codePage int) []string {
+	var values []string
+	for offset := 0; offset < len(data); {
+		length := int(data[offset])
+		offset++
+		if offset+length > len(data) {
+			break
+		}
+		values = append(values, decodeCodePage(data[offset:offset+length], codePage))
+		offset += length
+	}
+	return values
+}
+
+// writeWord6Comments reads the comments from the annotation reference table.
+// Each reference has a 20 byte ATRD, with the initials as a Pascal string.
+// Word 6 comments are anchored at their reference, and have no date.
+func (w *WordOleExtractor) writeWord6Comments(buffer []byte, codePage int) error {
+	plcfandRef, err := w.readFibTable(buffer, buffer, word6PlcfandRef)
+	if err != nil || len(plcfandRef) < 4 {
+		return err
+	}
+	plcfandTxt, err := w.readFibTable(buffer, buffer, word6PlcfandTxt)
+	if err != nil {
+		return err
+	}
+	grpStAtnOwners, err := w.readFibTable(buffer, buffer, word6GrpStAtnOwnr)
+	if err != nil {
+		return err
+	}
+	owners := readWord6Strings(grpStAtnOwners, codePage)
+
+	count := (len(plcfandRef) - 4) / 24
+	dataOffset := (count + 1) * 4
+	for i := 0; i < count; i++ {
+		atrd := plcfandRef[dataOffset+i*20 : dataOffset+(i+1)*20]
+		a := annotation{refCp: int(binary.LittleEndian.Uint32(plcfandRef[i*4:])), parent: -1}
+		a.startCp, a.endCp = a.refCp, a.refCp
+		if length := int(atrd[0]); length <= 9 {
+			a.initials = decodeCodePage(atrd[1:1+length], codePage)
+		}
+		if ibst := int(binary.LittleEndian.Uint16(atrd[10:])); ibst < len(owners) {
+			a.author = owners[ibst]
+		}
+		if (i+2)*4 <= len(plcfandTxt) {
+			a.textStart = int(binary.LittleEndian.Uint32(plcfandTxt[i*4:]))
+			a.textEnd = int(binary.LittleEndian.Uint32(plcfandTxt[(i+1)*4:]))
+		}
+		w.annotations = append(w.annotations, a)
+	}
+	return nil
+}
+
+// normalizeWord6Headers tags the stories of the header document. Word 6 only
+// keeps the stories that are set: the note separators flagged in the DOP,
+// then for each section the headers and footers flagged in its SEPX.
+func (w *WordOleExtractor) normalizeWord6Headers(buffer []byte) error {
+	plcHdd, err := w.readFibTable(buffer, buffer, word6Plcfhdd)
+	if err != nil || len(plcHdd) < 8 {
+		return err
+	}
+
+	var types []string
+	dop, err := w.readFibTable(buffer, buffer, word6Dop)
+	if err != nil {
+		return err
+	}
+	if len(dop) >= 2 {
+		for bit := 0; bit < 6; bit++ {
+			if dop[1]&(1<<bit) == 0 {
+				continue
+			}
+			if bit < 3 {
+				types = append(types, "footnoteSeparators")
+			} else {
+				types = append(types, "endSeparators")
+			}
+		}
+	}
+
+	// Stories of each section come in the order even header, odd header,
+	// even footer, odd footer, first header and first footer
+	sectionTypes := [6]string{"headers", "headers", "footers", "footers", "headers", "footers"}
+	for _, flags := range w.readWord6SectionHeaders(buffer) {
+		for bit := 0; bit < 6; bit++ {
+			if flags&(1<<bit) != 0 {
+				types = append(types, sectionTypes[bit])
+			}
+		}
+	}
+
+	w.splitHeaders(plcHdd, func(story int) string {
+		if story < len(types) {
+			return types[story]
+		}
+		return ""
+	})
+	return nil
+}
+
+// readWord6SectionHeaders returns the flags of the headers and footers that
+// each section has, from the sprmSGprfIhdt of its SEPX
+func (w *WordOleExtractor) readWord6SectionHeaders(buffer []byte) []byte {
+	plcfsed, err := w.readFibTable(buffer, buffer, word6Plcfsed)
+	if err != nil || len(plcfsed) < 4 {
+		return nil
+	}
+	count := (len(plcfsed) - 4) / 16
+	flags := make([]byte, count)
+	for i := range flags {
+		sed := plcfsed[(count+1)*4+i*12:]
+		fcSepx := binary.LittleEndian.Uint32(sed[2:])
+		if fcSepx == 0xFFFFFFFF || int(fcSepx)+2 > len(buffer) {
+			continue
+		}
+		cb := int(binary.LittleEndian.Uint16(buffer[fcSepx:]))
+		start := int(fcSepx) + 2
+		if start+cb > len(buffer) {
+			continue
+		}
+		processWord6Sprms(buffer[start:start+cb], func(sprm byte, operand []byte) {
+			if sprm == sprm6SGprfIhdt {
+				flags[i] = operand[0]
+			}
+		})
+	}
+	return flags
+}
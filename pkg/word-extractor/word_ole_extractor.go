@@ -2,16 +2,22 @@ package word_extractor
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 	"unicode/utf16"
+	"unicode/utf8"
 
-	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/msoleps"
+	"github.com/richardlehane/msoleps/types"
 )
 
 type unbufferedReaderAt struct {
@@ -40,15 +46,56 @@ func (u *unbufferedReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
 }
 
 const (
-	sprmCFRMarkDel = 0x00
+	sprmCFRMarkDel       = 0x0800
+	sprmCFRMarkIns       = 0x0801
+	sprmCIbstRMark       = 0x4804
+	sprmCDttmRMark       = 0x6805
+	sprmCIbstRMarkDel    = 0x4863
+	sprmCDttmRMarkDel    = 0x6864
+	sprmPFInTable        = 0x2416
+	sprmPFTtp            = 0x2417
+	sprmPFInnerTableCell = 0x244B
+	sprmPFInnerTtp       = 0x244C
+	sprmPItap            = 0x6649
+	sprmPIstd            = 0x4600
+	sprmPOutLvl          = 0x2640
+	sprmPIlvl            = 0x260A
+	sprmPIlfo            = 0x460B
+	sprmTDefTable        = 0xD608
+	sprmCFData           = 0x0806
+	sprmCFOle2           = 0x080A
+	sprmCFSpec           = 0x0855
+	sprmCPicLocation     = 0x6A03
 )
 
 // WordOleExtractor handles extraction of text from OLE-based Word files
 type WordOleExtractor struct {
+	// Options configures how documents are extracted
+	Options ExtractOptions
+
+	// tableStream is the name of the stream the FIB tables are in
+	tableStream string
+	limits      *limiter
+
 	pieces        []Piece
 	bookmarks     map[string]Bookmark
 	boundaries    Boundaries
 	taggedHeaders []TaggedHeader
+	paragraphs    []ParagraphProperties
+	styles        []oleStyle
+	lists         map[int]*listDefinition
+	listOverrides []oleListOverride
+	revisionMarks []RevisionMark
+	authors       []string
+	annotations   []annotation
+	footnoteRefs  []noteReference
+	endnoteRefs   []noteReference
+	noteMarkers   map[int]string
+	pictureRuns   []pictureRun
+	dataStream    []byte
+	blips         []*Image
+	shapeAnchors  map[int]uint32
+	shapes        map[uint32][]artShape
 }
 
 type Piece struct {
@@ -64,6 +111,31 @@ type Piece struct {
 	EndCp        int
 	EndStream    int
 	EndFilePos   int
+
+	// offsets gives the offset from StartCp of each UTF-16 unit of Text,
+	// for text in a double byte code page, whose character positions count
+	// bytes. It is nil when each unit is one character position.
+	offsets []int
+}
+
+// unitOffset returns the offset from StartCp of a UTF-16 unit of the text
+func (p *Piece) unitOffset(i int) int {
+	switch {
+	case p.offsets == nil:
+		return i
+	case i >= len(p.offsets):
+		return p.Length
+	}
+	return p.offsets[i]
+}
+
+// unitIndex returns the index of the first UTF-16 unit of the text at or
+// after an offset from StartCp
+func (p *Piece) unitIndex(offset int) int {
+	if p.offsets == nil {
+		return offset
+	}
+	return sort.SearchInts(p.offsets, offset)
 }
 
 type Bookmark struct {
@@ -76,17 +148,171 @@ type Boundaries struct {
 	CcpText    int
 	CcpFtn     int
 	CcpHdd     int
+	CcpMcr     int
 	CcpAtn     int
 	CcpEdn     int
 	CcpTxbx    int
 	CcpHdrTxbx int
 }
 
+// characters returns the number of characters of all the stories. The piece
+// table may hold one more, the paragraph mark that ends the last story.
+func (b Boundaries) characters() int {
+	return b.CcpText + b.CcpFtn + b.CcpHdd + b.CcpMcr + b.CcpAtn + b.CcpEdn + b.CcpTxbx + b.CcpHdrTxbx
+}
+
 type TaggedHeader struct {
 	Type string
 	Text string
 }
 
+// ParagraphProperties holds the paragraph properties read from a PAPX FKP
+// run, covering the file positions [StartFilePos, EndFilePos)
+type ParagraphProperties struct {
+	StartFilePos int
+	EndFilePos   int
+	InTable      bool
+	Ttp          bool
+	InnerCell    bool
+	InnerTtp     bool
+	Itap         int
+
+	// cells holds the cell layout from the table definition of a row mark
+	cells []cellFormat
+
+	// istd is the index of the paragraph style, and outLvl the outline level
+	// set directly on the paragraph when hasOutLvl is set
+	istd      int
+	outLvl    int
+	hasOutLvl bool
+	// ilfo is the 1-based index of the list override that numbers the
+	// paragraph, and ilvl its list level, both read when hasList is set
+	ilfo    int
+	ilvl    int
+	hasList bool
+}
+
+// oleStyle is a paragraph style from the STSH style sheet
+type oleStyle struct {
+	name      string
+	sti       int
+	base      int
+	outLvl    int
+	hasOutLvl bool
+	ilfo      int
+	ilvl      int
+	hasList   bool
+}
+
+// oleListOverride is a list override (LFO), which numbers paragraphs with
+// the list definition of lsid and may restart some of its levels
+type oleListOverride struct {
+	lsid   int
+	starts map[int]int
+}
+
+// depth returns the table nesting depth of the paragraph
+func (p ParagraphProperties) depth() int {
+	if p.Itap > 0 {
+		return p.Itap
+	}
+	if p.InTable {
+		return 1
+	}
+	return 0
+}
+
+// rowEnd tells whether a character ends a table row
+func (p ParagraphProperties) rowEnd(r rune) bool {
+	return (p.Ttp && (r == 0x07 || r == '\n')) || (p.InnerTtp && r == '\r')
+}
+
+// cellEnd tells whether a character ends a table cell
+func (p ParagraphProperties) cellEnd(r rune) bool {
+	return r == 0x07 || (p.InnerCell && r == '\r')
+}
+
+// RevisionMark records a run of text that carries tracked change properties,
+// covering the file positions [StartFilePos, EndFilePos)
+type RevisionMark struct {
+	StartFilePos int
+	EndFilePos   int
+	Inserted     bool
+	Deleted      bool
+	InsertAuthor int
+	InsertDate   time.Time
+	DeleteAuthor int
+	DeleteDate   time.Time
+}
+
+// revisionType returns the type used to list and mark up the run. Text that
+// was inserted and then deleted counts as a deletion.
+func (m *RevisionMark) revisionType() RevisionType {
+	if m.Deleted {
+		return Deletion
+	}
+	return Insertion
+}
+
+// sameRevisionMark tells whether two runs of text belong to the same tracked
+// change. Word splits changes over many character runs.
+func sameRevisionMark(a, b *RevisionMark) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Inserted == b.Inserted && a.Deleted == b.Deleted &&
+		a.InsertAuthor == b.InsertAuthor && a.InsertDate.Equal(b.InsertDate) &&
+		a.DeleteAuthor == b.DeleteAuthor && a.DeleteDate.Equal(b.DeleteDate)
+}
+
+// annotation is a comment read from the annotation tables, with its anchor
+// and text given as character positions. The text positions are relative to
+// the start of the annotation story.
+type annotation struct {
+	refCp     int
+	startCp   int
+	endCp     int
+	textStart int
+	textEnd   int
+	initials  string
+	author    string
+	date      time.Time
+	parent    int
+}
+
+// noteReference is a footnote or endnote reference, with the range of its
+// text relative to the start of the note story
+type noteReference struct {
+	refCp     int
+	textStart int
+	textEnd   int
+}
+
+// pictureRun is a run of text holding inline pictures, covering the file
+// positions [start, end). The picture data is at location in the Data stream.
+type pictureRun struct {
+	start    int
+	end      int
+	location int
+}
+
+// oleAnchor is a character of a story that anchors a picture or a drawing,
+// with its offset in the rendered text
+type oleAnchor struct {
+	r      rune
+	cp     int
+	fc     int
+	offset int
+}
+
+// oleChar is a single character of the document text, with its character
+// position and file position
+type oleChar struct {
+	r  rune
+	cp int
+	fc int
+}
+
 // NewWordOleExtractor creates a new WordOleExtractor instance
 func NewWordOleExtractor() *WordOleExtractor {
 	return &WordOleExtractor{
@@ -98,59 +324,290 @@ func NewWordOleExtractor() *WordOleExtractor {
 
 // Extract implements the DocumentExtractor interface
 func (w *WordOleExtractor) Extract(reader io.ReadSeeker) (*Document, error) {
+	return w.ExtractContext(context.Background(), reader)
+}
+
+// ExtractContext extracts a document like Extract, and stops with the error
+// of the context once it is done
+func (w *WordOleExtractor) ExtractContext(ctx context.Context, reader io.ReadSeeker) (*Document, error) {
+	return w.extractLimited(newLimiter(ctx, w.Options.Limits), reader)
+}
+
+// extractLimited extracts a document within the limiter of the extraction it
+// is part of
+func (w *WordOleExtractor) extractLimited(limits *limiter, reader io.ReadSeeker) (*Document, error) {
+	w.limits = limits
+	if err := w.limits.checkInput(reader); err != nil {
+		return nil, err
+	}
+
+	// Other compound files, such as spreadsheets, have no WordDocument stream
+	buffer, err := readStream(w.limits, reader, "WordDocument")
+	if errors.Is(err, errMissingStream) {
+		return nil, &ExtractError{Part: "WordDocument", Offset: -1, Err: fmt.Errorf("%w: not a Word document", ErrUnsupportedFormat)}
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	buffer, err := readStream(reader, "WordDocument")
+	doc, err := w.extractWordDocument(reader, buffer)
 	if err != nil {
 		return nil, err
 	}
+	w.readMetadata(reader, &doc.Metadata)
+	doc.objects = w.readObjectPool(reader)
+
+	// Templates are flagged in the FIB, and macros are kept in the Macros
+	// storage
+	doc.Format = FormatDoc
+	if binary.LittleEndian.Uint16(buffer[0x0A:0x0C])&0x0001 != 0 {
+		doc.Format = FormatDot
+	}
+	doc.HasMacros = hasCompoundStorage(reader, "Macros")
+	if err := w.limits.checkOutput(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// readObjectPool reads the embedded objects, each kept in a storage of the
+// ObjectPool storage. Objects are optional, so they are skipped when they
+// cannot be read.
+func (w *WordOleExtractor) readObjectPool(reader io.ReadSeeker) []*EmbeddedObject {
+	entries, err := readCompoundFile(w.limits, reader, "ObjectPool")
+	if err != nil {
+		return nil
+	}
+	var objects []*EmbeddedObject
+	for _, e := range entries {
+		if !e.storage || len(e.path) != 1 {
+			continue
+		}
+		object, err := compoundObject(e.name, "", compoundStorage(entries, []string{"ObjectPool", e.name}), nil)
+		if err == nil {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// readMetadata reads the document properties from the summary information
+// streams. These are optional, so a stream that is missing or cannot be read
+// is skipped.
+func (w *WordOleExtractor) readMetadata(reader io.ReadSeeker, meta *Metadata) {
+	if data, err := readStream(w.limits, reader, "SummaryInformation"); err == nil {
+		readPropertySets(data, func(name string, value types.Type, user bool) {
+			switch name {
+			case "Title":
+				meta.Title = propertyText(value)
+			case "Subject":
+				meta.Subject = propertyText(value)
+			case "Author":
+				meta.Author = propertyText(value)
+			case "Keywords":
+				meta.Keywords = propertyText(value)
+			case "Comments":
+				meta.Comments = propertyText(value)
+			case "Template":
+				meta.Template = propertyText(value)
+			case "LastAuthor":
+				meta.LastModifiedBy = propertyText(value)
+			case "RevNumber":
+				meta.Revision = propertyText(value)
+			case "CreateTime":
+				meta.Created = propertyTime(value)
+			case "LastSaveTime":
+				meta.Modified = propertyTime(value)
+			case "PageCount":
+				meta.Pages = parseCount(propertyText(value))
+			case "WordCount":
+				meta.Words = parseCount(propertyText(value))
+			case "CharCount":
+				meta.Characters = parseCount(propertyText(value))
+			case "AppName":
+				meta.Application = propertyText(value)
+			}
+		})
+	}
+
+	if data, err := readStream(w.limits, reader, "DocumentSummaryInformation"); err == nil {
+		readPropertySets(data, func(name string, value types.Type, user bool) {
+			switch {
+			case user:
+				// The second property set holds the custom properties. Word
+				// also keeps hidden binary values there, named _PID_...
+				switch {
+				case name == "", name == "CodePage", name == "Dictionary", name == "Locale", name == "Behaviour":
+				case strings.HasPrefix(name, "_PID_"):
+				default:
+					meta.setCustom(name, propertyText(value))
+				}
+			case name == "Company":
+				meta.Company = propertyText(value)
+			case name == "Category":
+				meta.Category = propertyText(value)
+			}
+		})
+	}
+}
+
+// readPropertySets reads the properties of a property set stream, telling
+// for each one whether it is in the second, user defined, set
+func readPropertySets(data []byte, visit func(name string, value types.Type, user bool)) {
+	// The property set reader does not check its offsets, and metadata is
+	// not worth failing the extraction for
+	defer func() {
+		recover()
+	}()
+
+	// It also makes room for as many properties as a set claims to have, so
+	// sets that claim more than their stream can hold are not read
+	if len(data) < 48 {
+		return
+	}
+	sets := int(binary.LittleEndian.Uint32(data[24:]))
+	for i := 0; i < sets && 28+i*20+20 <= len(data); i++ {
+		offset := int(binary.LittleEndian.Uint32(data[28+i*20+16:]))
+		if offset < 0 || offset > len(data)-8 || int(binary.LittleEndian.Uint32(data[offset+4:])) > (len(data)-offset)/8 {
+			return
+		}
+	}
+
+	props, err := msoleps.NewFrom(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	// The properties of the first set come first
+	first := len(props.Property)
+	if offset := int(binary.LittleEndian.Uint32(data[44:])); offset+8 <= len(data) {
+		first = int(binary.LittleEndian.Uint32(data[offset+4:]))
+	}
+	for i, prop := range props.Property {
+		if prop != nil && prop.T != nil {
+			visit(prop.Name, prop.T, i >= first)
+		}
+	}
+}
+
+// propertyText returns the value of a property as text
+func propertyText(value types.Type) string {
+	switch v := value.(type) {
+	case types.FileTime:
+		return v.Time().UTC().Format(time.RFC3339)
+	case *types.CodeString:
+		// Strings in a code page other than UTF-16 are returned as bytes
+		text := v.String()
+		if !utf8.ValidString(text) {
+			chars := make([]rune, len(text))
+			for i := 0; i < len(text); i++ {
+				chars[i] = rune(text[i])
+			}
+			text = binaryToUnicode(string(chars[:len(text)]))
+		}
+		return text
+	}
+	return value.String()
+}
 
-	return w.extractWordDocument(reader, buffer)
+// propertyTime returns the value of a date property, or the zero time when
+// it is not set
+func propertyTime(value types.Type) time.Time {
+	if v, ok := value.(types.FileTime); ok && (v.Low != 0 || v.High != 0) {
+		return v.Time().UTC()
+	}
+	return time.Time{}
 }
 
 func (w *WordOleExtractor) extractWordDocument(reader io.ReadSeeker, buffer []byte) (*Document, error) {
+	// Word 6.0 and Word 95 files have an older FIB, told apart by its nFib
+	if len(buffer) >= 4 {
+		if nFib := binary.LittleEndian.Uint16(buffer[2:4]); nFib >= word6Fib && nFib < word97Fib {
+			return w.extractWord6Document(buffer)
+		}
+	}
+
 	// Check magic number (0xA5EC)
-	magic := binary.LittleEndian.Uint16(buffer[0:2])
+	fib := newStructReader("WordDocument", "FIB", buffer)
+	magic := fib.uint16(0)
+	if err := fib.err(); err != nil {
+		return nil, err
+	}
 	if magic != 0xA5EC {
-		return nil, errors.New("invalid Word document: incorrect magic number")
+		return nil, corruptError("WordDocument", 0, "incorrect magic number")
 	}
 
 	// Get flags and determine table stream name
-	flags := binary.LittleEndian.Uint16(buffer[0x0A:0x0C])
+	flags := fib.uint16(0x0A)
 	streamName := "1Table"
 	if (flags & 0x0200) == 0 {
 		streamName = "0Table"
 	}
+	w.tableStream = streamName
 
-	tableBuffer, err := readStream(reader, streamName)
+	tableBuffer, err := readStream(w.limits, reader, streamName)
 	if err != nil {
 		return nil, err
 	}
 
+	// The Data stream holds inline pictures, and is only there when the
+	// document has some
+	w.dataStream, _ = readStream(w.limits, reader, "Data")
+
+	if flags&fibEncrypted != 0 {
+		if buffer, tableBuffer, w.dataStream, err = decryptWordStreams(buffer, tableBuffer, w.dataStream, streamName, w.Options.Password); err != nil {
+			return nil, err
+		}
+	}
+
 	// Extract document boundaries
+	fib, _ = w.structReaders(buffer, tableBuffer)
 	w.boundaries = Boundaries{
-		FcMin:      int(binary.LittleEndian.Uint32(buffer[0x0018:0x001C])),
-		CcpText:    int(binary.LittleEndian.Uint32(buffer[0x004C:0x0050])),
-		CcpFtn:     int(binary.LittleEndian.Uint32(buffer[0x0050:0x0054])),
-		CcpHdd:     int(binary.LittleEndian.Uint32(buffer[0x0054:0x0058])),
-		CcpAtn:     int(binary.LittleEndian.Uint32(buffer[0x005C:0x0060])),
-		CcpEdn:     int(binary.LittleEndian.Uint32(buffer[0x0060:0x0064])),
-		CcpTxbx:    int(binary.LittleEndian.Uint32(buffer[0x0064:0x0068])),
-		CcpHdrTxbx: int(binary.LittleEndian.Uint32(buffer[0x0068:0x006C])),
+		FcMin:      int(fib.uint32(0x0018)),
+		CcpText:    int(fib.uint32(0x004C)),
+		CcpFtn:     int(fib.uint32(0x0050)),
+		CcpHdd:     int(fib.uint32(0x0054)),
+		CcpMcr:     int(fib.uint32(0x0058)),
+		CcpAtn:     int(fib.uint32(0x005C)),
+		CcpEdn:     int(fib.uint32(0x0060)),
+		CcpTxbx:    int(fib.uint32(0x0064)),
+		CcpHdrTxbx: int(fib.uint32(0x0068)),
+	}
+	if err := fib.err(); err != nil {
+		return nil, err
 	}
 
 	// Extract document components
+	if err := w.writeRevisionAuthors(buffer, tableBuffer); err != nil {
+		return nil, err
+	}
 	if err := w.writeBookmarks(buffer, tableBuffer); err != nil {
 		return nil, err
 	}
+	if err := w.writeComments(buffer, tableBuffer); err != nil {
+		return nil, err
+	}
+	if err := w.writeNotes(buffer, tableBuffer, word97NoteTables); err != nil {
+		return nil, err
+	}
 	if err := w.writePieces(buffer, tableBuffer); err != nil {
 		return nil, err
 	}
 	if err := w.writeCharacterProperties(buffer, tableBuffer); err != nil {
 		return nil, err
 	}
+	if err := w.writeStyles(buffer, tableBuffer); err != nil {
+		return nil, err
+	}
+	if err := w.writeLists(buffer, tableBuffer); err != nil {
+		return nil, err
+	}
 	if err := w.writeParagraphProperties(buffer, tableBuffer); err != nil {
 		return nil, err
 	}
+	if err := w.writeDrawings(buffer, tableBuffer); err != nil {
+		return nil, err
+	}
 	if err := w.normalizeHeaders(buffer, tableBuffer); err != nil {
 		return nil, err
 	}
@@ -166,13 +623,27 @@ func (w *WordOleExtractor) buildDocument() (*Document, error) {
 	// fmt.Printf("End: %d\n", start+w.boundaries.CcpText)
 	// fmt.Printf("Body length: %d\n", len(w.getTextRangeByCP(start, start+w.boundaries.CcpText)))
 
-	// Extract body text
-	doc.Body = cleanText(w.getTextRangeByCP(start, start+w.boundaries.CcpText))
+	// Extract body text, keeping its paragraph and table structure
+	body, err := w.buildStory(start, start+w.boundaries.CcpText)
+	if err != nil {
+		return nil, err
+	}
+	doc.Body = body.String()
+	doc.Blocks = body.Blocks()
+	doc.revisions = body.revisions
+	doc.fields = body.fields
+	doc.hyperlinks = body.hyperlinks
+	doc.images = w.buildImages(body)
 	start += w.boundaries.CcpText
+	doc.comments = w.buildComments(body, start+w.boundaries.CcpFtn+w.boundaries.CcpHdd)
+	doc.footnotes = w.buildNotes(body, w.footnoteRefs, start)
+	doc.endnotes = w.buildNotes(body, w.endnoteRefs, start+w.boundaries.CcpFtn+w.boundaries.CcpHdd+w.boundaries.CcpAtn)
 
 	// Extract footnotes if present
 	if w.boundaries.CcpFtn > 0 {
-		doc.Footnotes = cleanText(w.getTextRangeByCP(start, start+w.boundaries.CcpFtn-1))
+		if doc.Footnotes, err = w.storyText(start, start+w.boundaries.CcpFtn-1); err != nil {
+			return nil, err
+		}
 		start += w.boundaries.CcpFtn
 	}
 
@@ -189,126 +660,703 @@ func (w *WordOleExtractor) buildDocument() (*Document, error) {
 		}
 		doc.Headers = cleanText(join(headers, ""))
 		doc.Footers = cleanText(join(footers, ""))
+		if err := w.limits.addOutput(utf8.RuneCountInString(doc.Headers) + utf8.RuneCountInString(doc.Footers)); err != nil {
+			return nil, err
+		}
 		start += w.boundaries.CcpHdd
 	}
 
 	// Extract annotations if present
 	if w.boundaries.CcpAtn > 0 {
-		doc.Annotations = cleanText(w.getTextRangeByCP(start, start+w.boundaries.CcpAtn-1))
+		if doc.Annotations, err = w.storyText(start, start+w.boundaries.CcpAtn-1); err != nil {
+			return nil, err
+		}
 		start += w.boundaries.CcpAtn
 	}
 
 	// Extract endnotes if present
 	if w.boundaries.CcpEdn > 0 {
-		doc.Endnotes = cleanText(w.getTextRangeByCP(start, start+w.boundaries.CcpEdn-1))
+		if doc.Endnotes, err = w.storyText(start, start+w.boundaries.CcpEdn-1); err != nil {
+			return nil, err
+		}
 		start += w.boundaries.CcpEdn
 	}
 
 	// Extract textboxes if present
 	if w.boundaries.CcpTxbx > 0 {
-		doc.Textboxes = cleanText(w.getTextRangeByCP(start, start+w.boundaries.CcpTxbx-1))
+		if doc.Textboxes, err = w.storyText(start, start+w.boundaries.CcpTxbx-1); err != nil {
+			return nil, err
+		}
 		start += w.boundaries.CcpTxbx
 	}
 
 	// Extract header textboxes if present
 	if w.boundaries.CcpHdrTxbx > 0 {
-		doc.HeaderTextboxes = cleanText(w.getTextRangeByCP(start, start+w.boundaries.CcpHdrTxbx-1))
+		if doc.HeaderTextboxes, err = w.storyText(start, start+w.boundaries.CcpHdrTxbx-1); err != nil {
+			return nil, err
+		}
 		start += w.boundaries.CcpHdrTxbx
 	}
-
-	return doc, nil
+
+	return doc, nil
+}
+
+// storyText returns the text between two character positions of a story
+// other than the body, counting its characters against the limits
+func (w *WordOleExtractor) storyText(start, end int) (string, error) {
+	text := cleanText(w.getRevisedTextByCP(start, end))
+	if err := w.limits.addOutput(utf8.RuneCountInString(text)); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// oleStory is a story of a .doc file rendered through a storyBuilder, along
+// with the tracked changes, fields and links found in it
+type oleStory struct {
+	*storyBuilder
+	revisions  []Revision
+	fields     []*Field
+	hyperlinks []*Hyperlink
+	anchors    []oleAnchor
+
+	// cps and offsets map the character positions of the story to offsets
+	// in its rendered text
+	cps     []int
+	offsets []int
+}
+
+// offsetAt returns the offset in the rendered text of a character position,
+// or of the first character after it that is kept
+func (s *oleStory) offsetAt(cp int) int {
+	i := sort.SearchInts(s.cps, cp)
+	if i < len(s.offsets) {
+		return s.offsets[i]
+	}
+	return s.offset()
+}
+
+// buildStory renders the text between two character positions through a
+// storyBuilder. Paragraph marks, cell marks and table row marks are turned
+// into paragraphs and table cells, field instructions are hidden, and tracked
+// changes are applied, so that the rendered text matches what cleanText makes
+// of the same range. The limits are checked as the characters are rendered,
+// as a story may be long.
+func (w *WordOleExtractor) buildStory(start, end int) (*oleStory, error) {
+	chars := w.getCharsByCP(start, end)
+	runes := make([]rune, len(chars))
+	for i, c := range chars {
+		runes[i] = c.r
+	}
+	visible, fields := visibleFieldChars(runes)
+
+	b := newStoryBuilder(w.limits)
+	story := &oleStory{storyBuilder: b, cps: make([]int, len(chars)), offsets: make([]int, len(chars))}
+	tracker := w.newRevisionTracker()
+	numbering := newListNumbering()
+	atStart := true
+	var props ParagraphProperties
+	for i, c := range chars {
+		if i%4096 == 0 {
+			if err := w.limits.check(); err != nil {
+				return nil, err
+			}
+		}
+		story.cps[i], story.offsets[i] = c.cp, b.offset()
+		if atStart {
+			props = w.paragraphPropertiesAt(c.fc)
+			b.setDepth(props.depth())
+			if b.depth() > 0 && !props.Ttp && !props.InnerTtp {
+				b.ensureCell()
+			}
+			b.startParagraph()
+			atStart = false
+		}
+		if !visible[i] {
+			continue
+		}
+
+		// A section break ends its paragraph like a paragraph mark does
+		paraEnd := c.r == '\r' || c.r == 0x0C && w.endsParagraph(chars, i)
+		mark := w.revisionMarkAt(c.fc)
+		if props.rowEnd(c.r) || props.cellEnd(c.r) || paraEnd {
+			// Revision markers are closed within the paragraph, and a
+			// hidden paragraph mark joins its paragraph to the next
+			b.write(tracker.leave())
+			if !tracker.shows(mark) {
+				continue
+			}
+		} else {
+			if !sameRevisionMark(mark, tracker.current) {
+				b.write(tracker.leave())
+				b.write(tracker.enter(mark, b.offset()))
+			}
+			tracker.collect(cleanChar(c.r))
+			if !tracker.shows(mark) {
+				continue
+			}
+		}
+
+		switch {
+		case props.rowEnd(c.r):
+			b.formatRow(props.cells)
+			b.endRow()
+			atStart = true
+		case props.cellEnd(c.r):
+			w.setParagraphStyle(b.endParagraph(), props, numbering)
+			b.endCell()
+			atStart = true
+		case paraEnd:
+			w.setParagraphStyle(b.endParagraph(), props, numbering)
+			atStart = true
+		default:
+			b.write(w.noteMarkers[c.cp])
+			if c.r == 0x01 || c.r == 0x08 {
+				story.anchors = append(story.anchors, oleAnchor{r: c.r, cp: c.cp, fc: c.fc, offset: b.offset()})
+			}
+			b.write(cleanChar(c.r))
+		}
+	}
+	b.write(tracker.leave())
+	b.finish()
+	story.revisions = tracker.found
+
+	// Field results keep their place in the text, so fields and links can
+	// be found from the offsets of their marks
+	for _, span := range fields {
+		field := parseField(fieldInstruction(runes, span))
+		field.Depth, field.begin = span.depth, span.begin
+		field.Offset = story.offsets[span.resultStart()]
+		field.Length = story.offsets[span.end] - field.Offset
+		story.fields = append(story.fields, field)
+		if link, ok := fieldHyperlink(field); ok && span.separator >= 0 {
+			story.hyperlinks = append(story.hyperlinks, link)
+		}
+	}
+	text := []rune(b.String())
+	sortFields(story.fields)
+	setFieldResults(story.fields, text)
+	setHyperlinkText(story.hyperlinks, text)
+	return story, nil
+}
+
+// endsParagraph tells whether a character is the last of the paragraph
+// properties run that holds it
+func (w *WordOleExtractor) endsParagraph(chars []oleChar, i int) bool {
+	props := w.paragraphPropertiesAt(chars[i].fc)
+	if props.EndFilePos == 0 {
+		return false
+	}
+	if i+1 == len(chars) {
+		return true
+	}
+	next := chars[i+1].fc
+	return next < props.StartFilePos || next >= props.EndFilePos
+}
+
+// getRevisedTextByCP returns the raw text between two character positions
+// with tracked changes applied, ready to be passed to cleanText
+func (w *WordOleExtractor) getRevisedTextByCP(start, end int) string {
+	tracker := w.newRevisionTracker()
+	var result strings.Builder
+	for _, c := range w.getCharsByCP(start, end) {
+		mark := w.revisionMarkAt(c.fc)
+		if c.r == '\r' || c.r == 0x07 {
+			result.WriteString(tracker.leave())
+		} else if !sameRevisionMark(mark, tracker.current) {
+			result.WriteString(tracker.leave())
+			result.WriteString(tracker.enter(mark, 0))
+		}
+		if tracker.shows(mark) {
+			result.WriteRune(c.r)
+		}
+	}
+	result.WriteString(tracker.leave())
+	return result.String()
+}
+
+// revisionTracker follows the tracked changes in a run of .doc text, one
+// character at a time. It produces the markers written by ShowRevisions and
+// collects each revision it passes through.
+type revisionTracker struct {
+	mode    RevisionMode
+	authors []string
+	current *RevisionMark
+	open    *Revision
+	found   []Revision
+}
+
+func (w *WordOleExtractor) newRevisionTracker() *revisionTracker {
+	return &revisionTracker{mode: w.Options.Revisions, authors: w.authors}
+}
+
+// enter starts a new run of text with the given revision mark, returning the
+// marker to write before it
+func (t *revisionTracker) enter(mark *RevisionMark, offset int) string {
+	t.current = mark
+	if mark == nil {
+		return ""
+	}
+	t.open = &Revision{Type: mark.revisionType(), Offset: offset}
+	author := mark.InsertAuthor
+	t.open.Date = mark.InsertDate
+	if mark.Deleted {
+		author = mark.DeleteAuthor
+		t.open.Date = mark.DeleteDate
+	}
+	if author >= 0 && author < len(t.authors) {
+		t.open.Author = t.authors[author]
+	}
+	start, _ := t.mode.markers(t.open.Type)
+	return start
+}
+
+// leave ends the current run of revised text, returning the marker to write
+// after it
+func (t *revisionTracker) leave() string {
+	if t.current == nil {
+		return ""
+	}
+	_, end := t.mode.markers(t.open.Type)
+	if t.open.Text != "" {
+		t.found = append(t.found, *t.open)
+	}
+	t.current, t.open = nil, nil
+	return end
+}
+
+// collect adds text to the revision that is currently open
+func (t *revisionTracker) collect(text string) {
+	if t.open != nil {
+		t.open.Text += text
+	}
+}
+
+// shows tells whether text with the given revision mark is kept
+func (t *revisionTracker) shows(mark *RevisionMark) bool {
+	if mark == nil {
+		return true
+	}
+	return (!mark.Inserted || t.mode.shows(Insertion)) && (!mark.Deleted || t.mode.shows(Deletion))
+}
+
+// revisionMarkAt returns the revision mark covering a file position, or nil
+// when the text there is not a tracked change
+func (w *WordOleExtractor) revisionMarkAt(fc int) *RevisionMark {
+	i := sort.Search(len(w.revisionMarks), func(i int) bool {
+		return w.revisionMarks[i].StartFilePos > fc
+	})
+	if i > 0 && fc < w.revisionMarks[i-1].EndFilePos {
+		return &w.revisionMarks[i-1]
+	}
+	return nil
+}
+
+// paragraphPropertiesAt returns the paragraph properties in effect at a file
+// position, or the zero value when there are none
+func (w *WordOleExtractor) paragraphPropertiesAt(fc int) ParagraphProperties {
+	i := sort.Search(len(w.paragraphs), func(i int) bool {
+		return w.paragraphs[i].StartFilePos > fc
+	})
+	if i > 0 && fc < w.paragraphs[i-1].EndFilePos {
+		return w.paragraphs[i-1]
+	}
+	return ParagraphProperties{}
+}
+
+// Helper functions
+
+// readStream reads a stream at the root of the compound file. Embedded
+// objects keep streams of the same names in their own storages. The stream
+// is read within the limits.
+func readStream(limits *limiter, reader io.ReadSeeker, name string) ([]byte, error) {
+	cfb, err := openCompoundFile(reader)
+	if err != nil {
+		return nil, partError("", -1, err)
+	}
+
+	for entry, err := cfb.Next(); err == nil; entry, err = cfb.Next() {
+		if entry.Name == name && len(entry.Path) == 0 {
+			if err := limits.checkStream(name, entry.Size); err != nil {
+				return nil, err
+			}
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(cfb)
+			if err != nil {
+				return nil, partError(name, -1, err)
+			}
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, &ExtractError{Part: name, Offset: -1, Err: errMissingStream}
+}
+
+// Helper functions for text manipulation and processing would go here
+
+func (w *WordOleExtractor) writeBookmarks(buffer, tableBuffer []byte) error {
+	fib, table := w.structReaders(buffer, tableBuffer)
+	sttbfBkmk := w.fibTable(fib, table, 0x0142)
+	plcfBkf := w.fibTable(fib, table, 0x014A)
+	plcfBkl := w.fibTable(fib, table, 0x0152)
+
+	if sttbfBkmk.size() == 0 {
+		return fib.err()
+	}
+
+	fcExtend := sttbfBkmk.uint16(0)
+	if err := fib.err(); err != nil {
+		return err
+	}
+	if fcExtend != 0xFFFF {
+		return corruptError(w.tableStream, sttbfBkmk.base, "unexpected single-byte bookmark data")
+	}
+
+	offset := 6 // Skip header bytes
+	index := 0
+
+	for offset < sttbfBkmk.size() {
+		length := int(sttbfBkmk.uint16(offset)) * 2
+		segment := sttbfBkmk.bytes(offset+2, length)
+		cpStart := plcfBkf.uint32(index * 4)
+		cpEnd := plcfBkl.uint32(index * 4)
+		if err := fib.err(); err != nil {
+			return err
+		}
+		w.bookmarks[string(segment)] = Bookmark{Start: int(cpStart), End: int(cpEnd)}
+		offset += length + 2
+	}
+
+	return nil
+}
+
+// fibTableNames names the structures of the table stream that are given by
+// the fc and lcb pairs of the FIB, by the offset of the pair
+var fibTableNames = map[int]string{
+	0x00A2: "STSH",
+	0x00AA: "PlcffndRef",
+	0x00B2: "PlcffndTxt",
+	0x00BA: "PlcfandRef",
+	0x00C2: "PlcfandTxt",
+	0x00F2: "PlcfHdd",
+	0x00FA: "PlcBteChpx",
+	0x0102: "PlcBtePapx",
+	0x0142: "SttbfBkmk",
+	0x014A: "PlcfBkf",
+	0x0152: "PlcfBkl",
+	0x01A2: "Clx",
+	0x01BA: "GrpXstAtnOwners",
+	0x01C2: "SttbfAtnBkmk",
+	0x01DA: "PlcSpaMom",
+	0x01EA: "PlcfAtnBkf",
+	0x01F2: "PlcfAtnBkl",
+	0x020A: "PlcfendRef",
+	0x0212: "PlcfendTxt",
+	0x022A: "OfficeArtContent",
+	0x0232: "SttbfRMark",
+	0x02E2: "PlfLst",
+	0x02EA: "PlfLfo",
+	0x041A: "AtrdExtra",
+}
+
+// structReaders returns readers of the FIB, at the start of the WordDocument
+// stream, and of the table stream. They share their error.
+func (w *WordOleExtractor) structReaders(buffer, tableBuffer []byte) (fib, table *structReader) {
+	fib = newStructReader("WordDocument", "FIB", buffer)
+	table = &structReader{stream: w.tableStream, name: w.tableStream, data: tableBuffer, errp: fib.errp}
+	return fib, table
+}
+
+// fibTable returns a reader of the structure of the table stream given by
+// the fc and lcb pair at an offset of the FIB. It is empty when the FIB is
+// too short to hold the pair, or the structure is empty.
+func (w *WordOleExtractor) fibTable(fib, table *structReader, offset int) *structReader {
+	names := fibTableNames
+	if w.tableStream == "WordDocument" {
+		names = word6TableNames
+	}
+	name, ok := names[offset]
+	if !ok {
+		name = fmt.Sprintf("table at FIB offset 0x%04X", offset)
+	}
+	if offset+8 > fib.size() || fib.uint32(offset+4) == 0 {
+		return table.sub(name, 0, 0)
+	}
+	return table.sub(name, int(fib.uint32(offset)), int(fib.uint32(offset+4)))
+}
+
+// readFibTable returns the part of the table stream described by the fc and
+// lcb pair at the given offset of the FIB, or nil when it is empty
+func (w *WordOleExtractor) readFibTable(buffer, tableBuffer []byte, offset int) ([]byte, error) {
+	fib, table := w.structReaders(buffer, tableBuffer)
+	data := w.fibTable(fib, table, offset).data
+	if len(data) == 0 {
+		return nil, fib.err()
+	}
+	return data, fib.err()
+}
+
+// writeComments reads the comments from the annotation reference table,
+// along with their authors, dates and the bookmarks that give their ranges
+func (w *WordOleExtractor) writeComments(buffer, tableBuffer []byte) error {
+	plcfandRef, err := w.readFibTable(buffer, tableBuffer, 0x00BA)
+	if err != nil || len(plcfandRef) < 4 {
+		return err
+	}
+	plcfandTxt, err := w.readFibTable(buffer, tableBuffer, 0x00C2)
+	if err != nil {
+		return err
+	}
+	grpXstAtnOwners, err := w.readFibTable(buffer, tableBuffer, 0x01BA)
+	if err != nil {
+		return err
+	}
+	sttbfAtnBkmk, err := w.readFibTable(buffer, tableBuffer, 0x01C2)
+	if err != nil {
+		return err
+	}
+	plcfAtnBkf, err := w.readFibTable(buffer, tableBuffer, 0x01EA)
+	if err != nil {
+		return err
+	}
+	plcfAtnBkl, err := w.readFibTable(buffer, tableBuffer, 0x01F2)
+	if err != nil {
+		return err
+	}
+
+	// Dates and threads are kept in ATRDPost10 records, in the part of the
+	// FIB added by Word 2002
+	var atrdExtra []byte
+	if len(buffer) >= 0x009A && binary.LittleEndian.Uint16(buffer[0x0098:]) > 112 {
+		if atrdExtra, err = w.readFibTable(buffer, tableBuffer, 0x041A); err != nil {
+			return err
+		}
+	}
+
+	// The owners are a list of Xst strings, referred to by index
+	var owners []string
+	for offset := 0; offset+2 <= len(grpXstAtnOwners); {
+		length := int(binary.LittleEndian.Uint16(grpXstAtnOwners[offset:])) * 2
+		offset += 2
+		if offset+length > len(grpXstAtnOwners) {
+			break
+		}
+		owner, _ := bufferToUCS2String(grpXstAtnOwners[offset : offset+length])
+		owners = append(owners, owner)
+		offset += length
+	}
+
+	// Comment ranges are bookmarks, found through the tag in their ATNBE
+	type bookmarkRange struct{ start, end int }
+	ranges := make(map[int32]bookmarkRange)
+	_, tags := readSttbEntries(sttbfAtnBkmk)
+	bkfCount := (len(plcfAtnBkf) - 4) / 8
+	for k, extra := range tags {
+		if len(extra) < 6 || k >= bkfCount {
+			continue
+		}
+		start := int(binary.LittleEndian.Uint32(plcfAtnBkf[k*4:]))
+		ibkl := int(binary.LittleEndian.Uint16(plcfAtnBkf[(bkfCount+1)*4+k*4:]))
+		if ibkl*4+4 > len(plcfAtnBkl) {
+			continue
+		}
+		end := int(binary.LittleEndian.Uint32(plcfAtnBkl[ibkl*4:]))
+		ranges[int32(binary.LittleEndian.Uint32(extra[2:]))] = bookmarkRange{start, end}
+	}
+
+	// Each reference has a 30 byte ATRDPre10
+	count := (len(plcfandRef) - 4) / 34
+	dataOffset := (count + 1) * 4
+	for i := 0; i < count; i++ {
+		atrd := plcfandRef[dataOffset+i*30 : dataOffset+(i+1)*30]
+		a := annotation{
+			refCp:  int(binary.LittleEndian.Uint32(plcfandRef[i*4:])),
+			parent: -1,
+		}
+		a.startCp, a.endCp = a.refCp, a.refCp
+
+		// The initials are an Xst of up to nine characters
+		if length := int(binary.LittleEndian.Uint16(atrd)); length <= 9 {
+			a.initials, _ = bufferToUCS2String(atrd[2 : 2+length*2])
+		}
+		if ibst := int(binary.LittleEndian.Uint16(atrd[20:])); ibst < len(owners) {
+			a.author = owners[ibst]
+		}
+		if r, ok := ranges[int32(binary.LittleEndian.Uint32(atrd[26:]))]; ok {
+			a.startCp, a.endCp = r.start, r.end
+		}
+
+		if (i+2)*4 <= len(plcfandTxt) {
+			a.textStart = int(binary.LittleEndian.Uint32(plcfandTxt[i*4:]))
+			a.textEnd = int(binary.LittleEndian.Uint32(plcfandTxt[(i+1)*4:]))
+		}
+
+		if (i+1)*18 <= len(atrdExtra) {
+			post := atrdExtra[i*18:]
+			a.date = parseDTTM(binary.LittleEndian.Uint32(post))
+			depth := binary.LittleEndian.Uint32(post[6:])
+			parent := int(int32(binary.LittleEndian.Uint32(post[10:])))
+			if depth > 0 && parent != 0 {
+				a.parent = i + parent
+			}
+		}
+		w.annotations = append(w.annotations, a)
+	}
+	return nil
+}
+
+// buildComments makes the comments read from the annotation tables, anchored
+// to the rendered body. Their text is read from the annotation story, which
+// starts at the given character position.
+func (w *WordOleExtractor) buildComments(body *oleStory, atnStart int) []*Comment {
+	if len(w.annotations) == 0 {
+		return nil
+	}
+	text := []rune(body.String())
+	comments := make([]*Comment, len(w.annotations))
+	parents := make([]int, len(w.annotations))
+	for i, a := range w.annotations {
+		c := &Comment{
+			ID:       strconv.Itoa(i),
+			Author:   a.author,
+			Initials: a.initials,
+			Date:     a.date,
+		}
+		if a.textEnd > a.textStart {
+			content := cleanText(w.getRevisedTextByCP(atnStart+a.textStart, atnStart+a.textEnd))
+			c.Text = strings.TrimSuffix(content, "\n")
+		}
+		c.anchor(text, body.offsetAt(a.startCp), body.offsetAt(a.endCp))
+		comments[i] = c
+		parents[i] = a.parent
+	}
+	return threadComments(comments, parents)
+}
+
+// noteTables gives the offsets in the FIB of the footnote and endnote
+// reference tables and of the note text tables
+type noteTables struct {
+	fndRef, fndTxt int
+	endRef, endTxt int
+}
+
+var word97NoteTables = noteTables{fndRef: 0x00AA, fndTxt: 0x00B2, endRef: 0x020A, endTxt: 0x0212}
+
+// writeNotes reads the footnote and endnote references, and the ranges of
+// the note stories that hold their text
+func (w *WordOleExtractor) writeNotes(buffer, tableBuffer []byte, tables noteTables) error {
+	var err error
+	if w.footnoteRefs, err = w.readNoteReferences(buffer, tableBuffer, tables.fndRef, tables.fndTxt); err != nil {
+		return err
+	}
+	if w.endnoteRefs, err = w.readNoteReferences(buffer, tableBuffer, tables.endRef, tables.endTxt); err != nil {
+		return err
+	}
+
+	if w.Options.NoteMarkers {
+		w.noteMarkers = make(map[int]string)
+		for i, ref := range w.footnoteRefs {
+			w.noteMarkers[ref.refCp] = noteMarker(false, i+1)
+		}
+		for i, ref := range w.endnoteRefs {
+			w.noteMarkers[ref.refCp] = noteMarker(true, i+1)
+		}
+	}
+	return nil
 }
 
-// Helper functions
-
-func readStream(reader io.ReadSeeker, name string) ([]byte, error) {
-	var readerAt io.ReaderAt
-	if _, ok := reader.(*os.File); !ok {
-		readerAt = NewUnbufferedReaderAt(reader)
-	} else {
-		readerAt = reader.(io.ReaderAt)
+// readNoteReferences reads a note reference table, such as PlcffndRef, and
+// the matching note text table, such as PlcffndTxt
+func (w *WordOleExtractor) readNoteReferences(buffer, tableBuffer []byte, refOffset, txtOffset int) ([]noteReference, error) {
+	plcRef, err := w.readFibTable(buffer, tableBuffer, refOffset)
+	if err != nil || len(plcRef) < 4 {
+		return nil, err
 	}
-
-	readerAt.(io.ReadSeeker).Seek(0, io.SeekStart)
-	cfb, err := mscfb.New(readerAt)
+	plcTxt, err := w.readFibTable(buffer, tableBuffer, txtOffset)
 	if err != nil {
 		return nil, err
 	}
 
-	for entry, err := cfb.Next(); err == nil; entry, err = cfb.Next() {
-		if entry.Name == name {
-			buf := new(bytes.Buffer)
-			_, err := buf.ReadFrom(cfb)
-			if err != nil {
-				return nil, err
-			}
-			return buf.Bytes(), nil
+	// Each reference has a two byte FRD
+	count := (len(plcRef) - 4) / 6
+	refs := make([]noteReference, count)
+	for i := range refs {
+		refs[i].refCp = int(binary.LittleEndian.Uint32(plcRef[i*4:]))
+		if (i+2)*4 <= len(plcTxt) {
+			refs[i].textStart = int(binary.LittleEndian.Uint32(plcTxt[i*4:]))
+			refs[i].textEnd = int(binary.LittleEndian.Uint32(plcTxt[(i+1)*4:]))
 		}
 	}
-	return nil, errors.New("stream not found: " + name)
+	return refs, nil
 }
 
-// Helper functions for text manipulation and processing would go here
-
-func (w *WordOleExtractor) writeBookmarks(buffer, tableBuffer []byte) error {
-	fcSttbfBkmk := binary.LittleEndian.Uint32(buffer[0x0142:0x0146])
-	lcbSttbfBkmk := binary.LittleEndian.Uint32(buffer[0x0146:0x014A])
-	fcPlcfBkf := binary.LittleEndian.Uint32(buffer[0x014A:0x014E])
-	lcbPlcfBkf := binary.LittleEndian.Uint32(buffer[0x014E:0x0152])
-	fcPlcfBkl := binary.LittleEndian.Uint32(buffer[0x0152:0x0156])
-	lcbPlcfBkl := binary.LittleEndian.Uint32(buffer[0x0156:0x015A])
-
-	if lcbSttbfBkmk == 0 {
-		return nil
+// buildNotes makes the notes for a list of references, placed in the
+// rendered body. Their text is read from the note story, which starts at the
+// given character position.
+func (w *WordOleExtractor) buildNotes(body *oleStory, refs []noteReference, storyStart int) []*Note {
+	var notes []*Note
+	for i, ref := range refs {
+		note := &Note{ID: strconv.Itoa(i + 1), Offset: body.offsetAt(ref.refCp)}
+		if ref.textEnd > ref.textStart {
+			text := cleanText(w.getRevisedTextByCP(storyStart+ref.textStart, storyStart+ref.textEnd))
+			note.Text = strings.TrimSpace(text)
+		}
+		notes = append(notes, note)
 	}
+	return notes
+}
 
-	sttbfBkmk := tableBuffer[fcSttbfBkmk : fcSttbfBkmk+lcbSttbfBkmk]
-	plcfBkf := tableBuffer[fcPlcfBkf : fcPlcfBkf+lcbPlcfBkf]
-	plcfBkl := tableBuffer[fcPlcfBkl : fcPlcfBkl+lcbPlcfBkl]
-
-	fcExtend := binary.LittleEndian.Uint16(sttbfBkmk[0:2])
-	if fcExtend != 0xFFFF {
-		return errors.New("unexpected single-byte bookmark data")
+// checkPieces checks the pieces of a piece table before their text is
+// decoded. Pieces may not overlap, nor hold more characters than the FIB
+// counts, so that a piece table cannot have the same text decoded over and
+// over. The text read is counted against the limits.
+func (w *WordOleExtractor) checkPieces(pieces []Piece, part string) error {
+	characters, size := 0, 0
+	for _, piece := range pieces {
+		characters += piece.TotLength
+		size += piece.Size
 	}
-
-	offset := 6 // Skip header bytes
-	index := 0
-
-	for offset < int(lcbSttbfBkmk) {
-		length := int(binary.LittleEndian.Uint16(sttbfBkmk[offset:offset+2])) * 2
-		segment := sttbfBkmk[offset+2 : offset+2+length]
-		cpStart := binary.LittleEndian.Uint32(plcfBkf[index*4 : index*4+4])
-		cpEnd := binary.LittleEndian.Uint32(plcfBkl[index*4 : index*4+4])
-		w.bookmarks[string(segment)] = Bookmark{Start: int(cpStart), End: int(cpEnd)}
-		offset += length + 2
+	if characters > w.boundaries.characters()+1 {
+		return corruptError(part, -1, "invalid piece table: %d characters for %d", characters, w.boundaries.characters())
 	}
 
-	return nil
+	sorted := append([]Piece(nil), pieces...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartFilePos < sorted[j].StartFilePos })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].StartFilePos < sorted[i-1].StartFilePos+sorted[i-1].Size {
+			return corruptError("WordDocument", sorted[i].StartFilePos, "overlapping pieces")
+		}
+	}
+	return w.limits.checkStream("WordDocument", int64(size))
 }
 
 func (w *WordOleExtractor) writePieces(buffer, tableBuffer []byte) error {
-	pos := binary.LittleEndian.Uint32(buffer[0x01A2:0x01A6])
+	fib, table := w.structReaders(buffer, tableBuffer)
+	clx := w.fibTable(fib, table, 0x01A2)
+	pos := 0
 
 	// Skip initial flags
-	for tableBuffer[pos] == 1 {
-		skip := binary.LittleEndian.Uint16(tableBuffer[pos+1:])
-		pos += 3 + uint32(skip)
+	for clx.uint8(pos) == 1 {
+		skip := clx.uint16(pos + 1)
+		pos += 3 + int(skip)
 	}
 
-	if tableBuffer[pos] != 2 {
-		return errors.New("corrupted Word file")
+	if err := fib.err(); err != nil {
+		return err
+	}
+	if clx.uint8(pos) != 2 {
+		return corruptError(w.tableStream, clx.base+pos, "invalid piece table")
 	}
 	pos++
 
-	pieceTableSize := binary.LittleEndian.Uint32(tableBuffer[pos:])
+	pieceTableSize := int(clx.uint32(pos))
 	pos += 4
+	plcPcd := clx.sub("PlcPcd", pos, pieceTableSize)
 
-	pieces := (pieceTableSize - 4) / 12
-	var startCp, startStream int
-
-	for x := uint32(0); x < pieces; x++ {
-		offset := pos + ((pieces + 1) * 4) + (x * 8) + 2
-		startFilePos := binary.LittleEndian.Uint32(tableBuffer[offset:])
+	// The pieces are checked before their text is decoded
+	pieces := make([]Piece, (pieceTableSize-4)/12)
+	for x := range pieces {
+		offset := ((len(pieces) + 1) * 4) + (x * 8) + 2
+		startFilePos := plcPcd.uint32(offset)
 
 		unicode := true
 		if (startFilePos & 0x40000000) != 0 {
@@ -317,28 +1365,44 @@ func (w *WordOleExtractor) writePieces(buffer, tableBuffer []byte) error {
 			startFilePos = startFilePos / 2
 		}
 
-		lStart := binary.LittleEndian.Uint32(tableBuffer[pos+x*4:])
-		lEnd := binary.LittleEndian.Uint32(tableBuffer[pos+(x+1)*4:])
-		totLength := lEnd - lStart
+		lStart := plcPcd.uint32(x * 4)
+		lEnd := plcPcd.uint32((x + 1) * 4)
+		if err := fib.err(); err != nil {
+			return err
+		}
+		if lEnd < lStart {
+			return corruptError(w.tableStream, plcPcd.base+x*4, "invalid PlcPcd")
+		}
 
 		piece := Piece{
-			StartCp:      startCp,
-			StartStream:  startStream,
-			TotLength:    int(totLength),
+			TotLength:    int(lEnd - lStart),
 			StartFilePos: int(startFilePos),
 			Unicode:      unicode,
 			Bpc:          1,
 		}
-
 		if unicode {
 			piece.Bpc = 2
 		}
+		piece.Size = piece.Bpc * piece.TotLength
+		pieces[x] = piece
+	}
+	if err := w.checkPieces(pieces, w.tableStream); err != nil {
+		return err
+	}
 
-		piece.Size = piece.Bpc * int(lEnd-lStart)
+	var startCp, startStream int
+	for _, piece := range pieces {
+		if err := w.limits.check(); err != nil {
+			return err
+		}
+		piece.StartCp, piece.StartStream = startCp, startStream
 
 		// Extract text correctly based on unicode flag
-		textBuffer := buffer[startFilePos : startFilePos+uint32(piece.Size)]
-		if unicode {
+		textBuffer := fib.sub("piece text", piece.StartFilePos, piece.Size).data
+		if err := fib.err(); err != nil {
+			return err
+		}
+		if piece.Unicode {
 			text, err := bufferToUCS2String(textBuffer)
 			if err != nil {
 				fmt.Printf("Error converting text buffer: %v\n", err)
@@ -371,18 +1435,39 @@ func (w *WordOleExtractor) writePieces(buffer, tableBuffer []byte) error {
 }
 
 func (w *WordOleExtractor) normalizeHeaders(buffer, tableBuffer []byte) error {
-	fcPlcfhdd := binary.LittleEndian.Uint32(buffer[0x00F2:0x00F6])
-	lcbPlcfhdd := binary.LittleEndian.Uint32(buffer[0x00F6:0x00FA])
+	fib, table := w.structReaders(buffer, tableBuffer)
+	plcfHdd := w.fibTable(fib, table, 0x00F2)
 
-	if lcbPlcfhdd < 8 {
-		return nil
+	if plcfHdd.size() < 8 {
+		return fib.err()
+	}
+	w.splitHeaders(plcfHdd.data, headerStoryType)
+	return nil
+}
+
+// headerStoryType returns the type of a story of the header document. The
+// first six stories are the note separators, followed by six stories for
+// each section.
+func headerStoryType(story int) string {
+	switch {
+	case story < 3:
+		return "footnoteSeparators"
+	case story < 6:
+		return "endSeparators"
+	case story%6 == 0 || story%6 == 1 || story%6 == 4:
+		return "headers"
+	default:
+		return "footers"
 	}
+}
 
+// splitHeaders tags the stories of the header document, whose character
+// positions are given by the PlcfHdd table, with their types. Empty stories
+// are blanked out, as is the paragraph mark that ends each story.
+func (w *WordOleExtractor) splitHeaders(plcHdd []byte, storyType func(story int) string) {
 	offset := w.boundaries.CcpText + w.boundaries.CcpFtn
 	ccpHdd := w.boundaries.CcpHdd
-
-	plcHdd := tableBuffer[fcPlcfhdd : fcPlcfhdd+lcbPlcfhdd]
-	plcHddCount := lcbPlcfhdd / 4
+	plcHddCount := uint32(len(plcHdd) / 4)
 
 	start := offset + int(binary.LittleEndian.Uint32(plcHdd[0:4]))
 
@@ -392,22 +1477,10 @@ func (w *WordOleExtractor) normalizeHeaders(buffer, tableBuffer []byte) error {
 			end = offset + ccpHdd
 		}
 
-		text := w.getTextRangeByCP(start, end)
+		text := w.getRevisedTextByCP(start, end)
 		story := int(i - 1)
 
-		header := TaggedHeader{Text: text}
-
-		switch {
-		case story < 3:
-			header.Type = "footnoteSeparators"
-		case story < 6:
-			header.Type = "endSeparators"
-		case story%6 == 0 || story%6 == 1 || story%6 == 4:
-			header.Type = "headers"
-		case story%6 == 2 || story%6 == 3 || story%6 == 5:
-			header.Type = "footers"
-		}
-
+		header := TaggedHeader{Type: storyType(story), Text: text}
 		w.taggedHeaders = append(w.taggedHeaders, header)
 
 		if !containsNonWhitespace(text) {
@@ -418,12 +1491,12 @@ func (w *WordOleExtractor) normalizeHeaders(buffer, tableBuffer []byte) error {
 
 		start = end
 	}
-
-	return nil
 }
 
 // Helper functions
 
+// getPieceIndexByCP returns the index of the piece holding a character
+// position, or -1 when there are no pieces
 func getPieceIndexByCP(pieces []Piece, position int) int {
 	for i, piece := range pieces {
 		if position <= piece.EndCp {
@@ -434,7 +1507,7 @@ func getPieceIndexByCP(pieces []Piece, position int) int {
 }
 
 func (w *WordOleExtractor) getTextRangeByCP(start, end int) string {
-	startPiece := getPieceIndexByCP(w.pieces, start)
+	startPiece := max(getPieceIndexByCP(w.pieces, start), 0)
 	endPiece := getPieceIndexByCP(w.pieces, end)
 
 	// fmt.Printf("getTextRangeByCP: startPiece: %d, endPiece: %d\\n", startPiece, endPiece)
@@ -449,12 +1522,12 @@ func (w *WordOleExtractor) getTextRangeByCP(start, end int) string {
 		// Calculate start and end indices based on CP (UTF-16 units)
 		xstart := 0
 		if i == startPiece {
-			xstart = start - piece.StartCp
+			xstart = piece.unitIndex(start - piece.StartCp)
 		}
 		// Use piece.Length which is already calculated based on UTF-16 units
-		xend := piece.Length
+		xend := len(utf16Encoded)
 		if i == endPiece {
-			xend = end - piece.StartCp
+			xend = piece.unitIndex(end - piece.StartCp)
 		}
 
 		// Handle bounds checking against UTF-16 length
@@ -465,6 +1538,9 @@ func (w *WordOleExtractor) getTextRangeByCP(start, end int) string {
 			xstart = 0
 		}
 		// Ensure start is not greater than end
+		if xend < 0 {
+			xend = 0
+		}
 		if xstart > xend {
 			xstart = xend
 		}
@@ -478,6 +1554,34 @@ func (w *WordOleExtractor) getTextRangeByCP(start, end int) string {
 	return result.String() // Use result.String()
 }
 
+// getCharsByCP returns the characters between two character positions,
+// together with their file positions
+func (w *WordOleExtractor) getCharsByCP(start, end int) []oleChar {
+	var result []oleChar
+	for _, piece := range w.pieces {
+		if piece.EndCp <= start || piece.StartCp >= end {
+			continue
+		}
+		units := utf16.Encode([]rune(piece.Text))
+		for i := 0; i < len(units); i++ {
+			cp := piece.StartCp + piece.unitOffset(i)
+			if cp < start || cp >= end {
+				continue
+			}
+			r := rune(units[i])
+			fc := piece.StartFilePos + piece.unitOffset(i)*piece.Bpc
+			if utf16.IsSurrogate(r) && i+1 < len(units) {
+				if pair := utf16.DecodeRune(r, rune(units[i+1])); pair != unicode.ReplacementChar {
+					r = pair
+					i++
+				}
+			}
+			result = append(result, oleChar{r: r, cp: cp, fc: fc})
+		}
+	}
+	return result
+}
+
 func getPieceIndexByFilePos(pieces []Piece, position int) int {
 	for i, piece := range pieces {
 		if position <= piece.EndFilePos {
@@ -506,13 +1610,16 @@ func fillPieceRange(piece *Piece, start, end int, character string) {
 
 	// Convert to UTF-16 slice for safe unicode operations
 	utf16Runes := utf16.Encode([]rune(piece.Text))
-	startIdx := start - pieceStart // Index is UTF-16 based
-	endIdx := end - pieceStart     // Index is UTF-16 based
+	startIdx := piece.unitIndex(start - pieceStart) // Index is UTF-16 based
+	endIdx := piece.unitIndex(end - pieceStart)     // Index is UTF-16 based
 
 	// Ensure indices are within bounds
 	if startIdx < 0 {
 		startIdx = 0
 	}
+	if endIdx < 0 {
+		endIdx = 0
+	}
 	if endIdx > len(utf16Runes) {
 		endIdx = len(utf16Runes)
 	}
@@ -536,9 +1643,12 @@ func fillPieceRange(piece *Piece, start, end int, character string) {
 		result = append(result, utf16Runes[endIdx:]...)
 	}
 	piece.Text = string(utf16.Decode(result))
-	// Recalculate length after modification
-	piece.Length = len(result)
-	piece.EndCp = piece.StartCp + piece.Length
+	// Recalculate length after modification. The units of double byte text
+	// are replaced one for one, so its positions stay as they were.
+	if piece.offsets == nil {
+		piece.Length = len(result)
+		piece.EndCp = piece.StartCp + piece.Length
+	}
 }
 
 func fillPieceRangeByFilePos(piece *Piece, start, end int, character string) {
@@ -556,8 +1666,8 @@ func fillPieceRangeByFilePos(piece *Piece, start, end int, character string) {
 	}
 
 	// Convert byte offsets to character indices (which correspond to UTF-16 indices)
-	startIdx := (start - pieceStart) / piece.Bpc
-	endIdx := (end - pieceStart) / piece.Bpc
+	startIdx := piece.unitIndex((start - pieceStart) / piece.Bpc)
+	endIdx := piece.unitIndex((end - pieceStart) / piece.Bpc)
 
 	// Convert to UTF-16 slice
 	utf16Runes := utf16.Encode([]rune(piece.Text))
@@ -566,6 +1676,9 @@ func fillPieceRangeByFilePos(piece *Piece, start, end int, character string) {
 	if startIdx < 0 {
 		startIdx = 0
 	}
+	if endIdx < 0 {
+		endIdx = 0
+	}
 	if endIdx > len(utf16Runes) {
 		endIdx = len(utf16Runes)
 	}
@@ -589,13 +1702,16 @@ func fillPieceRangeByFilePos(piece *Piece, start, end int, character string) {
 		result = append(result, utf16Runes[endIdx:]...)
 	}
 	piece.Text = string(utf16.Decode(result))
-	// Recalculate length after modification
-	piece.Length = len(result)
-	piece.EndCp = piece.StartCp + piece.Length
+	// Recalculate length after modification. The units of double byte text
+	// are replaced one for one, so its positions stay as they were.
+	if piece.offsets == nil {
+		piece.Length = len(result)
+		piece.EndCp = piece.StartCp + piece.Length
+	}
 }
 
 func (w *WordOleExtractor) replaceSelectedRange(start, end int, character string) {
-	startPiece := getPieceIndexByCP(w.pieces, start)
+	startPiece := max(getPieceIndexByCP(w.pieces, start), 0)
 	endPiece := getPieceIndexByCP(w.pieces, end)
 	for i := startPiece; i <= endPiece; i++ {
 		fillPieceRange(&w.pieces[i], start, end, character)
@@ -603,19 +1719,17 @@ func (w *WordOleExtractor) replaceSelectedRange(start, end int, character string
 }
 
 func (w *WordOleExtractor) replaceSelectedRangeByFilePos(start, end int, character string) {
-	startPiece := getPieceIndexByFilePos(w.pieces, start)
+	startPiece := max(getPieceIndexByFilePos(w.pieces, start), 0)
 	endPiece := getPieceIndexByFilePos(w.pieces, end)
 	for i := startPiece; i <= endPiece; i++ {
 		fillPieceRangeByFilePos(&w.pieces[i], start, end, character)
 	}
 }
 
-func (w *WordOleExtractor) markDeletedRange(start, end int) {
-	w.replaceSelectedRangeByFilePos(start, end, "\x00")
-}
-
+// processSprms calls a handler for each sprm of a grpprl from an offset, with
+// the offset of its operand
 func processSprms(buffer []byte, offset int, handler func(buffer []byte, offset int, sprm uint16, ispmd uint16, fspec uint8, sgc uint8, spra uint8)) {
-	for offset < len(buffer)-1 {
+	for offset >= 0 && offset < len(buffer)-1 {
 		sprm := binary.LittleEndian.Uint16(buffer[offset:])
 		ispmd := sprm & 0x1ff
 		fspec := uint8((sprm >> 9) & 0x01)
@@ -638,6 +1752,17 @@ func processSprms(buffer []byte, offset int, handler func(buffer []byte, offset
 		case 4, 5:
 			offset += 2
 		case 6:
+			if sprm == sprmTDefTable {
+				// The table definition has a two byte operand size
+				if offset+2 > len(buffer) {
+					return
+				}
+				offset += int(binary.LittleEndian.Uint16(buffer[offset:])) + 1
+				continue
+			}
+			if offset >= len(buffer) {
+				return
+			}
 			offset += int(buffer[offset]) + 1
 		case 7:
 			offset += 3
@@ -648,115 +1773,733 @@ func processSprms(buffer []byte, offset int, handler func(buffer []byte, offset
 }
 
 func (w *WordOleExtractor) writeParagraphProperties(buffer, tableBuffer []byte) error {
-	fcPlcfbtePapx := binary.LittleEndian.Uint32(buffer[0x0102:0x0106])
-	lcbPlcfbtePapx := binary.LittleEndian.Uint32(buffer[0x0106:0x010A])
+	fib, table := w.structReaders(buffer, tableBuffer)
+	plcBtePapx := w.fibTable(fib, table, 0x0102)
+	if err := fib.err(); err != nil {
+		return err
+	}
 
-	plcBtePapxCount := (lcbPlcfbtePapx - 4) / 8
+	plcBtePapxCount := (plcBtePapx.size() - 4) / 8
 	dataOffset := (plcBtePapxCount + 1) * 4
-	plcBtePapx := tableBuffer[fcPlcfbtePapx : fcPlcfbtePapx+lcbPlcfbtePapx]
 
-	for i := uint32(0); i < plcBtePapxCount; i++ {
-		papxFkpBlock := binary.LittleEndian.Uint32(plcBtePapx[dataOffset+i*4:])
-		papxFkpBlockBuffer := buffer[papxFkpBlock*512 : (papxFkpBlock+1)*512]
+	for i := 0; i < plcBtePapxCount; i++ {
+		if err := w.limits.check(); err != nil {
+			return err
+		}
+		papxFkpBlock := int(plcBtePapx.uint32(dataOffset + i*4))
+		fkp := fib.sub("PAPX FKP", papxFkpBlock*512, 512)
 
-		crun := uint32(papxFkpBlockBuffer[511])
-		for j := uint32(0); j < crun; j++ {
-			rgfc := binary.LittleEndian.Uint32(papxFkpBlockBuffer[j*4:])
-			rgfcNext := binary.LittleEndian.Uint32(papxFkpBlockBuffer[(j+1)*4:])
+		crun := int(fkp.uint8(511))
+		if err := fib.err(); err != nil {
+			return err
+		}
+		for j := 0; j < crun; j++ {
+			rgfc := fkp.uint32(j * 4)
+			rgfcNext := fkp.uint32((j + 1) * 4)
 
-			cbLocation := (uint32(crun)+1)*4 + uint32(j)*13
-			cbIndex := uint32(papxFkpBlockBuffer[cbLocation]) * 2
+			cbLocation := (crun+1)*4 + j*13
+			cbIndex := int(fkp.uint8(cbLocation)) * 2
 
 			var grpPrlAndIstd []byte
-			if cb := uint32(papxFkpBlockBuffer[cbIndex]); cb != 0 {
-				// fmt.Printf("cb: %d\n", cb)
-				grpPrlAndIstd = papxFkpBlockBuffer[uint32(cbIndex)+1 : uint32(cbIndex)+1+uint32(2*cb)-1]
+			if cb := int(fkp.uint8(cbIndex)); cb != 0 {
+				grpPrlAndIstd = fkp.bytes(cbIndex+1, 2*cb-1)
 			} else {
-				// fmt.Printf("cb: %d\n", cb)
-				cb2 := papxFkpBlockBuffer[cbIndex+1]
-				grpPrlAndIstd = papxFkpBlockBuffer[uint32(cbIndex)+2 : uint32(cbIndex)+2+(2*uint32(cb2))]
+				cb2 := int(fkp.uint8(cbIndex + 1))
+				grpPrlAndIstd = fkp.bytes(cbIndex+2, 2*cb2)
+			}
+			if err := fib.err(); err != nil {
+				return err
+			}
+			props := ParagraphProperties{StartFilePos: int(rgfc), EndFilePos: int(rgfcNext)}
+			if len(grpPrlAndIstd) >= 2 {
+				props.istd = int(binary.LittleEndian.Uint16(grpPrlAndIstd))
 			}
-			// fmt.Printf("papxFkpBlockBuffer: %d\n", len(papxFkpBlockBuffer))
-			// fmt.Printf("grpPrlAndIstd: %d\n", len(grpPrlAndIstd))
 			processSprms(grpPrlAndIstd, 2, func(buffer []byte, offset int, sprm uint16, ispmd uint16, fspec uint8, sgc uint8, spra uint8) {
-				if sprm == uint16(0x2417) {
+				if offset >= len(buffer) {
+					return
+				}
+				switch sprm {
+				case sprmPFInTable:
+					props.InTable = buffer[offset] != 0
+				case sprmPFTtp:
+					props.Ttp = buffer[offset] != 0
 					w.replaceSelectedRangeByFilePos(int(rgfc), int(rgfcNext), "\n")
+				case sprmPFInnerTableCell:
+					props.InnerCell = buffer[offset] != 0
+				case sprmPFInnerTtp:
+					props.InnerTtp = buffer[offset] != 0
+				case sprmPItap:
+					if offset+4 <= len(buffer) {
+						props.Itap = int(int32(binary.LittleEndian.Uint32(buffer[offset:])))
+					}
+				case sprmTDefTable:
+					props.cells = readTableDefinition(buffer[offset:], 20)
+				case sprmPIstd:
+					if offset+2 <= len(buffer) {
+						props.istd = int(binary.LittleEndian.Uint16(buffer[offset:]))
+					}
+				case sprmPOutLvl:
+					props.outLvl, props.hasOutLvl = int(buffer[offset]), true
+				case sprmPIlfo:
+					if offset+2 <= len(buffer) {
+						props.ilfo, props.hasList = int(int16(binary.LittleEndian.Uint16(buffer[offset:]))), true
+					}
+				case sprmPIlvl:
+					props.ilvl = int(buffer[offset])
 				}
 			})
+			w.paragraphs = append(w.paragraphs, props)
 		}
 	}
+
+	sort.SliceStable(w.paragraphs, func(i, j int) bool {
+		return w.paragraphs[i].StartFilePos < w.paragraphs[j].StartFilePos
+	})
 	return nil
 }
 
-func (w *WordOleExtractor) writeCharacterProperties(buffer, tableBuffer []byte) error {
-	fcPlcfbteChpx := binary.LittleEndian.Uint32(buffer[0x00FA:0x00FE])
-	lcbPlcfbteChpx := binary.LittleEndian.Uint32(buffer[0x00FE:0x0102])
+// readTableDefinition reads the cell boundaries and merge flags of a table
+// row from the operand of sprmTDefTable, whose TC cell descriptors have the
+// given size
+func readTableDefinition(operand []byte, tcSize int) []cellFormat {
+	if len(operand) < 3 {
+		return nil
+	}
+	size := int(binary.LittleEndian.Uint16(operand)) + 1
+	if size > len(operand) {
+		size = len(operand)
+	}
+	if size < 3 {
+		return nil
+	}
+	operand = operand[:size]
 
-	// Skip if no character properties
-	if lcbPlcfbteChpx == 0 {
+	itcMac := int(operand[2])
+	centersEnd := 3 + (itcMac+1)*2
+	if centersEnd > len(operand) {
 		return nil
 	}
 
-	plcBteChpxCount := (lcbPlcfbteChpx - 4) / 8
-	dataOffset := (plcBteChpxCount + 1) * 4
+	cells := make([]cellFormat, itcMac)
+	for i := range cells {
+		cells[i].left = int(int16(binary.LittleEndian.Uint16(operand[3+i*2:])))
+		cells[i].right = int(int16(binary.LittleEndian.Uint16(operand[3+(i+1)*2:])))
+
+		// Each TC starts with its flags. Later ones may be left out, in
+		// which case they are all zero.
+		tc := centersEnd + i*tcSize
+		if tc+2 > len(operand) {
+			continue
+		}
+		rgf := binary.LittleEndian.Uint16(operand[tc:])
+		cells[i].merged = rgf&0x0002 != 0
+		if rgf&0x0020 != 0 {
+			if rgf&0x0040 != 0 {
+				cells[i].vMerge = MergeRestart
+			} else {
+				cells[i].vMerge = MergeContinue
+			}
+		}
+	}
+	return cells
+}
+
+// writeStyles reads the names, base styles and outline levels of the styles
+// in the STSH style sheet
+func (w *WordOleExtractor) writeStyles(buffer, tableBuffer []byte) error {
+	stsh, err := w.readFibTable(buffer, tableBuffer, 0x00A2)
+	if err != nil || len(stsh) < 6 {
+		return err
+	}
+	cbStshi := int(binary.LittleEndian.Uint16(stsh))
+	cstd := int(binary.LittleEndian.Uint16(stsh[2:]))
+	cbStdBase := int(binary.LittleEndian.Uint16(stsh[4:]))
+
+	offset := 2 + cbStshi
+	w.styles = make([]oleStyle, cstd)
+	for i := 0; i < cstd && offset+2 <= len(stsh); i++ {
+		cbStd := int(binary.LittleEndian.Uint16(stsh[offset:]))
+		offset += 2
+		if offset+cbStd > len(stsh) {
+			break
+		}
+		w.styles[i] = readStyle(stsh[offset:offset+cbStd], cbStdBase)
+		offset += cbStd
+	}
+	return nil
+}
+
+// readStyle reads a STD style definition. Only paragraph styles are kept, as
+// the others have no outline level.
+func readStyle(std []byte, cbStdBase int) oleStyle {
+	style := oleStyle{base: 0x0FFF}
+	if len(std) < 10 || cbStdBase < 10 || cbStdBase+2 > len(std) {
+		return style
+	}
+	style.sti = int(binary.LittleEndian.Uint16(std) & 0x0FFF)
+	stk := binary.LittleEndian.Uint16(std[2:]) & 0x000F
+	style.base = int(binary.LittleEndian.Uint16(std[2:]) >> 4)
+	if stk != 1 {
+		return style
+	}
+
+	// The name is a length-prefixed UTF-16 string with a null terminator,
+	// followed by the paragraph properties of the style
+	offset := cbStdBase
+	cch := int(binary.LittleEndian.Uint16(std[offset:]))
+	offset += 2
+	if offset+cch*2 > len(std) {
+		return style
+	}
+	style.name, _ = bufferToUCS2String(std[offset : offset+cch*2])
+	offset += cch*2 + 2
+	if offset%2 != 0 {
+		offset++
+	}
+	if offset+2 > len(std) {
+		return style
+	}
+	cbUpx := int(binary.LittleEndian.Uint16(std[offset:]))
+	offset += 2
+	if cbUpx < 2 || offset+cbUpx > len(std) {
+		return style
+	}
+	processSprms(std[offset:offset+cbUpx], 2, func(buffer []byte, offset int, sprm uint16, ispmd uint16, fspec uint8, sgc uint8, spra uint8) {
+		if offset >= len(buffer) {
+			return
+		}
+		switch sprm {
+		case sprmPOutLvl:
+			style.outLvl, style.hasOutLvl = int(buffer[offset]), true
+		case sprmPIlfo:
+			if offset+2 <= len(buffer) {
+				style.ilfo, style.hasList = int(int16(binary.LittleEndian.Uint16(buffer[offset:]))), true
+			}
+		case sprmPIlvl:
+			style.ilvl = int(buffer[offset])
+		}
+	})
+	return style
+}
+
+// setParagraphStyle fills in the style name, outline level and list numbering
+// of a paragraph. An outline level or list set on the paragraph wins over the
+// one of its style, which may come from a base style, and built-in heading
+// styles have the level of their number.
+func (w *WordOleExtractor) setParagraphStyle(p *Paragraph, props ParagraphProperties, numbering *listNumbering) {
+	styles := w.styleChain(props.istd)
+	if len(styles) > 0 {
+		p.Style = styles[0].name
+	}
+
+	p.Level = headingStyleLevel(p.Style)
+	if props.hasOutLvl {
+		p.Level = outlineLevel(props.outLvl)
+	} else {
+		for _, style := range styles {
+			if style.hasOutLvl {
+				p.Level = outlineLevel(style.outLvl)
+				break
+			}
+			if style.sti >= 1 && style.sti <= 9 {
+				p.Level = style.sti
+				break
+			}
+		}
+	}
+
+	ilfo, ilvl, hasList := props.ilfo, props.ilvl, props.hasList
+	for _, style := range styles {
+		if hasList {
+			break
+		}
+		ilfo, ilvl, hasList = style.ilfo, style.ilvl, style.hasList
+	}
+	if ilfo <= 0 || ilfo > len(w.listOverrides) {
+		return
+	}
+	override := w.listOverrides[ilfo-1]
+	def := w.lists[override.lsid]
+	if def == nil {
+		return
+	}
+	key := "lst:" + strconv.Itoa(override.lsid)
+	if numbering.first("lfo:" + strconv.Itoa(ilfo)) {
+		for level, start := range override.starts {
+			numbering.restart(key, level, start)
+		}
+	}
+	p.List = numbering.next(key, def, ilvl)
+}
+
+// styleChain returns a style followed by the styles it is based on
+func (w *WordOleExtractor) styleChain(istd int) []oleStyle {
+	var chain []oleStyle
+	for istd >= 0 && istd < len(w.styles) && len(chain) <= len(w.styles) {
+		chain = append(chain, w.styles[istd])
+		istd = w.styles[istd].base
+	}
+	return chain
+}
 
-	// Validate buffer sizes
-	if int(fcPlcfbteChpx+lcbPlcfbteChpx) > len(tableBuffer) {
-		return errors.New("invalid table buffer size")
+// writeLists reads the list definitions (LSTs) and the list overrides (LFOs)
+// that paragraphs refer to
+func (w *WordOleExtractor) writeLists(buffer, tableBuffer []byte) error {
+	fib, table := w.structReaders(buffer, tableBuffer)
+	plfLstTable := w.fibTable(fib, table, 0x02E2)
+	if err := fib.err(); err != nil || plfLstTable.size() < 2 {
+		return err
 	}
 
-	plcBteChpx := tableBuffer[fcPlcfbteChpx : fcPlcfbteChpx+lcbPlcfbteChpx]
-	var lastDeletionEnd int
+	// The LVLs of every list follow the PlfLst, which does not count them
+	plfLst := plfLstTable.data
+	cLst := int(int16(binary.LittleEndian.Uint16(plfLst)))
+	offset := plfLstTable.base + plfLstTable.size()
+	w.lists = make(map[int]*listDefinition)
+	for i := 0; i < cLst && 2+(i+1)*28 <= len(plfLst); i++ {
+		lstf := plfLst[2+i*28:]
+		lsid := int(int32(binary.LittleEndian.Uint32(lstf)))
+		count := 9
+		if lstf[26]&0x01 != 0 {
+			count = 1
+		}
+		def := newListDefinition()
+		for level := 0; level < count; level++ {
+			lvl, size := readListLevel(tableBuffer[offset:])
+			if size == 0 {
+				return nil
+			}
+			def.levels[level] = lvl
+			offset += size
+		}
+		w.lists[lsid] = def
+	}
+
+	plfLfo, err := w.readFibTable(buffer, tableBuffer, 0x02EA)
+	if err != nil || len(plfLfo) < 4 {
+		return err
+	}
+	lfoMac := int(binary.LittleEndian.Uint32(plfLfo))
+	offset = 4 + lfoMac*16
+	for i := 0; i < lfoMac && 4+(i+1)*16 <= len(plfLfo); i++ {
+		lfo := plfLfo[4+i*16:]
+		override := oleListOverride{lsid: int(int32(binary.LittleEndian.Uint32(lfo))), starts: make(map[int]int)}
+
+		// Each LFOData holds a CP followed by the level overrides, which
+		// may restart a level and may replace its formatting
+		offset += 4
+		for j := 0; j < int(lfo[12]) && offset+8 <= len(plfLfo); j++ {
+			start := int(int32(binary.LittleEndian.Uint32(plfLfo[offset:])))
+			flags := binary.LittleEndian.Uint32(plfLfo[offset+4:])
+			offset += 8
+			if flags&0x10 != 0 {
+				override.starts[int(flags&0x0F)] = start
+			}
+			if flags&0x20 != 0 {
+				_, size := readListLevel(plfLfo[offset:])
+				if size == 0 {
+					break
+				}
+				offset += size
+			}
+		}
+		w.listOverrides = append(w.listOverrides, override)
+	}
+	return nil
+}
+
+// listFormats maps the number formats (nfc) of .doc list levels to their
+// WordprocessingML names
+var listFormats = map[byte]string{
+	0:   formatDecimal,
+	1:   formatUpperRoman,
+	2:   formatLowerRoman,
+	3:   formatUpperLetter,
+	4:   formatLowerLetter,
+	5:   formatOrdinal,
+	22:  formatDecimalZero,
+	23:  formatBullet,
+	255: formatNone,
+}
 
-	for i := uint32(0); i < plcBteChpxCount; i++ {
-		binary.LittleEndian.Uint32(plcBteChpx[i*4:])
-		chpxFkpBlock := binary.LittleEndian.Uint32(plcBteChpx[dataOffset+i*4:])
+// readListLevel reads an LVL list level and returns its size, which is zero
+// when it does not fit in the data
+func readListLevel(data []byte) (listLevel, int) {
+	lvl := listLevel{start: 1, format: formatDecimal, restart: -1}
+	if len(data) < 28 {
+		return lvl, 0
+	}
+	lvl.start = int(int32(binary.LittleEndian.Uint32(data)))
+	if format, ok := listFormats[data[4]]; ok {
+		lvl.format = format
+	}
+	lvl.legal = data[5]&0x04 != 0
+	if data[5]&0x08 != 0 {
+		lvl.restart = int(data[26])
+	}
 
-		// Calculate block boundaries
-		blockStart := chpxFkpBlock * 512
-		blockEnd := (chpxFkpBlock + 1) * 512
-		if int(blockEnd) > len(buffer) {
-			return errors.New("invalid block boundaries")
+	// The level text follows the paragraph and character properties. The
+	// placeholders for level numbers are characters holding the level,
+	// found at the 1-based positions listed in rgbxchNums.
+	offset := 28 + int(data[25]) + int(data[24])
+	if offset+2 > len(data) {
+		return lvl, 0
+	}
+	cch := int(binary.LittleEndian.Uint16(data[offset:]))
+	offset += 2
+	if offset+cch*2 > len(data) {
+		return lvl, 0
+	}
+	placeholders := make(map[int]bool)
+	for _, position := range data[6:15] {
+		if position == 0 {
+			break
 		}
+		placeholders[int(position)-1] = true
+	}
+	var text strings.Builder
+	for i := 0; i < cch; i++ {
+		r := rune(binary.LittleEndian.Uint16(data[offset+i*2:]))
+		if placeholders[i] && r < 9 {
+			text.WriteString("%" + strconv.Itoa(int(r)+1))
+		} else {
+			text.WriteRune(r)
+		}
+	}
+	lvl.text = text.String()
+	return lvl, offset + cch*2
+}
+
+func (w *WordOleExtractor) writeCharacterProperties(buffer, tableBuffer []byte) error {
+	fib, table := w.structReaders(buffer, tableBuffer)
+	plcBteChpx := w.fibTable(fib, table, 0x00FA)
+
+	// Skip if no character properties
+	if plcBteChpx.size() == 0 {
+		return fib.err()
+	}
+
+	plcBteChpxCount := (plcBteChpx.size() - 4) / 8
+	dataOffset := (plcBteChpxCount + 1) * 4
 
-		chpxFkpBlockBuffer := buffer[blockStart:blockEnd]
+	for i := 0; i < plcBteChpxCount; i++ {
+		if err := w.limits.check(); err != nil {
+			return err
+		}
+		chpxFkpBlock := int(plcBteChpx.uint32(dataOffset + i*4))
+		fkp := fib.sub("CHPX FKP", chpxFkpBlock*512, 512)
 
-		crun := uint32(chpxFkpBlockBuffer[511])
+		crun := int(fkp.uint8(511))
+		if err := fib.err(); err != nil {
+			return err
+		}
 
-		for j := uint32(0); j < crun; j++ {
-			rgfc := binary.LittleEndian.Uint32(chpxFkpBlockBuffer[j*4:])
-			rgfcNext := binary.LittleEndian.Uint32(chpxFkpBlockBuffer[(j+1)*4:])
-			rgb := uint32(chpxFkpBlockBuffer[(uint32(crun)+1)*4+uint32(j)])
+		for j := 0; j < crun; j++ {
+			rgfc := fkp.uint32(j * 4)
+			rgfcNext := fkp.uint32((j + 1) * 4)
+			rgb := int(fkp.uint8((crun+1)*4 + j))
 			if rgb == 0 {
 				continue
 			}
 
 			chpxOffset := rgb * 2
 
-			cb := uint32(chpxFkpBlockBuffer[chpxOffset])
-			if int(chpxOffset+1+cb) > len(chpxFkpBlockBuffer) {
-				return errors.New("invalid grpprl boundaries")
+			cb := int(fkp.uint8(chpxOffset))
+			grpprl := fkp.bytes(chpxOffset+1, cb)
+			if err := fib.err(); err != nil {
+				return err
 			}
 
-			grpprl := chpxFkpBlockBuffer[chpxOffset+1 : chpxOffset+1+cb]
-
 			// fmt.Printf("grpprl: %d\n", len(grpprl))
 
+			mark := RevisionMark{StartFilePos: int(rgfc), EndFilePos: int(rgfcNext)}
+			picture := pictureRun{start: int(rgfc), end: int(rgfcNext), location: -1}
+			var special, data, ole bool
 			processSprms(grpprl, 0, func(buffer []byte, offset int, sprm uint16, ispmd uint16, fspec uint8, sgc uint8, spra uint8) {
-				if ispmd == uint16(sprmCFRMarkDel) {
-					if (buffer[offset] & 1) != 1 {
+				if offset >= len(buffer) {
+					return
+				}
+				switch sprm {
+				case sprmCFRMarkDel:
+					mark.Deleted = buffer[offset]&1 == 1
+				case sprmCFRMarkIns:
+					mark.Inserted = buffer[offset]&1 == 1
+				case sprmCIbstRMark, sprmCIbstRMarkDel:
+					if offset+2 > len(buffer) {
 						return
 					}
-
-					if lastDeletionEnd == int(rgfc) {
-						w.markDeletedRange(lastDeletionEnd, int(rgfcNext))
+					author := int(binary.LittleEndian.Uint16(buffer[offset:]))
+					if sprm == sprmCIbstRMark {
+						mark.InsertAuthor = author
+					} else {
+						mark.DeleteAuthor = author
+					}
+				case sprmCDttmRMark, sprmCDttmRMarkDel:
+					if offset+4 > len(buffer) {
+						return
+					}
+					date := parseDTTM(binary.LittleEndian.Uint32(buffer[offset:]))
+					if sprm == sprmCDttmRMark {
+						mark.InsertDate = date
 					} else {
-						w.markDeletedRange(int(rgfc), int(rgfcNext))
+						mark.DeleteDate = date
+					}
+				case sprmCFSpec:
+					special = buffer[offset]&1 == 1
+				case sprmCFData:
+					data = buffer[offset]&1 == 1
+				case sprmCFOle2:
+					ole = buffer[offset]&1 == 1
+				case sprmCPicLocation:
+					if offset+4 <= len(buffer) {
+						picture.location = int(binary.LittleEndian.Uint32(buffer[offset:]))
 					}
-					lastDeletionEnd = int(rgfcNext)
 				}
 			})
+			if mark.Inserted || mark.Deleted {
+				w.revisionMarks = append(w.revisionMarks, mark)
+			}
+			// Form field data and embedded objects use the same location
+			if special && !data && !ole && picture.location >= 0 {
+				w.pictureRuns = append(w.pictureRuns, picture)
+			}
+		}
+	}
+
+	sort.SliceStable(w.revisionMarks, func(i, j int) bool {
+		return w.revisionMarks[i].StartFilePos < w.revisionMarks[j].StartFilePos
+	})
+	sort.SliceStable(w.pictureRuns, func(i, j int) bool {
+		return w.pictureRuns[i].start < w.pictureRuns[j].start
+	})
+	return nil
+}
+
+// pictureAt returns the Data stream location of the inline picture at a file
+// position
+func (w *WordOleExtractor) pictureAt(fc int) (int, bool) {
+	i := sort.Search(len(w.pictureRuns), func(i int) bool {
+		return w.pictureRuns[i].start > fc
+	})
+	if i > 0 && fc < w.pictureRuns[i-1].end {
+		return w.pictureRuns[i-1].location, true
+	}
+	return 0, false
+}
+
+// writeDrawings reads the pictures of the drawing layer: the blip store shared
+// by all pictures, the shapes drawn over the main document, and the
+// characters that anchor them. Blips that are not kept in the store are kept
+// in the WordDocument stream.
+func (w *WordOleExtractor) writeDrawings(buffer, tableBuffer []byte) error {
+	plcfSpa, err := w.readFibTable(buffer, tableBuffer, 0x01DA)
+	if err != nil {
+		return err
+	}
+	content, err := w.readFibTable(buffer, tableBuffer, 0x022A)
+	if err != nil {
+		return err
+	}
+
+	w.shapeAnchors = make(map[int]uint32)
+	w.shapes = make(map[uint32][]artShape)
+	count := (len(plcfSpa) - 4) / 30
+	for i := 0; i < count; i++ {
+		cp := int(binary.LittleEndian.Uint32(plcfSpa[i*4:]))
+		w.shapeAnchors[cp] = binary.LittleEndian.Uint32(plcfSpa[(count+1)*4+i*26:])
+	}
+
+	// The drawing group comes first, followed by the drawing of the main
+	// document and the drawing of the headers, each behind a byte telling
+	// which it is
+	dgg, size := readArtRecord(content)
+	if size == 0 || dgg.typ != artDggContainer {
+		return nil
+	}
+	for _, rec := range readArtRecords(dgg.data) {
+		if rec.typ != artBStoreContainer {
+			continue
+		}
+		for _, entry := range readArtRecords(rec.data) {
+			blip, _ := blipStoreEntry(entry, buffer)
+			w.blips = append(w.blips, blip)
+		}
+	}
+	for offset := size; offset < len(content); {
+		dg, size := readArtRecord(content[offset+1:])
+		if size == 0 {
+			break
+		}
+		if content[offset] == 0 && dg.typ == artDgContainer {
+			readArtShapes(dg.data, false, w.shapes)
+		}
+		offset += 1 + size
+	}
+	return nil
+}
+
+// buildImages returns the pictures anchored in a story: the inline pictures
+// kept in the Data stream, and the pictures of the shapes drawn over it
+func (w *WordOleExtractor) buildImages(story *oleStory) []*Image {
+	var images []*Image
+	add := func(image *Image, anchor oleAnchor) {
+		image.Name = imageName(len(images)+1, image.ContentType)
+		image.Offset = anchor.offset
+		images = append(images, image)
+	}
+	for _, anchor := range story.anchors {
+		if anchor.r == 0x01 {
+			if location, ok := w.pictureAt(anchor.fc); ok {
+				if image, ok := readInlinePicture(w.dataStream, location); ok {
+					add(image, anchor)
+				}
+			}
+			continue
+		}
+		spid, ok := w.shapeAnchors[anchor.cp]
+		if !ok {
+			continue
+		}
+		for _, shape := range w.shapes[spid] {
+			if shape.pib < 1 || shape.pib > len(w.blips) || w.blips[shape.pib-1] == nil {
+				continue
+			}
+			image := *w.blips[shape.pib-1]
+			image.AltText = shape.altText
+			add(&image, anchor)
+		}
+	}
+	return images
+}
+
+// readInlinePicture reads the inline picture at a location of the Data
+// stream: a picture header, maybe followed by a file name, then the shape of
+// the picture and the blip store entries holding its picture
+func readInlinePicture(data []byte, location int) (*Image, bool) {
+	if location < 0 || location+0x44 > len(data) {
+		return nil, false
+	}
+	lcb := int(binary.LittleEndian.Uint32(data[location:]))
+	cbHeader := int(binary.LittleEndian.Uint16(data[location+4:]))
+	mm := binary.LittleEndian.Uint16(data[location+6:])
+	if lcb < cbHeader || lcb > len(data)-location || mm != 0x64 && mm != 0x66 {
+		return nil, false
+	}
+	picture := data[location+cbHeader : location+lcb]
+	if mm == 0x66 {
+		if len(picture) == 0 || 1+int(picture[0]) > len(picture) {
+			return nil, false
 		}
+		picture = picture[1+int(picture[0]):]
+	}
+
+	records := readArtRecords(picture)
+	if len(records) < 2 || records[0].typ != artSpContainer {
+		return nil, false
+	}
+	shape := readArtShape(records[0].data)
+	entries := records[1:]
+	entry := entries[0]
+	if shape.pib > 0 && shape.pib <= len(entries) {
+		entry = entries[shape.pib-1]
+	}
+	image, ok := blipStoreEntry(entry, nil)
+	if !ok {
+		return nil, false
 	}
+	image.AltText = shape.altText
+	return image, true
+}
+
+// writeRevisionAuthors reads the names of the authors of tracked changes
+// from the SttbfRMark string table
+func (w *WordOleExtractor) writeRevisionAuthors(buffer, tableBuffer []byte) error {
+	sttbfRMark, err := w.readFibTable(buffer, tableBuffer, 0x0232)
+	if err != nil || sttbfRMark == nil {
+		return err
+	}
+
+	w.authors = readSttb(sttbfRMark)
 	return nil
 }
+
+// readSttb reads the strings of an STTB string table, ignoring any extra
+// data stored with them
+func readSttb(data []byte) []string {
+	values, _ := readSttbEntries(data)
+	return values
+}
+
+// readSttbEntries reads the strings of an STTB string table along with the
+// extra data stored with each of them
+func readSttbEntries(data []byte) ([]string, [][]byte) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+	extended := binary.LittleEndian.Uint16(data) == 0xFFFF
+	offset := 0
+	if extended {
+		offset = 2
+	}
+	count := int(binary.LittleEndian.Uint16(data[offset:]))
+	offset += 2
+	if offset+2 > len(data) {
+		return nil, nil
+	}
+	cbExtra := int(binary.LittleEndian.Uint16(data[offset:]))
+	offset += 2
+
+	result := make([]string, 0, count)
+	extras := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		var value string
+		if extended {
+			if offset+2 > len(data) {
+				break
+			}
+			length := int(binary.LittleEndian.Uint16(data[offset:])) * 2
+			offset += 2
+			if offset+length > len(data) {
+				break
+			}
+			value, _ = bufferToUCS2String(data[offset : offset+length])
+			offset += length
+		} else {
+			if offset+1 > len(data) {
+				break
+			}
+			length := int(data[offset])
+			offset++
+			if offset+length > len(data) {
+				break
+			}
+			chars := make([]rune, length)
+			for j, b := range data[offset : offset+length] {
+				chars[j] = rune(b)
+			}
+			value = binaryToUnicode(string(chars))
+			offset += length
+		}
+		if offset+cbExtra > len(data) {
+			break
+		}
+		result = append(result, value)
+		extras = append(extras, data[offset:offset+cbExtra])
+		offset += cbExtra
+	}
+	return result, extras
+}
+
+// parseDTTM converts a packed DTTM date and time into a time.Time. Word
+// stores these in local time without a zone, so they are returned as UTC.
+func parseDTTM(dttm uint32) time.Time {
+	if dttm == 0 {
+		return time.Time{}
+	}
+	minute := int(dttm & 0x3F)
+	hour := int((dttm >> 6) & 0x1F)
+	day := int((dttm >> 11) & 0x1F)
+	month := int((dttm >> 16) & 0x0F)
+	year := 1900 + int((dttm>>20)&0x1FF)
+	return time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC)
+}
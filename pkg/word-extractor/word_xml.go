@@ -0,0 +1,441 @@
+package word_extractor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Word can save a document as a single XML file: either as a Flat OPC
+// package, which holds the parts of a .docx file, or in the WordprocessingML
+// 2003 format, which keeps the notes, comments, headers and pictures of the
+// document inline in its body.
+const (
+	flatOPCNamespace    = "http://schemas.microsoft.com/office/2006/xmlPackage"
+	WordML2003Namespace = "http://schemas.microsoft.com/office/word/2003/wordml"
+	amlNamespace        = "http://schemas.microsoft.com/aml/2001/core"
+)
+
+// wordXMLPart is the name given to the single part of a Word 2003 XML
+// document, which is read as the main document part
+const wordXMLPart = "word/document.xml"
+
+// wordXMLType is the action of the part of a Word 2003 XML document
+const wordXMLType = "wordml-2003"
+
+// packageFile is a part of a package
+type packageFile interface {
+	Open() (io.ReadCloser, error)
+}
+
+// xmlPart is a part held in memory, such as a part of a Flat OPC package
+type xmlPart []byte
+
+func (p xmlPart) Open() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(p)), nil
+}
+
+// xmlRootElement returns the name of the root element of an XML document,
+// or false when the data is not XML. The reader is left at the start.
+func xmlRootElement(reader io.ReadSeeker) (xml.Name, bool) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return xml.Name{}, false
+	}
+	defer reader.Seek(0, io.SeekStart)
+
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(reader, head)
+	head = bytes.TrimLeft(bytes.TrimPrefix(head[:n], []byte("\xEF\xBB\xBF")), " \t\r\n")
+	if !bytes.HasPrefix(head, []byte("<")) {
+		return xml.Name{}, false
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return xml.Name{}, false
+	}
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, false
+		}
+		if t, ok := token.(xml.StartElement); ok {
+			return t.Name, true
+		}
+	}
+}
+
+// isWordXML tells whether an XML root element starts a document Word saved
+// as a single file
+func isWordXML(root xml.Name) bool {
+	return (root.Space == flatOPCNamespace && root.Local == "package") ||
+		(root.Space == WordML2003Namespace && root.Local == "wordDocument")
+}
+
+// openXMLDocument reads the parts of a document saved as a single XML file.
+// The parts of a Flat OPC package are given content types from the package,
+// and a Word 2003 XML document is read as a single part.
+func (e *OpenOfficeExtractor) openXMLDocument(reader io.ReadSeeker, root xml.Name) (map[string]packageFile, []string, error) {
+	if !isWordXML(root) {
+		return nil, nil, fmt.Errorf("%w: unknown XML document", ErrUnsupportedFormat)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	e.document.Format = FormatXML
+	if root.Local == "wordDocument" {
+		e.actions[wordXMLPart] = Action{typ: wordXMLType}
+		e.mainPart = wordXMLPart
+		e.wordXML = true
+		return map[string]packageFile{wordXMLPart: xmlPart(data)}, []string{wordXMLPart}, nil
+	}
+
+	var pkg struct {
+		Parts []struct {
+			Name        string `xml:"name,attr"`
+			ContentType string `xml:"contentType,attr"`
+			XMLData     struct {
+				Inner []byte `xml:",innerxml"`
+			} `xml:"xmlData"`
+			BinaryData string `xml:"binaryData"`
+		} `xml:"part"`
+	}
+	decoder, _ := e.limits.newDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&pkg); err != nil {
+		return nil, nil, partError("", -1, err)
+	}
+
+	contentTypesFile := "[Content_Types].xml"
+	var types bytes.Buffer
+	types.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	files := map[string]packageFile{}
+	names := []string{contentTypesFile}
+	for _, part := range pkg.Parts {
+		name := strings.TrimPrefix(part.Name, "/")
+		if part.BinaryData != "" {
+			decoded, err := base64.StdEncoding.DecodeString(stripSpace(part.BinaryData))
+			if err != nil {
+				continue
+			}
+			files[name] = xmlPart(decoded)
+		} else {
+			files[name] = xmlPart(bytes.TrimSpace(part.XMLData.Inner))
+		}
+		names = append(names, name)
+		types.WriteString(`<Override PartName="`)
+		xml.EscapeText(&types, []byte("/"+name))
+		types.WriteString(`" ContentType="`)
+		xml.EscapeText(&types, []byte(part.ContentType))
+		types.WriteString(`"/>`)
+	}
+	types.WriteString(`</Types>`)
+	files[contentTypesFile] = xmlPart(types.Bytes())
+	e.actions[contentTypesFile] = Action{typ: "content-types"}
+	return files, names, nil
+}
+
+// stripSpace removes the white space that breaks base64 data into lines
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// readWordXMLBlock reads the styles, lists and document properties that
+// Word 2003 XML keeps in the document part, and tells whether the element
+// was one of them
+func (e *OpenOfficeExtractor) readWordXMLBlock(decoder *xml.Decoder, se xml.StartElement) (bool, error) {
+	switch {
+	case se.Name.Space == WordML2003Namespace && se.Name.Local == "styles":
+		return true, e.readStyles(decoder)
+	case se.Name.Space == WordML2003Namespace && se.Name.Local == "lists":
+		return true, e.readNumbering(decoder)
+	case se.Name.Space == officeNamespace && se.Name.Local == "DocumentProperties":
+		return true, e.readWordXMLProperties(decoder, false)
+	case se.Name.Space == officeNamespace && se.Name.Local == "CustomDocumentProperties":
+		return true, e.readWordXMLProperties(decoder, true)
+	}
+	return false, nil
+}
+
+// readWordXMLProperties reads the document properties or custom properties
+// of a Word 2003 XML document, up to the end of their element. Each
+// property is an element named after it.
+func (e *OpenOfficeExtractor) readWordXMLProperties(decoder *xml.Decoder, custom bool) error {
+	meta := &e.document.Metadata
+	var text strings.Builder
+	for depth := 1; depth > 0; {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			depth--
+			if depth == 0 {
+				break
+			}
+			value := strings.TrimSpace(text.String())
+			text.Reset()
+			if custom {
+				meta.setCustom(wordXMLName(t.Name.Local), value)
+			} else {
+				setWordXMLProperty(meta, t.Name.Local, value)
+			}
+		}
+	}
+	return nil
+}
+
+// setWordXMLProperty sets the metadata field of a Word 2003 XML property
+func setWordXMLProperty(meta *Metadata, name, value string) {
+	switch name {
+	case "Title":
+		meta.Title = value
+	case "Subject":
+		meta.Subject = value
+	case "Author":
+		meta.Author = value
+	case "Keywords":
+		meta.Keywords = value
+	case "Description":
+		meta.Comments = value
+	case "Category":
+		meta.Category = value
+	case "LastAuthor":
+		meta.LastModifiedBy = value
+	case "Revision":
+		meta.Revision = value
+	case "Created":
+		meta.Created, _ = time.Parse(time.RFC3339, value)
+	case "LastSaved":
+		meta.Modified, _ = time.Parse(time.RFC3339, value)
+	case "Pages":
+		meta.Pages = parseCount(value)
+	case "Words":
+		meta.Words = parseCount(value)
+	case "Characters":
+		meta.Characters = parseCount(value)
+	case "Company":
+		meta.Company = value
+	case "Template":
+		meta.Template = value
+	}
+}
+
+// wordXMLName decodes an element name in which characters that cannot be
+// part of a name are written by their code, such as "Client_x0020_Name"
+func wordXMLName(name string) string {
+	var decoded strings.Builder
+	for i := 0; i < len(name); i++ {
+		if strings.HasPrefix(name[i:], "_x") && i+7 <= len(name) && name[i+6] == '_' {
+			if code, err := strconv.ParseUint(name[i+2:i+6], 16, 16); err == nil {
+				decoded.WriteRune(rune(code))
+				i += 6
+				continue
+			}
+		}
+		decoded.WriteByte(name[i])
+	}
+	return decoded.String()
+}
+
+// inlineStory is a story that Word 2003 XML keeps inside the body, such as
+// a footnote or a header, with the state of the story and paragraph it
+// interrupts
+type inlineStory struct {
+	element    string
+	story      *storyBuilder
+	context    []string
+	inDocument bool
+	start      int
+	paragraph  docxParagraph
+}
+
+// pushStory writes to another story until the element closes, in the given
+// context. Text in it is not part of the body.
+func (e *OpenOfficeExtractor) pushStory(element string, story *storyBuilder, context ...string) {
+	e.inlineStories = append(e.inlineStories, inlineStory{
+		element:    element,
+		story:      e.story,
+		context:    e.context,
+		inDocument: e.inDocument,
+		start:      story.offset(),
+		paragraph:  docxParagraph{style: e.paraStyle, level: e.paraLevel, numID: e.paraNumID, ilvl: e.paraIlvl},
+	})
+	e.story = story
+	e.context = append(context, e.context...)
+	e.inDocument = false
+}
+
+// popStory returns to the story interrupted by an element, and returns the
+// text the element added to its own story. It returns false when the
+// element did not start a story.
+func (e *OpenOfficeExtractor) popStory(element string) (string, bool) {
+	n := len(e.inlineStories)
+	if n == 0 || e.inlineStories[n-1].element != element {
+		return "", false
+	}
+	inline := e.inlineStories[n-1]
+	e.inlineStories = e.inlineStories[:n-1]
+	text := string(e.story.text[inline.start:])
+	e.story, e.context, e.inDocument = inline.story, inline.context, inline.inDocument
+	p := inline.paragraph
+	e.paraStyle, e.paraLevel, e.paraNumID, e.paraIlvl = p.style, p.level, p.numID, p.ilvl
+	e.hideParagraph, e.joinParagraph = false, false
+	return text, true
+}
+
+// inlineStoryFor returns the story that collects the inline footnotes,
+// endnotes or comments of a Word 2003 XML document
+func (e *OpenOfficeExtractor) inlineStoryFor(kind string) *storyBuilder {
+	story := e.inlineParts[kind]
+	if story == nil {
+		story = newStoryBuilder(e.limits)
+		e.inlineParts[kind] = story
+	}
+	return story
+}
+
+// openInlineNote starts a footnote or endnote of a Word 2003 XML document,
+// which is referenced where it is in the body. The separators set in the
+// document properties are not part of the notes.
+func (e *OpenOfficeExtractor) openInlineNote(se xml.StartElement) {
+	kind := se.Name.Local
+	if typ := attrValue(se, "type"); typ != "" && typ != "content" {
+		e.pushStory(kind, newStoryBuilder(e.limits), typ)
+		e.note = nil
+		return
+	}
+	var note *docxNote
+	if e.inDocument && len(e.storyStack) == 0 {
+		e.noteCounts[kind]++
+		id := strconv.Itoa(e.noteCounts[kind])
+		e.noteRefs = append(e.noteRefs, docxNoteReference{key: kind + ":" + id, id: id, offset: e.story.offset()})
+		if e.Options.NoteMarkers {
+			e.writeText(noteMarker(kind == "endnote", e.noteCounts[kind]))
+		}
+		note = &docxNote{key: kind + ":" + id}
+	}
+	e.pushStory(kind, e.inlineStoryFor(kind), "content")
+	e.note = note
+}
+
+// closeInlineNote ends a footnote or endnote of a Word 2003 XML document
+func (e *OpenOfficeExtractor) closeInlineNote(kind string) {
+	note := e.note
+	text, ok := e.popStory(kind)
+	if !ok || note == nil {
+		return
+	}
+	e.noteTexts[note.key] = strings.TrimSpace(text)
+	e.note = nil
+	if kind == "endnote" {
+		e.document.Endnotes = e.inlineStoryFor(kind).String()
+	} else {
+		e.document.Footnotes = e.inlineStoryFor(kind).String()
+	}
+}
+
+// openAnnotation starts an annotation of a Word 2003 XML document: a
+// comment, the start or end of its range, or a tracked change
+func (e *OpenOfficeExtractor) openAnnotation(se xml.StartElement) {
+	typ := attrValue(se, "type")
+	e.annotationTypes = append(e.annotationTypes, typ)
+	id := attrValue(se, "id")
+	inBody := e.inDocument && len(e.storyStack) == 0
+	switch typ {
+	case "Word.Comment.Start":
+		if inBody {
+			e.commentStarts[id] = e.story.offset()
+		}
+	case "Word.Comment.End":
+		if inBody {
+			e.commentEnds[id] = e.story.offset()
+		}
+	case "Word.Comment":
+		if _, ok := e.commentEnds[id]; !ok && inBody {
+			e.commentEnds[id] = e.story.offset()
+		}
+		date, _ := time.Parse(time.RFC3339, attrValue(se, "createdate"))
+		comment := &docxComment{comment: &Comment{
+			ID:       id,
+			Author:   attrValue(se, "author"),
+			Initials: attrValue(se, "initials"),
+			Date:     date,
+		}}
+		e.pushStory(se.Name.Local, e.inlineStoryFor("comment"), "content")
+		if inBody {
+			e.comment = comment
+		}
+	case "Word.Insertion":
+		e.openRevision(se, Insertion)
+	case "Word.Deletion":
+		e.openRevision(se, Deletion)
+	}
+}
+
+// closeAnnotation ends the innermost annotation of a Word 2003 XML document
+func (e *OpenOfficeExtractor) closeAnnotation(ee xml.EndElement) {
+	n := len(e.annotationTypes)
+	if n == 0 {
+		return
+	}
+	typ := e.annotationTypes[n-1]
+	e.annotationTypes = e.annotationTypes[:n-1]
+	switch typ {
+	case "Word.Comment":
+		comment := e.comment
+		text, ok := e.popStory(ee.Name.Local)
+		if !ok {
+			return
+		}
+		if comment != nil {
+			comment.comment.Text = strings.TrimSuffix(text, "\n")
+			e.comments = append(e.comments, comment)
+		}
+		e.comment = nil
+		e.document.Annotations = e.inlineStoryFor("comment").String()
+	case "Word.Insertion", "Word.Deletion":
+		e.closeRevision()
+	}
+}
+
+// closeBinData keeps the data of a picture of a Word 2003 XML document,
+// which is written in base64 before the shape that shows it
+func (e *OpenOfficeExtractor) closeBinData() {
+	if e.binName == "" {
+		return
+	}
+	if data, err := base64.StdEncoding.DecodeString(stripSpace(e.binText.String())); err == nil {
+		e.binData[e.binName] = data
+	}
+	e.binName = ""
+	e.binText.Reset()
+}
+
+// binDataImage returns the picture of a Word 2003 XML document kept under a
+// name, such as "wordml://02000001.png"
+func (e *OpenOfficeExtractor) binDataImage(name string) ([]byte, string, bool) {
+	data, ok := e.binData[name]
+	if !ok {
+		return nil, "", false
+	}
+	name = path.Base(strings.TrimPrefix(name, "wordml://"))
+	return data, name, true
+}
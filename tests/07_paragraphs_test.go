@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParagraphs(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test12.doc", "test12.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			texts := []string{}
+			for _, p := range doc.Paragraphs() {
+				texts = append(texts, p.Text)
+			}
+			assert.Equal(t, []string{
+				"This is a simple paragraph", "",
+				"Row 1, cell 1", "Row 1, cell 2", "Row 1, cell 3",
+				"Row 2, cell 1", "", "Row 2, cell 3",
+				"", "And a second paragraph", "",
+			}, texts)
+
+			require.Len(t, doc.Blocks, 6)
+			require.NotNil(t, doc.Blocks[2].Table)
+			assert.Nil(t, doc.Blocks[2].Paragraph)
+		})
+	}
+
+	t.Run("should locate paragraphs within the body", func(t *testing.T) {
+		for _, file := range []string{"test03.doc", "test03.docx", "test07.doc", "test07.docx"} {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			body := []rune(doc.Body)
+			for _, p := range doc.Paragraphs() {
+				text := []rune(p.Text)
+				require.LessOrEqual(t, p.Offset+len(text), len(body), file)
+				assert.Equal(t, p.Text, string(body[p.Offset:p.Offset+len(text)]), file)
+			}
+		}
+	})
+
+	t.Run("should flatten paragraphs held in table cells", func(t *testing.T) {
+		document := word_extractor.NewDocument()
+		document.Blocks = []word_extractor.Block{
+			{Paragraph: &word_extractor.Paragraph{Text: "Before"}},
+			{Table: &word_extractor.Table{Rows: [][]word_extractor.Cell{{
+				{Blocks: []word_extractor.Block{{Paragraph: &word_extractor.Paragraph{Text: "Cell"}}}},
+			}}}},
+			{Paragraph: &word_extractor.Paragraph{Text: "After"}},
+		}
+		paragraphs := document.Paragraphs()
+		require.Len(t, paragraphs, 3)
+		assert.Equal(t, "Cell", paragraphs[1].Text)
+	})
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+
+// buildDocx assembles a minimal .docx in memory from a set of parts. A
+// content types part is supplied when one is not given.
+func buildDocx(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	if _, ok := parts["[Content_Types].xml"]; !ok {
+		parts["[Content_Types].xml"] = testContentTypes
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// wordBody wraps body content in a WordprocessingML document element
+func wordBody(content string) string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+ xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` + content + `</w:body></w:document>`
+}
+
+func cellTexts(row []word_extractor.Cell) []string {
+	texts := []string{}
+	for _, cell := range row {
+		texts = append(texts, cell.Text)
+	}
+	return texts
+}
+
+func TestTables(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test12.doc", "test12.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			tables := doc.Tables()
+			require.Len(t, tables, 1)
+			require.Len(t, tables[0].Rows, 2)
+			assert.Equal(t, []string{"Row 1, cell 1", "Row 1, cell 2", "Row 1, cell 3"}, cellTexts(tables[0].Rows[0]))
+			assert.Equal(t, []string{"Row 2, cell 1", "", "Row 2, cell 3"}, cellTexts(tables[0].Rows[1]))
+		})
+	}
+
+	t.Run("should match merged cells between .doc and .docx", func(t *testing.T) {
+		docDoc, err := extractor.Extract(filepath.Join("data", "test07.doc"))
+		require.NoError(t, err)
+		docxDoc, err := extractor.Extract(filepath.Join("data", "test07.docx"))
+		require.NoError(t, err)
+
+		docTables, docxTables := docDoc.Tables(), docxDoc.Tables()
+		require.Equal(t, len(docxTables), len(docTables))
+		for i := range docxTables {
+			require.Equal(t, len(docxTables[i].Rows), len(docTables[i].Rows))
+			for r := range docxTables[i].Rows {
+				docxRow, docRow := docxTables[i].Rows[r], docTables[i].Rows[r]
+				require.Equal(t, len(docxRow), len(docRow))
+				for c := range docxRow {
+					assert.Equal(t, docxRow[c].GridSpan, docRow[c].GridSpan, "table %d row %d cell %d", i, r, c)
+				}
+			}
+		}
+	})
+
+	t.Run("should read nested tables and merges from .docx", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"word/document.xml": wordBody(`<w:tbl>` +
+				`<w:tr><w:tc><w:tcPr><w:gridSpan w:val="2"/></w:tcPr><w:p><w:r><w:t>Wide</w:t></w:r></w:p></w:tc>` +
+				`<w:tc><w:tcPr><w:vMerge w:val="restart"/></w:tcPr><w:p><w:r><w:t>Tall</w:t></w:r></w:p></w:tc></w:tr>` +
+				`<w:tr><w:tc><w:p><w:r><w:t>A</w:t></w:r></w:p></w:tc>` +
+				`<w:tc><w:tbl><w:tr><w:tc><w:p><w:r><w:t>Inner</w:t></w:r></w:p></w:tc></w:tr></w:tbl><w:p/></w:tc>` +
+				`<w:tc><w:tcPr><w:vMerge/></w:tcPr><w:p/></w:tc></w:tr>` +
+				`</w:tbl><w:p><w:r><w:t>After</w:t></w:r></w:p>`),
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		tables := doc.Tables()
+		require.Len(t, tables, 1)
+		rows := tables[0].Rows
+		require.Len(t, rows, 2)
+
+		assert.Equal(t, 2, rows[0][0].GridSpan)
+		assert.Equal(t, word_extractor.MergeRestart, rows[0][1].VMerge)
+		assert.Equal(t, word_extractor.MergeContinue, rows[1][2].VMerge)
+
+		require.Len(t, rows[1][1].Blocks, 2)
+		inner := rows[1][1].Blocks[0].Table
+		require.NotNil(t, inner)
+		assert.Equal(t, "Inner", inner.Rows[0][0].Text)
+
+		assert.Equal(t, "Wide\tTall\t\nA\tInner\t\n\t\t\nAfter\n", doc.Body)
+	})
+}
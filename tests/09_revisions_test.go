@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevisions(t *testing.T) {
+	modes := []struct {
+		name string
+		mode word_extractor.RevisionMode
+		body string
+	}{
+		{"accept", word_extractor.AcceptRevisions, "This is a test of reviewing\n\nThis text has been inserted, and should be included\n\n"},
+		{"reject", word_extractor.RejectRevisions, "This is a test of reviewing\n\nThis text has been deleted, and should not be included\n"},
+		{"show", word_extractor.ShowRevisions, "This is a test of reviewing\n\n{+This text has b+}{+een inserted, and should be included+}\n[-This text has been deleted, and should not be included-]\n"},
+	}
+
+	for _, file := range []string{"test14.doc", "test14.docx"} {
+		for _, m := range modes {
+			t.Run(file+" "+m.name, func(t *testing.T) {
+				extractor := word_extractor.NewWordExtractor()
+				extractor.Options.Revisions = m.mode
+				doc, err := extractor.Extract(filepath.Join("data", file))
+				require.NoError(t, err)
+				assert.Equal(t, m.body, doc.GetBody(nil))
+			})
+		}
+
+		t.Run(file+" revision list", func(t *testing.T) {
+			doc, err := word_extractor.NewWordExtractor().Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			revisions := doc.Revisions()
+			require.Len(t, revisions, 3)
+			assert.Equal(t, word_extractor.Insertion, revisions[0].Type)
+			assert.Equal(t, "Stuart Watt", revisions[0].Author)
+			assert.Equal(t, time.Date(2021, 5, 11, 17, 9, 0, 0, time.UTC), revisions[0].Date)
+			assert.Equal(t, "This text has b", revisions[0].Text)
+			assert.Equal(t, 29, revisions[0].Offset)
+
+			assert.Equal(t, word_extractor.Deletion, revisions[2].Type)
+			assert.Equal(t, "This text has been deleted, and should not be included", revisions[2].Text)
+			assert.Equal(t, 81, revisions[2].Offset)
+		})
+	}
+
+	t.Run("should use character offsets with Unicode text", func(t *testing.T) {
+		for _, file := range []string{"test01.doc", "test01.docx"} {
+			doc, err := word_extractor.NewWordExtractor().Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			revisions := doc.Revisions()
+			require.Len(t, revisions, 2, file)
+			insertion := revisions[0]
+			body := []rune(doc.Body)
+			text := []rune(insertion.Text)
+			assert.Equal(t, insertion.Text, string(body[insertion.Offset:insertion.Offset+len(text)]), file)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const commentsContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/comments.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"/>
+<Override PartName="/word/commentsExtended.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.commentsExtended+xml"/>
+</Types>`
+
+func TestComments(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test10.doc", "test10.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			comments := doc.Comments()
+			require.Len(t, comments, 2)
+
+			first := comments[0]
+			assert.Equal(t, "0", first.ID)
+			assert.Equal(t, "Stuart Watt", first.Author)
+			assert.Equal(t, "SW", first.Initials)
+			assert.Equal(t, time.Date(2016, 1, 17, 21, 59, 0, 0, time.UTC), first.Date)
+			assert.Equal(t, "Second paragraph comment", first.Text)
+			assert.Equal(t, "Second paragraph", first.Anchor)
+			assert.Equal(t, first.Anchor, string([]rune(doc.Body)[first.Offset:first.Offset+first.Length]))
+
+			assert.Equal(t, "Third paragraph comment – and this is all I have to say on the matter", comments[1].Text)
+			assert.Equal(t, "Third paragraph", comments[1].Anchor)
+		})
+	}
+
+	t.Run("should thread replies from commentsExtended.xml", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"[Content_Types].xml": commentsContentTypes,
+			"word/document.xml": wordBody(`<w:p><w:r><w:t>Some </w:t></w:r><w:commentRangeStart w:id="1"/>` +
+				`<w:r><w:t>commented</w:t></w:r><w:commentRangeEnd w:id="1"/>` +
+				`<w:r><w:commentReference w:id="1"/></w:r><w:r><w:commentReference w:id="2"/></w:r><w:r><w:t> text</w:t></w:r></w:p>`),
+			"word/comments.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+				`<w:comments xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:w14="http://schemas.microsoft.com/office/word/2010/wordml">` +
+				`<w:comment w:id="1" w:author="Ann" w:initials="A" w:date="2024-03-01T10:00:00Z"><w:p w14:paraId="00000001"><w:r><w:t>Why?</w:t></w:r></w:p></w:comment>` +
+				`<w:comment w:id="2" w:author="Bob" w:initials="B" w:date="2024-03-02T11:30:00Z"><w:p w14:paraId="00000002"><w:r><w:t>Because.</w:t></w:r></w:p></w:comment>` +
+				`</w:comments>`,
+			"word/commentsExtended.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+				`<w15:commentsEx xmlns:w15="http://schemas.microsoft.com/office/word/2012/wordml">` +
+				`<w15:commentEx w15:paraId="00000001" w15:done="0"/><w15:commentEx w15:paraId="00000002" w15:paraIdParent="00000001" w15:done="0"/>` +
+				`</w15:commentsEx>`,
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		comments := doc.Comments()
+		require.Len(t, comments, 1)
+		assert.Equal(t, "Why?", comments[0].Text)
+		assert.Equal(t, "commented", comments[0].Anchor)
+		assert.Equal(t, 5, comments[0].Offset)
+
+		require.Len(t, comments[0].Replies, 1)
+		reply := comments[0].Replies[0]
+		assert.Equal(t, "2", reply.ID)
+		assert.Equal(t, "Bob", reply.Author)
+		assert.Equal(t, "Because.", reply.Text)
+		assert.Equal(t, 14, reply.Offset)
+		assert.Equal(t, 0, reply.Length)
+	})
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotes(t *testing.T) {
+	for _, file := range []string{"test13.doc", "test13.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := word_extractor.NewWordExtractor().Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			footnotes := doc.FootnoteList()
+			require.Len(t, footnotes, 1)
+			assert.Equal(t, "1", footnotes[0].ID)
+			assert.Equal(t, "This is a footnote", footnotes[0].Text)
+			assert.Equal(t, 40, footnotes[0].Offset)
+
+			endnotes := doc.EndnoteList()
+			require.Len(t, endnotes, 1)
+			assert.Equal(t, "This is an endnote", endnotes[0].Text)
+			assert.Equal(t, 53, endnotes[0].Offset)
+		})
+
+		t.Run(file+" with note markers", func(t *testing.T) {
+			extractor := word_extractor.NewWordExtractor()
+			extractor.Options.NoteMarkers = true
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			body := []rune(doc.Body)
+			footnote := doc.FootnoteList()[0]
+			assert.Equal(t, "[^1]", string(body[footnote.Offset:footnote.Offset+4]))
+			endnote := doc.EndnoteList()[0]
+			assert.Equal(t, "[^e1]", string(body[endnote.Offset:endnote.Offset+5]))
+		})
+	}
+
+	t.Run("should place notes inside tables", func(t *testing.T) {
+		for _, file := range []string{"test07.doc", "test07.docx"} {
+			doc, err := word_extractor.NewWordExtractor().Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			footnotes := doc.FootnoteList()
+			require.Len(t, footnotes, 1, file)
+			assert.Equal(t, 1014, footnotes[0].Offset, file)
+			assert.Empty(t, doc.EndnoteList(), file)
+		}
+	})
+}
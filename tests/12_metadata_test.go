@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	t.Run("should read the summary information of a .doc file", func(t *testing.T) {
+		doc, err := extractor.Extract(filepath.Join("data", "test07.doc"))
+		require.NoError(t, err)
+
+		meta := doc.Metadata
+		assert.Equal(t, "CREDO REFERENCE", meta.Title)
+		assert.Equal(t, "staalc", meta.Author)
+		assert.Equal(t, "Grays School of Art", meta.LastModifiedBy)
+		assert.Equal(t, "4", meta.Revision)
+		assert.Equal(t, time.Date(2004, 6, 24, 13, 28, 0, 0, time.UTC), meta.Created)
+		assert.Equal(t, time.Date(2004, 6, 24, 13, 40, 0, 0, time.UTC), meta.Modified)
+		assert.Equal(t, 3, meta.Pages)
+		assert.Equal(t, 984, meta.Words)
+		assert.Equal(t, "The Robert Gordon University", meta.Company)
+		assert.Equal(t, "Normal", meta.Template)
+		assert.Equal(t, "c.davidson@rgu.ac.uk", meta.Custom["_AuthorEmail"])
+		assert.Equal(t, "-1064008333", meta.Custom["_AdHocReviewCycleID"])
+		assert.NotContains(t, meta.Custom, "_PID_HLINKS")
+	})
+
+	t.Run("should read the property parts of a .docx file", func(t *testing.T) {
+		doc, err := extractor.Extract(filepath.Join("data", "test07.docx"))
+		require.NoError(t, err)
+
+		meta := doc.Metadata
+		assert.Equal(t, "CREDO REFERENCE", meta.Title)
+		assert.Equal(t, "staalc", meta.Author)
+		assert.Equal(t, "Stuart Watt", meta.LastModifiedBy)
+		assert.Equal(t, "2", meta.Revision)
+		assert.Equal(t, time.Date(2021, 5, 9, 21, 20, 0, 0, time.UTC), meta.Created)
+		assert.Equal(t, 3, meta.Pages)
+		assert.Equal(t, 1027, meta.Words)
+		assert.Equal(t, "The Robert Gordon University", meta.Company)
+		assert.Equal(t, "Normal.dotm", meta.Template)
+		assert.Equal(t, "c.davidson@rgu.ac.uk", meta.Custom["_AuthorEmail"])
+		assert.Equal(t, "-1064008333", meta.Custom["_AdHocReviewCycleID"])
+	})
+
+	t.Run("should leave metadata empty when a .docx has no property parts", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"word/document.xml": wordBody(`<w:p><w:r><w:t>Text</w:t></w:r></w:p>`),
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+		assert.Equal(t, word_extractor.Metadata{}, doc.Metadata)
+	})
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperlinks(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test03.doc", "test03.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			links := doc.Hyperlinks()
+			require.Len(t, links, 5)
+			assert.Equal(t, "GPL v3.0", links[0].Text)
+			assert.Equal(t, "http://www.opensource.org/licenses/gpl-3.0.html", links[0].URL)
+			assert.Equal(t, "LGPL v3.0", links[1].Text)
+			assert.Equal(t, "http://opensource.org/licenses/lgpl-3.0.html", links[1].URL)
+
+			body := []rune(doc.Body)
+			for _, link := range links {
+				assert.Equal(t, link.Text, string(body[link.Offset:link.Offset+link.Length]))
+			}
+
+			inline := doc.GetBody(&word_extractor.Options{InlineHyperlinks: true})
+			assert.True(t, strings.Contains(inline, "GPL v3.0 <http://www.opensource.org/licenses/gpl-3.0.html>"))
+			assert.NotContains(t, doc.GetBody(nil), "<http")
+		})
+	}
+
+	t.Run("should read anchors and field hyperlinks from .docx", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"word/document.xml": wordBody(`<w:p><w:hyperlink w:anchor="intro"><w:r><w:t>Introduction</w:t></w:r></w:hyperlink>` +
+				`<w:r><w:t xml:space="preserve"> and </w:t></w:r>` +
+				`<w:fldSimple w:instr=" HYPERLINK &quot;http://example.com/&quot; \l &quot;top&quot; "><w:r><w:t>example</w:t></w:r></w:fldSimple></w:p>`),
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		links := doc.Hyperlinks()
+		require.Len(t, links, 2)
+		assert.Equal(t, "Introduction", links[0].Text)
+		assert.Equal(t, "", links[0].URL)
+		assert.Equal(t, "intro", links[0].Anchor)
+		assert.Equal(t, "example", links[1].Text)
+		assert.Equal(t, "http://example.com/", links[1].URL)
+		assert.Equal(t, "top", links[1].Anchor)
+		assert.Equal(t, 17, links[1].Offset)
+
+		inline := doc.GetBody(&word_extractor.Options{InlineHyperlinks: true})
+		assert.Equal(t, "Introduction <#intro> and example <http://example.com/#top>\n", inline)
+	})
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFields(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test06.doc", "test06.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			fields := doc.Fields()
+			require.Len(t, fields, 11)
+			assert.Equal(t, "FILENAME", fields[0].Type)
+			assert.Equal(t, "Document1", fields[0].Result)
+
+			field := fields[2]
+			assert.Equal(t, "DOCPROPERTY", field.Type)
+			assert.Equal(t, `DOCPROPERTY "Telephone number" \* MERGEFORMAT`, field.Instruction)
+			assert.Equal(t, []string{"Telephone number"}, field.Arguments)
+			format, ok := field.Switch(`\*`)
+			assert.True(t, ok)
+			assert.Equal(t, "MERGEFORMAT", format)
+			assert.Equal(t, "432", field.Result)
+
+			body := []rune(doc.Body)
+			for _, field := range fields {
+				assert.Equal(t, field.Result, string(body[field.Offset:field.Offset+field.Length]))
+			}
+		})
+	}
+
+	t.Run("should read switches without arguments", func(t *testing.T) {
+		doc, err := extractor.Extract(filepath.Join("data", "test02.doc"))
+		require.NoError(t, err)
+
+		fields := doc.Fields()
+		require.Len(t, fields, 1)
+		assert.Equal(t, "SEQ", fields[0].Type)
+		assert.Equal(t, []string{"CHAPTER"}, fields[0].Arguments)
+		assert.Equal(t, []word_extractor.FieldSwitch{{Name: `\h`}, {Name: `\r`, Value: "1"}}, fields[0].Switches)
+	})
+
+	t.Run("should list nested fields after the field they are in", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"word/document.xml": wordBody(`<w:p><w:r><w:t xml:space="preserve">Dear </w:t></w:r>` +
+				`<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText xml:space="preserve"> IF </w:instrText></w:r>` +
+				`<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText xml:space="preserve"> MERGEFIELD Title </w:instrText></w:r>` +
+				`<w:r><w:fldChar w:fldCharType="end"/></w:r>` +
+				`<w:r><w:instrText xml:space="preserve"> = "" "Sir" "Madam" </w:instrText></w:r>` +
+				`<w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:t>Madam</w:t></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r>` +
+				`<w:r><w:t xml:space="preserve">, today is </w:t></w:r>` +
+				`<w:fldSimple w:instr=" DATE \@ &quot;d MMMM yyyy&quot; "><w:r><w:t>1 May 2024</w:t></w:r></w:fldSimple></w:p>`),
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+		assert.Equal(t, "Dear Madam, today is 1 May 2024\n", doc.Body)
+
+		fields := doc.Fields()
+		require.Len(t, fields, 3)
+		assert.Equal(t, "IF", fields[0].Type)
+		assert.Equal(t, `IF  = "" "Sir" "Madam"`, fields[0].Instruction)
+		assert.Equal(t, "Madam", fields[0].Result)
+		assert.Equal(t, 0, fields[0].Depth)
+
+		assert.Equal(t, "MERGEFIELD", fields[1].Type)
+		assert.Equal(t, []string{"Title"}, fields[1].Arguments)
+		assert.Equal(t, "", fields[1].Result)
+		assert.Equal(t, 1, fields[1].Depth)
+
+		assert.Equal(t, "DATE", fields[2].Type)
+		picture, _ := fields[2].Switch(`\@`)
+		assert.Equal(t, "d MMMM yyyy", picture)
+		assert.Equal(t, "1 May 2024", fields[2].Result)
+		assert.Equal(t, 21, fields[2].Offset)
+	})
+}
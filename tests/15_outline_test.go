@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stylesContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+</Types>`
+
+func TestOutline(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test15.doc", "test15.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			outline := doc.Outline()
+			require.Len(t, outline, 2)
+			assert.Equal(t, "Header test file", outline[0].Text)
+			assert.Equal(t, "Heading 1", outline[0].Style)
+			assert.Equal(t, 1, outline[0].Level)
+			assert.Equal(t, 0, outline[0].Offset)
+			assert.Equal(t, outline[1].Offset, outline[0].End)
+			require.Len(t, outline[0].Children, 2)
+			assert.Equal(t, "Section 1 body continued", outline[0].Children[0].Text)
+			assert.Equal(t, 2, outline[0].Children[0].Level)
+
+			second := outline[1]
+			assert.Equal(t, "Second section", second.Text)
+			assert.Equal(t, len([]rune(doc.Body)), second.End)
+			require.Len(t, second.Children, 2)
+			require.Len(t, second.Children[1].Children, 1)
+			assert.Equal(t, "Section 3 text", second.Children[1].Children[0].Text)
+			assert.Equal(t, 3, second.Children[1].Children[0].Level)
+
+			body := []rune(doc.Body)
+			assert.Equal(t, second.Text, string(body[second.Offset:second.Offset+second.Length]))
+		})
+	}
+
+	t.Run("should read outline levels from paragraphs and base styles", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"[Content_Types].xml": stylesContentTypes,
+			"word/styles.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+<w:style w:type="paragraph" w:styleId="Chapter"><w:name w:val="Chapter"/><w:pPr><w:outlineLvl w:val="0"/></w:pPr></w:style>
+<w:style w:type="paragraph" w:styleId="Appendix"><w:name w:val="Appendix"/><w:basedOn w:val="Chapter"/></w:style>
+</w:styles>`,
+			"word/document.xml": wordBody(`<w:p><w:pPr><w:pStyle w:val="Chapter"/></w:pPr><w:r><w:t>One</w:t></w:r></w:p>` +
+				`<w:p><w:pPr><w:outlineLvl w:val="1"/></w:pPr><w:r><w:t>Detail</w:t></w:r></w:p>` +
+				`<w:p><w:r><w:t>Text</w:t></w:r></w:p>` +
+				`<w:p><w:pPr><w:pStyle w:val="Appendix"/></w:pPr><w:r><w:t>Two</w:t></w:r></w:p>`),
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		paragraphs := doc.Paragraphs()
+		require.Len(t, paragraphs, 4)
+		assert.Equal(t, "Normal", paragraphs[2].Style)
+		assert.Equal(t, 0, paragraphs[2].Level)
+
+		outline := doc.Outline()
+		require.Len(t, outline, 2)
+		assert.Equal(t, "One", outline[0].Text)
+		assert.Equal(t, "Chapter", outline[0].Style)
+		assert.Equal(t, 16, outline[0].End)
+		require.Len(t, outline[0].Children, 1)
+		assert.Equal(t, "Detail", outline[0].Children[0].Text)
+		assert.Equal(t, 2, outline[0].Children[0].Level)
+		assert.Equal(t, 16, outline[0].Children[0].End)
+		assert.Equal(t, "Two", outline[1].Text)
+		assert.Equal(t, 1, outline[1].Level)
+	})
+}
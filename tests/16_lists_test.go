@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const numberingContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>
+</Types>`
+
+func listParagraph(numID, ilvl, text string) string {
+	return `<w:p><w:pPr><w:numPr><w:ilvl w:val="` + ilvl + `"/><w:numId w:val="` + numID + `"/></w:numPr></w:pPr>` +
+		`<w:r><w:t>` + text + `</w:t></w:r></w:p>`
+}
+
+func listLabels(doc *word_extractor.Document) []string {
+	var labels []string
+	for _, p := range doc.Paragraphs() {
+		if p.List != nil {
+			labels = append(labels, p.List.Label)
+		}
+	}
+	return labels
+}
+
+func TestLists(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test07.doc", "test07.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			labels := listLabels(doc)
+			require.Len(t, labels, 14)
+			assert.Equal(t, "1.", labels[0])
+			assert.Equal(t, "14.", labels[13])
+
+			body := doc.GetBody(&word_extractor.Options{ListLabels: true})
+			assert.True(t, strings.Contains(body, "\n1. Is the research solely literature-based?"))
+			assert.NotContains(t, doc.GetBody(nil), "1. Is the research")
+		})
+	}
+
+	for _, file := range []string{"test17.doc", "test17.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			var bullets []*word_extractor.ListItem
+			for _, p := range doc.Paragraphs() {
+				if p.List != nil && p.List.Bullet {
+					bullets = append(bullets, p.List)
+				}
+			}
+			require.Len(t, bullets, 6)
+			assert.Equal(t, "•", bullets[0].Label)
+			assert.Equal(t, 1, bullets[0].Level)
+		})
+	}
+
+	t.Run("should number nested levels with restarts and overrides", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"[Content_Types].xml": numberingContentTypes,
+			"word/numbering.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:abstractNum w:abstractNumId="0">
+<w:lvl w:ilvl="0"><w:start w:val="4"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%1."/></w:lvl>
+<w:lvl w:ilvl="1"><w:start w:val="1"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%1.%2"/></w:lvl>
+<w:lvl w:ilvl="2"><w:start w:val="1"/><w:numFmt w:val="lowerLetter"/><w:lvlText w:val="%1.%2(%3)"/></w:lvl>
+<w:lvl w:ilvl="3"><w:start w:val="1"/><w:numFmt w:val="lowerRoman"/><w:isLgl/><w:lvlText w:val="%1.%2.%3.%4"/></w:lvl>
+</w:abstractNum>
+<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+<w:num w:numId="2"><w:abstractNumId w:val="0"/><w:lvlOverride w:ilvl="0"><w:startOverride w:val="1"/></w:lvlOverride></w:num>
+</w:numbering>`,
+			"word/document.xml": wordBody(listParagraph("1", "0", "Obligations") +
+				listParagraph("1", "1", "Payment") +
+				listParagraph("1", "1", "Delivery") +
+				listParagraph("1", "2", "on time") +
+				listParagraph("1", "2", "in full") +
+				listParagraph("1", "3", "legal") +
+				listParagraph("1", "0", "Termination") +
+				listParagraph("1", "2", "skipped level") +
+				`<w:p><w:r><w:t>Schedule</w:t></w:r></w:p>` +
+				listParagraph("2", "0", "Restarted")),
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"4.", "4.1", "4.2", "4.2(a)", "4.2(b)", "4.2.2.1", "5.", "5.1(a)", "1."}, listLabels(doc))
+
+		paragraphs := doc.Paragraphs()
+		assert.Equal(t, 3, paragraphs[4].List.Level)
+		assert.Equal(t, 2, paragraphs[4].List.Number)
+		assert.Nil(t, paragraphs[8].List)
+
+		body := doc.GetBody(&word_extractor.Options{ListLabels: true})
+		assert.True(t, strings.HasPrefix(body, "4. Obligations\n4.1 Payment\n"))
+		assert.True(t, strings.Contains(body, "\n4.2(b) in full\n"))
+		assert.True(t, strings.HasSuffix(body, "Schedule\n1. Restarted\n"))
+	})
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const imagesContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Default Extension="png" ContentType="image/png"/>
+<Default Extension="emf" ContentType="image/x-emf"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+
+func TestImages(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test17.doc", "test17.docx"} {
+		t.Run(file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+
+			// The chart is an embedded object, not a picture
+			images := doc.Images()
+			require.Len(t, images, 1)
+			assert.Equal(t, "image1.jpeg", images[0].Name)
+			assert.Equal(t, "image/jpeg", images[0].ContentType)
+			assert.Equal(t, []byte{0xFF, 0xD8}, images[0].Data[:2])
+			assert.Equal(t, 6186, images[0].Offset)
+		})
+	}
+
+	t.Run("should read pictures with their alt text and anchors", func(t *testing.T) {
+		drawing := `<w:drawing><wp:inline><wp:docPr id="1" name="Picture 1" descr="A red square"/>` +
+			`<a:graphic><a:graphicData><pic:pic><pic:blipFill><a:blip r:embed="rId1"/></pic:blipFill></pic:pic></a:graphicData></a:graphic>` +
+			`</wp:inline></w:drawing>`
+		data := buildDocx(t, map[string]string{
+			"[Content_Types].xml": imagesContentTypes,
+			"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/image1.png"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/image2.emf"/>
+</Relationships>`,
+			"word/media/image1.png": "\x89PNG",
+			"word/media/image2.emf": "EMF",
+			"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+ xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+ xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"
+ xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"
+ xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"
+ xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006"
+ xmlns:v="urn:schemas-microsoft-com:vml"><w:body>` +
+				`<w:p><w:r><w:t xml:space="preserve">Logo: </w:t></w:r><w:r>` + drawing + `</w:r></w:p>` +
+				`<w:p><w:r><w:t>Chart</w:t></w:r><w:r><w:object><v:shape><v:imagedata r:id="rId2"/></v:shape></w:object></w:r></w:p>` +
+				`<w:p><w:r><w:t>Again</w:t></w:r><w:r><mc:AlternateContent><mc:Choice Requires="wps">` + drawing +
+				`</mc:Choice><mc:Fallback><w:pict><v:shape alt="A red square"><v:imagedata r:id="rId1"/></v:shape></w:pict></mc:Fallback></mc:AlternateContent></w:r></w:p>` +
+				`<w:p><w:r><w:pict><v:shape><v:imagedata r:id="rId2" o:title="Diagram" xmlns:o="urn:schemas-microsoft-com:office:office"/></v:shape></w:pict></w:r></w:p>` +
+				`</w:body></w:document>`,
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		images := doc.Images()
+		require.Len(t, images, 3)
+		assert.Equal(t, "image1.png", images[0].Name)
+		assert.Equal(t, "image/png", images[0].ContentType)
+		assert.Equal(t, []byte("\x89PNG"), images[0].Data)
+		assert.Equal(t, "A red square", images[0].AltText)
+		assert.Equal(t, 6, images[0].Offset)
+
+		assert.Equal(t, "image1.png", images[1].Name)
+		assert.Equal(t, 18, images[1].Offset)
+
+		assert.Equal(t, "image2.emf", images[2].Name)
+		assert.Equal(t, "image/x-emf", images[2].ContentType)
+		assert.Equal(t, "Diagram", images[2].AltText)
+	})
+}
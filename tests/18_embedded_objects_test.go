@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var compoundSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+func TestEmbeddedObjects(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	t.Run("should read objects from the object pool of .doc files", func(t *testing.T) {
+		doc, err := extractor.Extract(filepath.Join("data", "test06.doc"))
+		require.NoError(t, err)
+
+		objects := doc.EmbeddedObjects()
+		require.Len(t, objects, 1)
+		assert.Equal(t, "MS_ClipArt_Gallery", objects[0].ProgID)
+		assert.Equal(t, "_1012299795", objects[0].Name)
+		assert.Equal(t, compoundSignature, objects[0].Data[:8])
+		assert.Nil(t, objects[0].Document)
+	})
+
+	t.Run("should read objects from the embeddings of .docx files", func(t *testing.T) {
+		inner := buildDocx(t, map[string]string{
+			"word/document.xml": wordBody(`<w:p><w:r><w:t>Embedded text</w:t></w:r></w:p>`),
+		})
+		oldDoc, err := os.ReadFile(filepath.Join("data", "test01.doc"))
+		require.NoError(t, err)
+		data := buildDocx(t, map[string]string{
+			"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/package" Target="embeddings/Microsoft_Word_Document.docx"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/oleObject" Target="embeddings/oleObject1.bin"/>
+</Relationships>`,
+			"word/embeddings/Microsoft_Word_Document.docx":   string(inner),
+			"word/embeddings/oleObject1.bin":                 string(oldDoc),
+			"word/embeddings/Microsoft_Excel_Worksheet.xlsx": "PK",
+			"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+ xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+ xmlns:o="urn:schemas-microsoft-com:office:office"><w:body>` +
+				`<w:p><w:r><w:t>Main text</w:t></w:r><w:r><w:object><o:OLEObject Type="Embed" ProgID="Word.Document.12" r:id="rId1"/></w:object></w:r></w:p>` +
+				`<w:p><w:r><w:object><o:OLEObject Type="Embed" ProgID="Word.Document.8" r:id="rId2"/></w:object></w:r></w:p>` +
+				`</w:body></w:document>`,
+		})
+
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+		assert.Equal(t, "Main text\n\n", doc.Body)
+
+		objects := doc.EmbeddedObjects()
+		require.Len(t, objects, 3)
+		assert.Equal(t, "Word.Document.12", objects[0].ProgID)
+		assert.Equal(t, "Microsoft_Word_Document.docx", objects[0].Name)
+		assert.Equal(t, inner, objects[0].Data)
+		assert.Equal(t, "Word.Document.8", objects[1].ProgID)
+		assert.Equal(t, "oleObject1.bin", objects[1].Name)
+		assert.Equal(t, oldDoc, objects[1].Data)
+		assert.Equal(t, "", objects[2].ProgID)
+		assert.Equal(t, "Microsoft_Excel_Worksheet.xlsx", objects[2].Name)
+
+		embedding := word_extractor.NewWordExtractor()
+		embedding.Options.EmbeddedDocuments = true
+		doc, err = embedding.Extract(data)
+		require.NoError(t, err)
+
+		objects = doc.EmbeddedObjects()
+		require.NotNil(t, objects[0].Document)
+		require.NotNil(t, objects[1].Document)
+		assert.Nil(t, objects[2].Document)
+		assert.Equal(t, "Embedded text\n", objects[0].Document.Body)
+		assert.True(t, strings.HasPrefix(doc.Body, "Main text\n\nEmbedded text\n"))
+		assert.True(t, strings.HasSuffix(doc.Body, objects[1].Document.Body))
+
+		body := []rune(doc.Body)
+		paragraphs := doc.Paragraphs()
+		assert.Equal(t, "Embedded text", paragraphs[2].Text)
+		for _, p := range paragraphs {
+			assert.Equal(t, p.Text, string(body[p.Offset:p.Offset+len([]rune(p.Text))]))
+		}
+	})
+	t.Run("should not extract documents embedded too deeply", func(t *testing.T) {
+		// Each level embeds the next, as a document crafted to recurse would
+		data := buildDocx(t, map[string]string{"word/document.xml": wordBody(`<w:p><w:r><w:t>Level 20</w:t></w:r></w:p>`)})
+		for level := 19; level >= 0; level-- {
+			data = buildDocx(t, map[string]string{
+				"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/package" Target="embeddings/Microsoft_Word_Document.docx"/>
+</Relationships>`,
+				"word/embeddings/Microsoft_Word_Document.docx": string(data),
+				"word/document.xml": wordBody(fmt.Sprintf(`<w:p><w:r><w:t>Level %d</w:t></w:r></w:p>`, level) +
+					`<w:p><w:r><w:object xmlns:o="urn:schemas-microsoft-com:office:office" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+					`<o:OLEObject Type="Embed" ProgID="Word.Document.12" r:id="rId1"/></w:object></w:r></w:p>`),
+			})
+		}
+
+		embedding := word_extractor.NewWordExtractor()
+		embedding.Options.EmbeddedDocuments = true
+		doc, err := embedding.Extract(data)
+		require.NoError(t, err)
+		assert.Contains(t, doc.Body, "Level 8\n")
+		assert.NotContains(t, doc.Body, "Level 9")
+	})
+}
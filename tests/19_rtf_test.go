@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"testing"
+	"time"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rtfDocument = `{\rtf1\ansi\ansicpg1252\uc1\deff0{\fonttbl{\f0\froman\fcharset0 Times New Roman;}{\f1\fswiss\fcharset204 Arial;}}
+{\colortbl;\red0\green0\blue0;}
+{\stylesheet{\ql \snext0 Normal;}{\s1\ql \outlinelevel0\sbasedon0 \snext0 heading 1;}{\*\cs10 \additive Default Paragraph Font;}}
+{\*\revtbl {Unknown;}{John Smith;}}
+{\info{\title Sample title}{\author Jane Doe}{\creatim\yr2003\mo9\dy12\hr10\min1}{\nofpages1}{\*\company Acme}}
+{\header \pard\plain Page header\par}
+{\footer \pard\plain Page footer\par}
+\pard\plain \s1 {Heading\par}
+\pard\plain Caf\'e9, {\f1 \'cf\'f0\'e8\'e2\'e5\'f2} and \u8364? and \u-10179?\u-8576?\par
+\pard Footnote{\super\chftn{\footnote \pard\plain {\super\chftn} This is a footnote\par}} and endnote{\super\chftn{\footnote\ftnalt \pard\plain {\super\chftn} This is an endnote\par}}.\par
+\pard {\*\atrfstart 1}Commented{\*\atrfend 1}{\*\atnid JD}{\*\atnauthor Jane Doe}\chatn{\*\annotation{\*\atnref 1}\pard\plain A comment\par} text\par
+\pard See {\field{\*\fldinst {HYPERLINK "http://example.com/" }}{\fldrslt {\ul the site}}}\tab now\par
+\pard Keep {\deleted\revauthdel1 gone }{\revised\revauth1 new }end\par
+{\listtext\pard\plain 1.\tab}\pard\ls1\ilvl0 Item\par
+}`
+
+func TestRtf(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	t.Run("should read the text and sections of RTF documents", func(t *testing.T) {
+		doc, err := extractor.Extract([]byte(rtfDocument))
+		require.NoError(t, err)
+
+		assert.Equal(t, "Heading\nCafé, Привет and € and 🚀\nFootnote and endnote.\nCommented text\nSee the site\tnow\nKeep new end\nItem\n", doc.Body)
+		assert.Equal(t, "Page header\n", doc.Headers)
+		assert.Equal(t, "Page footer\n", doc.Footers)
+		assert.Equal(t, " This is a footnote\n", doc.Footnotes)
+		assert.Equal(t, " This is an endnote\n", doc.Endnotes)
+		assert.Equal(t, "A comment\n", doc.Annotations)
+
+		paragraphs := doc.Paragraphs()
+		require.Len(t, paragraphs, 7)
+		assert.Equal(t, "heading 1", paragraphs[0].Style)
+		assert.Equal(t, 1, paragraphs[0].Level)
+		assert.Equal(t, "Normal", paragraphs[1].Style)
+		require.NotNil(t, paragraphs[6].List)
+		assert.Equal(t, "1.", paragraphs[6].List.Label)
+
+		footnotes := doc.FootnoteList()
+		require.Len(t, footnotes, 1)
+		assert.Equal(t, "This is a footnote", footnotes[0].Text)
+		assert.Equal(t, 41, footnotes[0].Offset)
+		endnotes := doc.EndnoteList()
+		require.Len(t, endnotes, 1)
+		assert.Equal(t, "This is an endnote", endnotes[0].Text)
+
+		comments := doc.Comments()
+		require.Len(t, comments, 1)
+		assert.Equal(t, "Jane Doe", comments[0].Author)
+		assert.Equal(t, "JD", comments[0].Initials)
+		assert.Equal(t, "A comment", comments[0].Text)
+		assert.Equal(t, "Commented", comments[0].Anchor)
+
+		links := doc.Hyperlinks()
+		require.Len(t, links, 1)
+		assert.Equal(t, "http://example.com/", links[0].URL)
+		assert.Equal(t, "the site", links[0].Text)
+		require.Len(t, doc.Fields(), 1)
+		assert.Equal(t, "HYPERLINK", doc.Fields()[0].Type)
+
+		revisions := doc.Revisions()
+		require.Len(t, revisions, 2)
+		assert.Equal(t, word_extractor.Deletion, revisions[0].Type)
+		assert.Equal(t, "John Smith", revisions[0].Author)
+		assert.Equal(t, "gone ", revisions[0].Text)
+
+		assert.Equal(t, "Sample title", doc.Metadata.Title)
+		assert.Equal(t, "Jane Doe", doc.Metadata.Author)
+		assert.Equal(t, "Acme", doc.Metadata.Company)
+		assert.Equal(t, 1, doc.Metadata.Pages)
+		assert.Equal(t, time.Date(2003, 9, 12, 10, 1, 0, 0, time.UTC), doc.Metadata.Created)
+	})
+
+	t.Run("should apply the extraction options to RTF documents", func(t *testing.T) {
+		extractor := word_extractor.NewWordExtractor()
+		extractor.Options.Revisions = word_extractor.ShowRevisions
+		extractor.Options.NoteMarkers = true
+		doc, err := extractor.Extract([]byte(rtfDocument))
+		require.NoError(t, err)
+
+		assert.Contains(t, doc.Body, "Footnote[^1] and endnote[^e1].")
+		assert.Contains(t, doc.Body, "Keep [-gone -]{+new +}end")
+	})
+
+	t.Run("should read RTF tables", func(t *testing.T) {
+		doc, err := extractor.Extract([]byte(`{\rtf1\ansi
+\trowd\cellx3000\clmgf\cellx6000\clmrg\cellx9000
+\pard\intbl A1\cell B1\cell C1\cell \pard\intbl{\trowd\cellx3000\clmgf\cellx6000\clmrg\cellx9000\row}
+\trowd\cellx3000\cellx9000
+\pard\intbl A2\cell \pard\intbl\itap2 Inner\nestcell{\*\nesttableprops\trowd\cellx2000\nestrow}{\nonesttables\par}\pard\intbl\itap1 B2\cell \pard\intbl{\trowd\cellx3000\cellx9000\row}
+\pard After\par}`))
+		require.NoError(t, err)
+
+		assert.Equal(t, "A1\tB1\tC1\t\nA2\tInner\t\nB2\t\nAfter\n", doc.Body)
+		tables := doc.Tables()
+		require.Len(t, tables, 1)
+		require.Len(t, tables[0].Rows, 2)
+		assert.Equal(t, []string{"A1", "B1"}, cellTexts(tables[0].Rows[0]))
+		assert.Equal(t, 2, tables[0].Rows[0][1].GridSpan)
+		assert.Equal(t, []string{"Inner"}, cellTexts(tables[0].Rows[1][1].Blocks[0].Table.Rows[0]))
+	})
+}
@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildOdt builds an OpenDocument text package from its files. The mimetype
+// file comes first and is stored uncompressed, as the format requires.
+func buildOdt(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("application/vnd.oasis.opendocument.text"))
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(parts[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+const odfNamespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+ xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+ xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+ xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+ xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"
+ xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"
+ xmlns:xlink="http://www.w3.org/1999/xlink"
+ xmlns:dc="http://purl.org/dc/elements/1.1/"
+ xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" office:version="1.3"`
+
+// odtContent wraps body content and automatic styles in a content.xml file
+func odtContent(styles, body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content ` + odfNamespaces + `><office:automatic-styles>` + styles +
+		`</office:automatic-styles><office:body><office:text>` + body + `</office:text></office:body></office:document-content>`
+}
+
+var odtStyles = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-styles ` + odfNamespaces + `><office:styles>
+<style:style style:name="Standard" style:family="paragraph"/>
+<style:style style:name="Heading" style:family="paragraph" style:parent-style-name="Standard"/>
+<style:style style:name="Heading_20_1" style:display-name="Heading 1" style:family="paragraph" style:parent-style-name="Heading" style:default-outline-level="1"/>
+<text:list-style style:name="Numbering_20_123">
+<text:list-level-style-number text:level="1" style:num-suffix="." style:num-format="1"/>
+<text:list-level-style-number text:level="2" style:num-suffix=")" style:num-format="a" text:display-levels="2"/>
+</text:list-style>
+</office:styles><office:master-styles><style:master-page style:name="Standard">
+<style:header><text:p>Page header</text:p></style:header>
+<style:header-left style:display="false"><text:p>Hidden header</text:p></style:header-left>
+<style:footer><text:p>Page <text:page-number>1</text:page-number></text:p></style:footer>
+</style:master-page></office:master-styles></office:document-styles>`
+
+var odtMeta = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta ` + odfNamespaces + `><office:meta>
+<meta:generator>LibreOffice/7.6</meta:generator><dc:title>Sample title</dc:title>
+<meta:initial-creator>Jane Doe</meta:initial-creator><dc:creator>John Smith</dc:creator>
+<meta:creation-date>2024-03-01T10:20:30.123000000</meta:creation-date><meta:keyword>one</meta:keyword><meta:keyword>two</meta:keyword>
+<meta:document-statistic meta:page-count="2" meta:word-count="40"/>
+<meta:user-defined meta:name="Client">Acme</meta:user-defined>
+</office:meta></office:document-meta>`
+
+func TestOdt(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	content := odtContent(`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Standard"/>`,
+		`<text:tracked-changes><text:changed-region text:id="ct1"><text:deletion><office:change-info><dc:creator>John Smith</dc:creator><dc:date>2024-03-02T09:00:00</dc:date></office:change-info><text:p>gone </text:p></text:deletion></text:changed-region>`+
+			`<text:changed-region text:id="ct2"><text:insertion><office:change-info><dc:creator>John Smith</dc:creator><dc:date>2024-03-02T09:00:00</dc:date></office:change-info></text:insertion></text:changed-region></text:tracked-changes>`+
+			`<text:h text:style-name="Heading_20_1" text:outline-level="1"><text:number>1</text:number>Heading</text:h>`+
+			`<text:p text:style-name="P1">Some   <text:span>spaced</text:span><text:s text:c="2"/>text<text:tab/>here</text:p>`+
+			`<text:p>Footnote<text:note text:id="ftn1" text:note-class="footnote"><text:note-citation>1</text:note-citation><text:note-body><text:p>This is a footnote</text:p></text:note-body></text:note> and endnote<text:note text:id="edn1" text:note-class="endnote"><text:note-citation>i</text:note-citation><text:note-body><text:p>This is an endnote</text:p></text:note-body></text:note>.</text:p>`+
+			`<text:p><office:annotation office:name="c1"><dc:creator>Jane Doe</dc:creator><dc:date>2024-03-01T11:00:00</dc:date><text:p>A comment</text:p></office:annotation>Commented<office:annotation-end office:name="c1"/> text</text:p>`+
+			`<text:p>See <text:a xlink:type="simple" xlink:href="http://example.com/">the site</text:a> now</text:p>`+
+			`<text:p>Keep <text:change text:change-id="ct1"/><text:change-start text:change-id="ct2"/>new <text:change-end text:change-id="ct2"/>end</text:p>`+
+			`<text:list text:style-name="Numbering_20_123"><text:list-item><text:p>First</text:p><text:list><text:list-item><text:p>Nested</text:p></text:list-item></text:list></text:list-item><text:list-item><text:p>Second</text:p></text:list-item></text:list>`+
+			`<text:p><draw:frame draw:name="Shape"><draw:text-box><text:p>Boxed</text:p></draw:text-box></draw:frame>After box</text:p>`)
+
+	data := buildOdt(t, map[string]string{
+		"content.xml": content,
+		"styles.xml":  odtStyles,
+		"meta.xml":    odtMeta,
+	})
+
+	t.Run("should read the text and sections of .odt files", func(t *testing.T) {
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Heading\nSome spaced  text\there\nFootnote and endnote.\nCommented text\nSee the site now\nKeep new end\nFirst\nNested\nSecond\nAfter box\n", doc.Body)
+		assert.Equal(t, "Page header\n", doc.Headers)
+		assert.Equal(t, "Page 1\n", doc.Footers)
+		assert.Equal(t, "This is a footnote\n", doc.Footnotes)
+		assert.Equal(t, "This is an endnote\n", doc.Endnotes)
+		assert.Equal(t, "A comment\n", doc.Annotations)
+		assert.Equal(t, "Boxed\n\n", doc.Textboxes)
+
+		paragraphs := doc.Paragraphs()
+		require.Len(t, paragraphs, 10)
+		assert.Equal(t, "Heading 1", paragraphs[0].Style)
+		assert.Equal(t, 1, paragraphs[0].Level)
+		assert.Equal(t, "Standard", paragraphs[1].Style)
+		assert.Equal(t, 0, paragraphs[1].Level)
+		labels := []string{}
+		for _, p := range paragraphs[6:9] {
+			require.NotNil(t, p.List)
+			labels = append(labels, p.List.Label)
+		}
+		assert.Equal(t, []string{"1.", "1.a)", "2."}, labels)
+
+		footnotes := doc.FootnoteList()
+		require.Len(t, footnotes, 1)
+		assert.Equal(t, "ftn1", footnotes[0].ID)
+		assert.Equal(t, "This is a footnote", footnotes[0].Text)
+		assert.Equal(t, 39, footnotes[0].Offset)
+		require.Len(t, doc.EndnoteList(), 1)
+		assert.Equal(t, "This is an endnote", doc.EndnoteList()[0].Text)
+
+		comments := doc.Comments()
+		require.Len(t, comments, 1)
+		assert.Equal(t, "Jane Doe", comments[0].Author)
+		assert.Equal(t, "A comment", comments[0].Text)
+		assert.Equal(t, "Commented", comments[0].Anchor)
+		assert.Equal(t, time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC), comments[0].Date)
+
+		links := doc.Hyperlinks()
+		require.Len(t, links, 1)
+		assert.Equal(t, "http://example.com/", links[0].URL)
+		assert.Equal(t, "the site", links[0].Text)
+		fields := doc.Fields()
+		require.Len(t, fields, 1)
+		assert.Equal(t, "HYPERLINK", fields[0].Type)
+
+		revisions := doc.Revisions()
+		require.Len(t, revisions, 2)
+		assert.Equal(t, word_extractor.Deletion, revisions[0].Type)
+		assert.Equal(t, "gone ", revisions[0].Text)
+		assert.Equal(t, word_extractor.Insertion, revisions[1].Type)
+		assert.Equal(t, "new ", revisions[1].Text)
+		assert.Equal(t, "John Smith", revisions[1].Author)
+
+		assert.Equal(t, "Sample title", doc.Metadata.Title)
+		assert.Equal(t, "Jane Doe", doc.Metadata.Author)
+		assert.Equal(t, "John Smith", doc.Metadata.LastModifiedBy)
+		assert.Equal(t, "one, two", doc.Metadata.Keywords)
+		assert.Equal(t, 2, doc.Metadata.Pages)
+		assert.Equal(t, "LibreOffice/7.6", doc.Metadata.Application)
+		assert.Equal(t, "Acme", doc.Metadata.Custom["Client"])
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 20, 30, 123000000, time.UTC), doc.Metadata.Created)
+	})
+
+	t.Run("should apply the extraction options to .odt files", func(t *testing.T) {
+		extractor := word_extractor.NewWordExtractor()
+		extractor.Options.Revisions = word_extractor.ShowRevisions
+		extractor.Options.NoteMarkers = true
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		assert.Contains(t, doc.Body, "Footnote[^1] and endnote[^e1].")
+		assert.Contains(t, doc.Body, "Keep [-gone -]{+new +}end")
+
+		extractor.Options.Revisions = word_extractor.RejectRevisions
+		doc, err = extractor.Extract(data)
+		require.NoError(t, err)
+		assert.Contains(t, doc.Body, "Keep gone end")
+	})
+
+	t.Run("should read .odt tables and pictures", func(t *testing.T) {
+		png := "\x89PNG\r\n\x1a\n"
+		data := buildOdt(t, map[string]string{
+			"content.xml": odtContent("", `<table:table table:name="Table1"><table:table-column table:number-columns-repeated="3"/>`+
+				`<table:table-row><table:table-cell><text:p>A1</text:p></table:table-cell><table:table-cell table:number-columns-spanned="2" table:number-rows-spanned="2"><text:p>B1</text:p></table:table-cell><table:covered-table-cell/></table:table-row>`+
+				`<table:table-row><table:table-cell><text:p>A2</text:p><table:table><table:table-row><table:table-cell><text:p>Inner</text:p></table:table-cell></table:table-row></table:table></table:table-cell><table:covered-table-cell/><table:covered-table-cell/></table:table-row>`+
+				`</table:table><text:p>Picture <draw:frame draw:name="Image1"><draw:image xlink:href="Pictures/red.png"/><svg:title>Red</svg:title><svg:desc>A red square</svg:desc></draw:frame></text:p>`),
+			"Pictures/red.png": png,
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		assert.Equal(t, "A1\tB1\t\nA2\nInner\t\t\t\nPicture \n", doc.Body)
+		tables := doc.Tables()
+		require.Len(t, tables, 1)
+		require.Len(t, tables[0].Rows, 2)
+		assert.Equal(t, []string{"A1", "B1"}, cellTexts(tables[0].Rows[0]))
+		assert.Equal(t, 2, tables[0].Rows[0][1].GridSpan)
+		assert.Equal(t, word_extractor.MergeRestart, tables[0].Rows[0][1].VMerge)
+		require.Len(t, tables[0].Rows[1], 2)
+		assert.Equal(t, word_extractor.MergeContinue, tables[0].Rows[1][1].VMerge)
+		assert.Equal(t, 2, tables[0].Rows[1][1].GridSpan)
+		assert.Equal(t, []string{"Inner"}, cellTexts(tables[0].Rows[1][0].Blocks[1].Table.Rows[0]))
+
+		images := doc.Images()
+		require.Len(t, images, 1)
+		assert.Equal(t, "red.png", images[0].Name)
+		assert.Equal(t, "image/png", images[0].ContentType)
+		assert.Equal(t, []byte(png), images[0].Data)
+		assert.Equal(t, "A red square", images[0].AltText)
+		assert.Equal(t, 27, images[0].Offset)
+	})
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"testing"
+	"time"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const flatOpcDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<?mso-application progid="Word.Document"?>
+<pkg:package xmlns:pkg="http://schemas.microsoft.com/office/2006/xmlPackage">
+ <pkg:part pkg:name="/_rels/.rels" pkg:contentType="application/vnd.openxmlformats-package.relationships+xml">
+  <pkg:xmlData><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/></Relationships></pkg:xmlData>
+ </pkg:part>
+ <pkg:part pkg:name="/word/document.xml" pkg:contentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml">
+  <pkg:xmlData><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body><w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Flat heading</w:t></w:r></w:p><w:p><w:r><w:t xml:space="preserve">See </w:t></w:r><w:hyperlink r:id="rId5"><w:r><w:t>the site</w:t></w:r></w:hyperlink></w:p></w:body></w:document></pkg:xmlData>
+ </pkg:part>
+ <pkg:part pkg:name="/word/_rels/document.xml.rels" pkg:contentType="application/vnd.openxmlformats-package.relationships+xml">
+  <pkg:xmlData><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId5" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="http://example.com/" TargetMode="External"/></Relationships></pkg:xmlData>
+ </pkg:part>
+ <pkg:part pkg:name="/word/styles.xml" pkg:contentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml">
+  <pkg:xmlData><w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/></w:style></w:styles></pkg:xmlData>
+ </pkg:part>
+ <pkg:part pkg:name="/docProps/core.xml" pkg:contentType="application/vnd.openxmlformats-package.core-properties+xml">
+  <pkg:xmlData><cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Flat title</dc:title></cp:coreProperties></pkg:xmlData>
+ </pkg:part>
+</pkg:package>`
+
+const wordML2003Document = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<?mso-application progid="Word.Document"?>
+<w:wordDocument xmlns:w="http://schemas.microsoft.com/office/word/2003/wordml" xmlns:v="urn:schemas-microsoft-com:vml" xmlns:o="urn:schemas-microsoft-com:office:office" xmlns:aml="http://schemas.microsoft.com/aml/2001/core" xmlns:wx="http://schemas.microsoft.com/office/word/2003/auxHint" xmlns:dt="uuid:C2F41010-65B3-11d1-A29F-00AA00C14882">
+ <o:DocumentProperties><o:Title>Sample title</o:Title><o:Author>Jane Doe</o:Author><o:LastAuthor>John Smith</o:LastAuthor><o:Created>2003-09-12T10:01:00Z</o:Created><o:Pages>1</o:Pages><o:Company>Acme</o:Company></o:DocumentProperties>
+ <o:CustomDocumentProperties><o:Client_x0020_Name dt:dt="string">Contoso</o:Client_x0020_Name></o:CustomDocumentProperties>
+ <w:lists><w:listDef w:listDefId="0"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:nfc w:val="4"/><w:lvlText w:val="%1)"/></w:lvl></w:listDef><w:list w:ilfo="1"><w:ilst w:val="0"/></w:list></w:lists>
+ <w:styles><w:style w:type="paragraph" w:default="on" w:styleId="Normal"><w:name w:val="Normal"/></w:style><w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/></w:style></w:styles>
+ <w:docPr><w:footnotePr><w:footnote w:type="separator"><w:p><w:r><w:separator/></w:r></w:p></w:footnote></w:footnotePr></w:docPr>
+ <w:body><wx:sect><w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Heading</w:t></w:r></w:p><w:p><w:r><w:t>Footnote</w:t></w:r><w:r><w:footnote><w:p><w:r><w:t>A note</w:t></w:r></w:p></w:footnote></w:r><w:r><w:t xml:space="preserve"> here</w:t></w:r></w:p><w:p><aml:annotation aml:id="0" w:type="Word.Comment.Start"/><w:r><w:t>Commented</w:t></w:r><aml:annotation aml:id="0" w:type="Word.Comment.End"/><w:r><aml:annotation aml:id="0" aml:author="Jane Doe" aml:createdate="2003-09-12T10:01:00Z" w:type="Word.Comment" w:initials="JD"><aml:content><w:p><w:r><w:t>A comment</w:t></w:r></w:p></aml:content></aml:annotation></w:r><w:r><w:t xml:space="preserve"> text</w:t></w:r></w:p><w:p><w:r><w:t xml:space="preserve">Keep </w:t></w:r><aml:annotation aml:id="1" aml:author="John Smith" aml:createdate="2003-09-13T00:00:00Z" w:type="Word.Deletion"><aml:content><w:r><w:delText>gone </w:delText></w:r></aml:content></aml:annotation><aml:annotation aml:id="2" aml:author="John Smith" aml:createdate="2003-09-13T00:00:00Z" w:type="Word.Insertion"><aml:content><w:r><w:t xml:space="preserve">new </w:t></w:r></aml:content></aml:annotation><w:r><w:t>end</w:t></w:r></w:p><w:p><w:r><w:t xml:space="preserve">See </w:t></w:r><w:hlink w:dest="http://example.com/"><w:r><w:t>the site</w:t></w:r></w:hlink></w:p><w:p><w:pPr><w:listPr><w:ilvl w:val="0"/><w:ilfo w:val="1"/></w:listPr></w:pPr><w:r><w:t>Item</w:t></w:r></w:p><w:tbl><w:tr><w:tc><w:tcPr><w:vmerge w:val="restart"/></w:tcPr><w:p><w:r><w:t>A1</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>B1</w:t></w:r></w:p></w:tc></w:tr><w:tr><w:tc><w:tcPr><w:vmerge/></w:tcPr><w:p/></w:tc><w:tc><w:p><w:r><w:t>B2</w:t></w:r></w:p></w:tc></w:tr></w:tbl><w:p><w:r><w:pict><w:binData w:name="wordml://02000001.png">iVBORw0K
+GgoAAAAN</w:binData><v:shape id="_x0000_i1025" alt="A picture"><v:imagedata src="wordml://02000001.png" o:title=""/></v:shape></w:pict></w:r></w:p><w:sectPr><w:hdr w:type="odd"><w:p><w:r><w:t>Page header</w:t></w:r></w:p></w:hdr><w:ftr w:type="odd"><w:p><w:r><w:t>Page footer</w:t></w:r></w:p></w:ftr></w:sectPr></wx:sect></w:body>
+</w:wordDocument>`
+
+func TestWordXml(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	t.Run("should read Flat OPC documents", func(t *testing.T) {
+		doc, err := extractor.Extract([]byte(flatOpcDocument))
+		require.NoError(t, err)
+
+		assert.Equal(t, "Flat heading\nSee the site\n", doc.Body)
+		paragraphs := doc.Paragraphs()
+		require.Len(t, paragraphs, 2)
+		assert.Equal(t, "Heading 1", paragraphs[0].Style)
+		assert.Equal(t, 1, paragraphs[0].Level)
+
+		links := doc.Hyperlinks()
+		require.Len(t, links, 1)
+		assert.Equal(t, "http://example.com/", links[0].URL)
+		assert.Equal(t, "the site", links[0].Text)
+		assert.Equal(t, "Flat title", doc.Metadata.Title)
+	})
+
+	t.Run("should read Word 2003 XML documents", func(t *testing.T) {
+		doc, err := extractor.Extract([]byte(wordML2003Document))
+		require.NoError(t, err)
+
+		assert.Equal(t, "Heading\nFootnote here\nCommented text\nKeep new end\nSee the site\nItem\nA1\tB1\t\n\tB2\t\n\n", doc.Body)
+		assert.Equal(t, "Page header\n", doc.Headers)
+		assert.Equal(t, "Page footer\n", doc.Footers)
+		assert.Equal(t, "A note\n", doc.Footnotes)
+		assert.Equal(t, "A comment\n", doc.Annotations)
+
+		paragraphs := doc.Paragraphs()
+		require.Len(t, paragraphs, 11)
+		assert.Equal(t, "Heading 1", paragraphs[0].Style)
+		assert.Equal(t, 1, paragraphs[0].Level)
+		assert.Equal(t, "Normal", paragraphs[1].Style)
+		require.NotNil(t, paragraphs[5].List)
+		assert.Equal(t, "a)", paragraphs[5].List.Label)
+
+		footnotes := doc.FootnoteList()
+		require.Len(t, footnotes, 1)
+		assert.Equal(t, "A note", footnotes[0].Text)
+		assert.Equal(t, 16, footnotes[0].Offset)
+
+		comments := doc.Comments()
+		require.Len(t, comments, 1)
+		assert.Equal(t, "Jane Doe", comments[0].Author)
+		assert.Equal(t, "JD", comments[0].Initials)
+		assert.Equal(t, "A comment", comments[0].Text)
+		assert.Equal(t, "Commented", comments[0].Anchor)
+
+		revisions := doc.Revisions()
+		require.Len(t, revisions, 2)
+		assert.Equal(t, word_extractor.Deletion, revisions[0].Type)
+		assert.Equal(t, "gone ", revisions[0].Text)
+		assert.Equal(t, "John Smith", revisions[0].Author)
+		assert.Equal(t, time.Date(2003, 9, 13, 0, 0, 0, 0, time.UTC), revisions[0].Date)
+		assert.Equal(t, word_extractor.Insertion, revisions[1].Type)
+
+		links := doc.Hyperlinks()
+		require.Len(t, links, 1)
+		assert.Equal(t, "http://example.com/", links[0].URL)
+		assert.Equal(t, "the site", links[0].Text)
+
+		tables := doc.Tables()
+		require.Len(t, tables, 1)
+		require.Len(t, tables[0].Rows, 2)
+		assert.Equal(t, word_extractor.MergeRestart, tables[0].Rows[0][0].VMerge)
+		assert.Equal(t, word_extractor.MergeContinue, tables[0].Rows[1][0].VMerge)
+
+		images := doc.Images()
+		require.Len(t, images, 1)
+		assert.Equal(t, "02000001.png", images[0].Name)
+		assert.Equal(t, "image/png", images[0].ContentType)
+		assert.Equal(t, "A picture", images[0].AltText)
+		assert.Equal(t, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), images[0].Data)
+
+		assert.Equal(t, "Sample title", doc.Metadata.Title)
+		assert.Equal(t, "Jane Doe", doc.Metadata.Author)
+		assert.Equal(t, "John Smith", doc.Metadata.LastModifiedBy)
+		assert.Equal(t, "Acme", doc.Metadata.Company)
+		assert.Equal(t, 1, doc.Metadata.Pages)
+		assert.Equal(t, time.Date(2003, 9, 12, 10, 1, 0, 0, time.UTC), doc.Metadata.Created)
+		assert.Equal(t, "Contoso", doc.Metadata.Custom["Client Name"])
+	})
+
+	t.Run("should apply the extraction options to Word 2003 XML documents", func(t *testing.T) {
+		extractor := word_extractor.NewWordExtractor()
+		extractor.Options.Revisions = word_extractor.ShowRevisions
+		extractor.Options.NoteMarkers = true
+		doc, err := extractor.Extract([]byte(wordML2003Document))
+		require.NoError(t, err)
+
+		assert.Contains(t, doc.Body, "Footnote[^1] here")
+		assert.Contains(t, doc.Body, "Keep [-gone -]{+new +}end")
+	})
+
+	t.Run("should not read other XML files", func(t *testing.T) {
+		_, err := extractor.Extract([]byte(`<?xml version="1.0"?><note>Hello</note>`))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,397 @@
+package tests
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// buildCompoundFile builds a compound file with streams at its root. Streams
+// are padded to the mini stream cutoff, so that they are all kept in sectors.
+// Names ending in a slash are empty storages.
+func buildCompoundFile(t *testing.T, streams map[string][]byte) []byte {
+	t.Helper()
+	const (
+		endOfChain = 0xFFFFFFFE
+		fatSector  = 0xFFFFFFFD
+		freeSector = 0xFFFFFFFF
+	)
+	names := make([]string, 0, len(streams))
+	for name := range streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sectors []byte
+	var fat []uint32
+	chain := func(count int) uint32 {
+		start := uint32(len(fat))
+		for i := 0; i < count; i++ {
+			if i == count-1 {
+				fat = append(fat, endOfChain)
+			} else {
+				fat = append(fat, uint32(len(fat)+1))
+			}
+		}
+		return start
+	}
+
+	starts := make([]uint32, len(names))
+	sizes := make([]int, len(names))
+	for i, name := range names {
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		data := append([]byte(nil), streams[name]...)
+		if len(data) < 4096 {
+			data = append(data, make([]byte, 4096-len(data))...)
+		}
+		if len(data)%512 != 0 {
+			data = append(data, make([]byte, 512-len(data)%512)...)
+		}
+		starts[i], sizes[i] = chain(len(data)/512), len(data)
+		sectors = append(sectors, data...)
+	}
+
+	// The root entry lists the streams as a chain of right siblings
+	dir := make([]byte, ((len(names)+1+3)/4)*512)
+	entry := func(id int, name string, typ byte, child, right uint32, start uint32, size int) {
+		e := dir[id*128:]
+		units := utf16.Encode([]rune(name))
+		for i, u := range units {
+			binary.LittleEndian.PutUint16(e[i*2:], u)
+		}
+		binary.LittleEndian.PutUint16(e[64:], uint16((len(units)+1)*2))
+		e[66], e[67] = typ, 1
+		binary.LittleEndian.PutUint32(e[68:], freeSector)
+		binary.LittleEndian.PutUint32(e[72:], right)
+		binary.LittleEndian.PutUint32(e[76:], child)
+		binary.LittleEndian.PutUint32(e[116:], start)
+		binary.LittleEndian.PutUint32(e[120:], uint32(size))
+	}
+	entry(0, "Root Entry", 5, 1, freeSector, endOfChain, 0)
+	for i, name := range names {
+		right := uint32(freeSector)
+		if i+1 < len(names) {
+			right = uint32(i + 2)
+		}
+		if strings.HasSuffix(name, "/") {
+			entry(i+1, strings.TrimSuffix(name, "/"), 1, freeSector, right, 0, 0)
+			continue
+		}
+		entry(i+1, name, 2, freeSector, right, starts[i], sizes[i])
+	}
+	for id := len(names) + 1; id < len(dir)/128; id++ {
+		for _, offset := range []int{68, 72, 76} {
+			binary.LittleEndian.PutUint32(dir[id*128+offset:], freeSector)
+		}
+	}
+	dirStart := chain(len(dir) / 512)
+	sectors = append(sectors, dir...)
+
+	fatCount := 0
+	for len(fat)+fatCount > fatCount*128 {
+		fatCount++
+	}
+	fatStart := uint32(len(fat))
+	for i := 0; i < fatCount; i++ {
+		fat = append(fat, fatSector)
+	}
+	for len(fat) < fatCount*128 {
+		fat = append(fat, freeSector)
+	}
+	for _, next := range fat {
+		sectors = binary.LittleEndian.AppendUint32(sectors, next)
+	}
+
+	header := make([]byte, 512)
+	copy(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	binary.LittleEndian.PutUint16(header[24:], 0x003E)
+	binary.LittleEndian.PutUint16(header[26:], 3)
+	binary.LittleEndian.PutUint16(header[28:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[30:], 9)
+	binary.LittleEndian.PutUint16(header[32:], 6)
+	binary.LittleEndian.PutUint32(header[44:], uint32(fatCount))
+	binary.LittleEndian.PutUint32(header[48:], dirStart)
+	binary.LittleEndian.PutUint32(header[56:], 4096)
+	binary.LittleEndian.PutUint32(header[60:], endOfChain)
+	binary.LittleEndian.PutUint32(header[68:], endOfChain)
+	for i := 0; i < 109; i++ {
+		next := uint32(freeSector)
+		if i < fatCount {
+			next = fatStart + uint32(i)
+		}
+		binary.LittleEndian.PutUint32(header[76+i*4:], next)
+	}
+	return append(header, sectors...)
+}
+
+// word6Document builds the WordDocument stream of a Word 6 file. The FIB
+// takes the first 0x300 bytes, followed by the text, the tables and the FKP
+// pages.
+type word6Document struct {
+	data  []byte
+	table int
+}
+
+func newWord6Document(lid uint16) *word6Document {
+	d := &word6Document{data: make([]byte, 0x1000), table: 0x600}
+	binary.LittleEndian.PutUint16(d.data[0x00:], 0xA5DC)
+	binary.LittleEndian.PutUint16(d.data[0x02:], 0x0065)
+	binary.LittleEndian.PutUint16(d.data[0x06:], lid)
+	return d
+}
+
+func (d *word6Document) put32(offset int, value int) {
+	binary.LittleEndian.PutUint32(d.data[offset:], uint32(value))
+}
+
+// addTable writes a table after the others, and points the fc and lcb pair
+// at the given FIB offset to it
+func (d *word6Document) addTable(fibOffset int, table []byte) int {
+	fc := d.table
+	copy(d.data[fc:], table)
+	d.table += len(table) + len(table)%2
+	if fibOffset > 0 {
+		d.put32(fibOffset, fc)
+		d.put32(fibOffset+4, len(table))
+	}
+	return fc
+}
+
+// plc builds a PLC from its character positions and data
+func plc(cps []int, data ...[]byte) []byte {
+	var out []byte
+	for _, cp := range cps {
+		out = binary.LittleEndian.AppendUint32(out, uint32(cp))
+	}
+	for _, d := range data {
+		out = append(out, d...)
+	}
+	return out
+}
+
+func pascal(s string) []byte {
+	encoded, _ := charmap.Windows1251.NewEncoder().Bytes([]byte(s))
+	return append([]byte{byte(len(encoded))}, encoded...)
+}
+
+func TestWord6(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	t.Run("should read Word 6 documents in their code page", func(t *testing.T) {
+		d := newWord6Document(0x0419)
+		const (
+			heading  = "Заголовок\r"
+			para     = "Привет, мир\x02 конец\x05\r"
+			table    = "A1\x07B1\x07\x07"
+			last     = "Конец\r"
+			footnote = "\x02 Сноска\r"
+			header   = "Верх\r\r"
+			comment  = "\x05Заметка\r"
+		)
+		body := heading + para + table + last
+		text, err := charmap.Windows1251.NewEncoder().String(body + footnote + header + comment)
+		require.NoError(t, err)
+		copy(d.data[0x300:], text)
+		d.put32(0x18, 0x300)
+		d.put32(0x1C, 0x300+len(text))
+		ccpText := len([]rune(body))
+		d.put32(0x34, ccpText)
+		d.put32(0x38, len([]rune(footnote)))
+		d.put32(0x3C, len([]rune(header)))
+		d.put32(0x44, len([]rune(comment)))
+		fc := func(cp int) int { return 0x300 + cp }
+
+		// Styles: Normal, and heading 1 based on it
+		stsh := []byte{4, 0, 2, 0, 8, 0}
+		for i, name := range []string{"Normal", "heading 1"} {
+			std := make([]byte, 8)
+			binary.LittleEndian.PutUint16(std, uint16(i))
+			base := 0xFFF
+			if i > 0 {
+				base = 0
+			}
+			binary.LittleEndian.PutUint16(std[2:], uint16(base<<4|1))
+			std = append(append(std, pascal(name)...), 0)
+			stsh = append(binary.LittleEndian.AppendUint16(stsh, uint16(len(std))), std...)
+		}
+		d.addTable(0x60, stsh)
+
+		refCp := len([]rune(heading + "Привет, мир"))
+		d.addTable(0x68, plc([]int{refCp, ccpText}, []byte{1, 0}))
+		d.addTable(0x70, plc([]int{0, len([]rune(footnote))}))
+		atrd := make([]byte, 20)
+		copy(atrd, pascal("ИП"))
+		d.addTable(0x78, plc([]int{refCp + len([]rune(" конец")) + 1, ccpText}, atrd))
+		d.addTable(0x80, plc([]int{0, len([]rune(comment))}))
+		d.addTable(0x178, pascal("Иван Петров"))
+		d.addTable(0x1FA, append([]byte{0, 0}, pascal("Иван")...))
+
+		// One section, with an odd page header
+		sepx := d.addTable(0, []byte{2, 0, 153, 0x02})
+		sed := make([]byte, 12)
+		binary.LittleEndian.PutUint32(sed[2:], uint32(sepx))
+		d.addTable(0x88, plc([]int{0, ccpText}, sed))
+		d.addTable(0x150, []byte{0, 0})
+		d.addTable(0xB0, plc([]int{0, 5, 6}))
+
+		// Paragraph properties: the heading style, table cells and the
+		// row with its table definition
+		papx := 0xA00
+		cells := []int{len([]rune(heading)), len([]rune(heading + para))}
+		cells = append(cells, cells[1]+3, cells[1]+6, cells[1]+7)
+		runs := []int{fc(0)}
+		for _, cp := range cells {
+			runs = append(runs, fc(cp))
+		}
+		runs = append(runs, 0x300+len(text))
+		fkp := d.data[papx : papx+512]
+		for i, r := range runs {
+			binary.LittleEndian.PutUint32(fkp[i*4:], uint32(r))
+		}
+		crun := len(runs) - 1
+		fkp[511] = byte(crun)
+		tdef := []byte{28, 0, 2, 0, 0, 0xA0, 0x05, 0x40, 0x0B}
+		tdef = append(tdef, make([]byte, 20)...)
+		props := [][]byte{
+			{1, 1, 0},
+			nil,
+			{2, 0, 0, 24, 1},
+			{2, 0, 0, 24, 1},
+			append([]byte{18, 0, 0, 24, 1, 25, 1, 190}, tdef...),
+			nil,
+		}
+		at := 0x100
+		for i, p := range props {
+			if p == nil {
+				continue
+			}
+			copy(fkp[at:], p)
+			fkp[(crun+1)*4+i*7] = byte(at / 2)
+			at += len(p) + len(p)%2
+		}
+		d.addTable(0xC0, plc([]int{fc(0), 0x300 + len(text)}, []byte{5, 0}))
+		binary.LittleEndian.PutUint16(d.data[0x190:], 1)
+
+		// Character properties: "мир" was inserted by the first author
+		chpx := d.data[0xC00 : 0xC00+512]
+		start := fc(len([]rune(heading + "Привет, ")))
+		for i, r := range []int{start, start + 3} {
+			binary.LittleEndian.PutUint32(chpx[i*4:], uint32(r))
+		}
+		chpx[511] = 1
+		chpx[8] = 0x80
+		copy(chpx[0x100:], []byte{5, 66, 1, 69, 0, 0})
+		d.addTable(0xB8, plc([]int{start, start + 3}, []byte{6, 0}))
+		binary.LittleEndian.PutUint16(d.data[0x18E:], 1)
+
+		doc, err := extractor.Extract(buildCompoundFile(t, map[string][]byte{"WordDocument": d.data}))
+		require.NoError(t, err)
+
+		assert.Equal(t, "Заголовок\nПривет, мир конец\nA1\tB1\t\nКонец\n", doc.Body)
+		assert.Equal(t, "Верх\n", doc.Headers)
+		paragraphs := doc.Paragraphs()
+		require.Len(t, paragraphs, 5)
+		assert.Equal(t, "heading 1", paragraphs[0].Style)
+		assert.Equal(t, 1, paragraphs[0].Level)
+		assert.Equal(t, "Normal", paragraphs[1].Style)
+		tables := doc.Tables()
+		require.Len(t, tables, 1)
+		assert.Equal(t, []string{"A1", "B1"}, cellTexts(tables[0].Rows[0]))
+
+		footnotes := doc.FootnoteList()
+		require.Len(t, footnotes, 1)
+		assert.Equal(t, "Сноска", footnotes[0].Text)
+		assert.Equal(t, len([]rune("Заголовок\nПривет, мир")), footnotes[0].Offset)
+
+		comments := doc.Comments()
+		require.Len(t, comments, 1)
+		assert.Equal(t, "Иван Петров", comments[0].Author)
+		assert.Equal(t, "ИП", comments[0].Initials)
+		assert.Equal(t, "Заметка", comments[0].Text)
+
+		revisions := doc.Revisions()
+		require.Len(t, revisions, 1)
+		assert.Equal(t, word_extractor.Insertion, revisions[0].Type)
+		assert.Equal(t, "мир", revisions[0].Text)
+		assert.Equal(t, "Иван", revisions[0].Author)
+	})
+
+	t.Run("should count double byte characters as two positions", func(t *testing.T) {
+		d := newWord6Document(0x0411)
+		const (
+			body     = "日本語\x02の文\rABC\r"
+			footnote = "\x02 注記\r"
+		)
+		text, err := japanese.ShiftJIS.NewEncoder().String(body + footnote)
+		require.NoError(t, err)
+		bodyBytes, err := japanese.ShiftJIS.NewEncoder().String(body)
+		require.NoError(t, err)
+		copy(d.data[0x300:], text)
+		d.put32(0x18, 0x300)
+		d.put32(0x1C, 0x300+len(text))
+		d.put32(0x34, len(bodyBytes))
+		d.put32(0x38, len(text)-len(bodyBytes))
+
+		// Positions count bytes: the reference follows three characters of
+		// two bytes
+		d.addTable(0x68, plc([]int{6, len(bodyBytes)}, []byte{1, 0}))
+		d.addTable(0x70, plc([]int{0, len(text) - len(bodyBytes)}))
+		d.addTable(0x178, append([]byte{0, 0}, pascal("Author")...))
+
+		// Character properties: "の文" was inserted
+		chpx := d.data[0xC00 : 0xC00+512]
+		for i, r := range []int{0x307, 0x30B} {
+			binary.LittleEndian.PutUint32(chpx[i*4:], uint32(r))
+		}
+		chpx[511] = 1
+		chpx[8] = 0x80
+		copy(chpx[0x100:], []byte{2, 66, 1})
+		d.addTable(0xB8, plc([]int{0x307, 0x30B}, []byte{6, 0}))
+		binary.LittleEndian.PutUint16(d.data[0x18E:], 1)
+
+		doc, err := extractor.Extract(buildCompoundFile(t, map[string][]byte{"WordDocument": d.data}))
+		require.NoError(t, err)
+		assert.Equal(t, "日本語の文\nABC\n", doc.Body)
+		assert.Equal(t, "注記", strings.Trim(doc.Footnotes, " \x02\n"))
+		footnotes := doc.FootnoteList()
+		require.Len(t, footnotes, 1)
+		assert.Equal(t, "注記", footnotes[0].Text)
+		assert.Equal(t, len([]rune("日本語")), footnotes[0].Offset)
+
+		revisions := doc.Revisions()
+		require.Len(t, revisions, 1)
+		assert.Equal(t, word_extractor.Insertion, revisions[0].Type)
+		assert.Equal(t, "の文", revisions[0].Text)
+	})
+
+	t.Run("should read the piece table of fast saved Word 6 documents", func(t *testing.T) {
+		d := newWord6Document(0x0409)
+		copy(d.data[0x300:], "world\rHello \x00")
+		binary.LittleEndian.PutUint16(d.data[0x0A:], 0x0004)
+		d.put32(0x18, 0x300)
+		d.put32(0x1C, 0x30C)
+		d.put32(0x34, 12)
+		pcd := func(fc int) []byte {
+			b := make([]byte, 8)
+			binary.LittleEndian.PutUint32(b[2:], uint32(fc))
+			return b
+		}
+		pieces := plc([]int{0, 6, 12}, pcd(0x306), pcd(0x300))
+		clx := append([]byte{2}, binary.LittleEndian.AppendUint32(nil, uint32(len(pieces)))...)
+		d.addTable(0x160, append(clx, pieces...))
+
+		doc, err := extractor.Extract(buildCompoundFile(t, map[string][]byte{"WordDocument": d.data}))
+		require.NoError(t, err)
+		assert.Equal(t, "Hello world\n", doc.Body)
+	})
+}
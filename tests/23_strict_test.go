@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrict(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for _, file := range []string{"test04", "test17"} {
+		t.Run("should read "+file+" in Strict conformance", func(t *testing.T) {
+			transitional, err := extractor.Extract(filepath.Join("data", file+".docx"))
+			require.NoError(t, err)
+			strict, err := extractor.Extract(filepath.Join("data", file+"-strict.docx"))
+			require.NoError(t, err)
+
+			require.NotEmpty(t, strict.Body)
+			assert.Equal(t, transitional.Body, strict.Body)
+			assert.Equal(t, transitional.Headers, strict.Headers)
+			assert.Equal(t, transitional.Footers, strict.Footers)
+			assert.Equal(t, transitional.Footnotes, strict.Footnotes)
+			assert.Equal(t, transitional.Endnotes, strict.Endnotes)
+			assert.Equal(t, transitional.Paragraphs(), strict.Paragraphs())
+			assert.Equal(t, transitional.Images(), strict.Images())
+			assert.Equal(t, transitional.EmbeddedObjects(), strict.EmbeddedObjects())
+			assert.Equal(t, transitional.Metadata, strict.Metadata)
+		})
+	}
+
+	t.Run("should read Strict body content", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{
+			"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://purl.oclc.org/ooxml/wordprocessingml/main" w:conformance="strict"><w:body>` +
+				`<w:p><w:r><w:t>Strict</w:t></w:r><w:r><w:tab/><w:t>text</w:t></w:r></w:p></w:body></w:document>`,
+		})
+		doc, err := extractor.Extract(data)
+		require.NoError(t, err)
+		assert.Equal(t, "Strict\ttext\n", doc.Body)
+	})
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// packageContentTypes gives a content types part with the main document part
+// of the given content type, and a VBA project part
+func packageContentTypes(mainType string) string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Default Extension="bin" ContentType="application/vnd.ms-office.vbaProject"/>
+<Override PartName="/word/document.xml" ContentType="` + mainType + `"/>
+</Types>`
+}
+
+func TestFormats(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	for file, format := range map[string]word_extractor.Format{
+		"test01.doc":         word_extractor.FormatDoc,
+		"test01.docx":        word_extractor.FormatDocx,
+		"test04-strict.docx": word_extractor.FormatDocx,
+	} {
+		t.Run("should report the format of "+file, func(t *testing.T) {
+			doc, err := extractor.Extract(filepath.Join("data", file))
+			require.NoError(t, err)
+			assert.Equal(t, format, doc.Format)
+			assert.False(t, doc.HasMacros)
+		})
+	}
+
+	for _, test := range []struct {
+		contentType string
+		format      word_extractor.Format
+		macros      bool
+	}{
+		{"application/vnd.ms-word.document.macroEnabled.main+xml", word_extractor.FormatDocm, true},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.template.main+xml", word_extractor.FormatDotx, false},
+		{"application/vnd.ms-word.template.macroEnabledTemplate.main+xml", word_extractor.FormatDotm, true},
+	} {
+		t.Run("should read "+string(test.format)+" packages", func(t *testing.T) {
+			parts := map[string]string{
+				"[Content_Types].xml": packageContentTypes(test.contentType),
+				"word/document.xml":   wordBody(`<w:p><w:r><w:t>Hello</w:t></w:r></w:p>`),
+			}
+			if test.macros {
+				parts["word/vbaProject.bin"] = "\xD0\xCF\x11\xE0"
+			}
+			doc, err := extractor.Extract(buildDocx(t, parts))
+			require.NoError(t, err)
+			assert.Equal(t, "Hello\n", doc.Body)
+			assert.Equal(t, test.format, doc.Format)
+			assert.Equal(t, test.macros, doc.HasMacros)
+		})
+	}
+
+	t.Run("should report templates and macros of .doc files", func(t *testing.T) {
+		d := newWord6Document(0x0409)
+		copy(d.data[0x300:], "Template\r")
+		binary.LittleEndian.PutUint16(d.data[0x0A:], 0x0001)
+		d.put32(0x18, 0x300)
+		d.put32(0x1C, 0x309)
+		d.put32(0x34, 9)
+
+		doc, err := extractor.Extract(buildCompoundFile(t, map[string][]byte{"WordDocument": d.data, "Macros/": nil}))
+		require.NoError(t, err)
+		assert.Equal(t, "Template\n", doc.Body)
+		assert.Equal(t, word_extractor.FormatDot, doc.Format)
+		assert.True(t, doc.HasMacros)
+	})
+
+	t.Run("should report the format of other documents", func(t *testing.T) {
+		doc, err := extractor.Extract([]byte(`{\rtf1\ansi Hello\par}`))
+		require.NoError(t, err)
+		assert.Equal(t, word_extractor.FormatRtf, doc.Format)
+
+		doc, err = extractor.Extract([]byte(wordML2003Document))
+		require.NoError(t, err)
+		assert.Equal(t, word_extractor.FormatXML, doc.Format)
+
+		doc, err = extractor.Extract([]byte(flatOpcDocument))
+		require.NoError(t, err)
+		assert.Equal(t, word_extractor.FormatXML, doc.Format)
+
+		doc, err = extractor.Extract(buildOdt(t, map[string]string{"content.xml": odtContent("", `<text:p>Hello</text:p>`)}))
+		require.NoError(t, err)
+		assert.Equal(t, word_extractor.FormatOdt, doc.Format)
+	})
+}
@@ -0,0 +1,388 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSalt = []byte("0123456789abcdef")
+
+func utf16Password(password string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(password)) {
+		out = binary.LittleEndian.AppendUint16(out, u)
+	}
+	return out
+}
+
+// encryptDoc encrypts a .doc file with RC4, or RC4 with CryptoAPI. The
+// encryption header is put at the start of the table stream, so the tables
+// of the FIB are moved after it.
+func encryptDoc(t *testing.T, file, password string, cryptoAPI bool) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("data", file))
+	require.NoError(t, err)
+	cfb, err := mscfb.New(bytes.NewReader(data))
+	require.NoError(t, err)
+	streams := map[string][]byte{}
+	for entry, err := cfb.Next(); err == nil; entry, err = cfb.Next() {
+		if len(entry.Path) == 0 && !entry.FileInfo().IsDir() {
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(cfb)
+			require.NoError(t, err)
+			streams[entry.Name] = buf.Bytes()
+		}
+	}
+
+	var blockKey func(block uint32) []byte
+	var header, verifierHash []byte
+	verifier := []byte("fedcba9876543210")
+	if cryptoAPI {
+		h0 := sha1.Sum(append(append([]byte(nil), testSalt...), utf16Password(password)...))
+		blockKey = func(block uint32) []byte {
+			h := sha1.Sum(binary.LittleEndian.AppendUint32(h0[:], block))
+			return h[:16]
+		}
+		sum := sha1.Sum(verifier)
+		verifierHash = sum[:]
+		info := make([]byte, 32)
+		binary.LittleEndian.PutUint32(info[0:], 0x04)
+		binary.LittleEndian.PutUint32(info[8:], 0x6801)
+		binary.LittleEndian.PutUint32(info[12:], 0x8004)
+		binary.LittleEndian.PutUint32(info[16:], 128)
+		binary.LittleEndian.PutUint32(info[20:], 1)
+		info = append(info, 0, 0)
+		header = []byte{4, 0, 2, 0, 4, 0, 0, 0}
+		header = binary.LittleEndian.AppendUint32(header, uint32(len(info)))
+		header = append(header, info...)
+		header = binary.LittleEndian.AppendUint32(header, 16)
+	} else {
+		h0 := md5.Sum(utf16Password(password))
+		var buf []byte
+		for i := 0; i < 16; i++ {
+			buf = append(append(buf, h0[:5]...), testSalt...)
+		}
+		h1 := md5.Sum(buf)
+		blockKey = func(block uint32) []byte {
+			h := md5.Sum(binary.LittleEndian.AppendUint32(append([]byte(nil), h1[:5]...), block))
+			return h[:]
+		}
+		sum := md5.Sum(verifier)
+		verifierHash = sum[:]
+		header = []byte{1, 0, 1, 0}
+	}
+	c, err := rc4.NewCipher(blockKey(0))
+	require.NoError(t, err)
+	encrypted := make([]byte, len(verifier)+len(verifierHash))
+	c.XORKeyStream(encrypted, append(append([]byte(nil), verifier...), verifierHash...))
+	header = append(append(header, testSalt...), encrypted[:16]...)
+	if cryptoAPI {
+		header = binary.LittleEndian.AppendUint32(header, 20)
+	}
+	header = append(header, encrypted[16:]...)
+
+	encrypt := func(data []byte, clear int) []byte {
+		out := make([]byte, len(data))
+		for start := 0; start < len(data); start += 512 {
+			end := min(start+512, len(data))
+			c, _ := rc4.NewCipher(blockKey(uint32(start / 512)))
+			c.XORKeyStream(out[start:end], data[start:end])
+		}
+		copy(out, data[:clear])
+		return out
+	}
+
+	doc := append([]byte(nil), streams["WordDocument"]...)
+	flags := binary.LittleEndian.Uint16(doc[0x0A:])
+	tableName := "0Table"
+	if flags&0x0200 != 0 {
+		tableName = "1Table"
+	}
+	binary.LittleEndian.PutUint16(doc[0x0A:], flags|0x0100)
+	binary.LittleEndian.PutUint32(doc[0x0E:], uint32(len(header)))
+	csw := int(binary.LittleEndian.Uint16(doc[0x20:]))
+	cslw := int(binary.LittleEndian.Uint16(doc[0x22+csw*2:]))
+	pos := 0x22 + csw*2 + 2 + cslw*4
+	count := int(binary.LittleEndian.Uint16(doc[pos:]))
+	for i := 0; i < count; i++ {
+		pair := doc[pos+2+i*8:]
+		if binary.LittleEndian.Uint32(pair[4:]) > 0 {
+			binary.LittleEndian.PutUint32(pair, binary.LittleEndian.Uint32(pair)+uint32(len(header)))
+		}
+	}
+
+	streams["WordDocument"] = encrypt(doc, 0x44)
+	streams[tableName] = encrypt(append(header, streams[tableName]...), len(header))
+	if data, ok := streams["Data"]; ok {
+		streams["Data"] = encrypt(data, 0)
+	}
+	return buildCompoundFile(t, streams)
+}
+
+// encryptPackage encrypts a .docx file with agile encryption, or with
+// standard encryption
+func encryptPackage(t *testing.T, file, password string, agile bool) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("data", file))
+	require.NoError(t, err)
+	pkg := binary.LittleEndian.AppendUint64(nil, uint64(len(data)))
+	if len(data)%16 != 0 {
+		data = append(data, make([]byte, 16-len(data)%16)...)
+	}
+
+	if !agile {
+		h := sha1.Sum(append(append([]byte(nil), testSalt...), utf16Password(password)...))
+		for i := 0; i < 50000; i++ {
+			h = sha1.Sum(append(binary.LittleEndian.AppendUint32(nil, uint32(i)), h[:]...))
+		}
+		final := sha1.Sum(binary.LittleEndian.AppendUint32(h[:], 0))
+		buf := bytes.Repeat([]byte{0x36}, 64)
+		for i, b := range final {
+			buf[i] ^= b
+		}
+		x1 := sha1.Sum(buf)
+		block, err := aes.NewCipher(x1[:16])
+		require.NoError(t, err)
+		ecb := func(data []byte) []byte {
+			out := make([]byte, len(data))
+			for i := 0; i < len(data); i += 16 {
+				block.Encrypt(out[i:], data[i:])
+			}
+			return out
+		}
+
+		header := make([]byte, 32)
+		binary.LittleEndian.PutUint32(header[0:], 0x24)
+		binary.LittleEndian.PutUint32(header[8:], 0x660E)
+		binary.LittleEndian.PutUint32(header[12:], 0x8004)
+		binary.LittleEndian.PutUint32(header[16:], 128)
+		binary.LittleEndian.PutUint32(header[20:], 0x18)
+		header = append(header, 0, 0)
+		info := []byte{4, 0, 2, 0, 0x24, 0, 0, 0}
+		info = binary.LittleEndian.AppendUint32(info, uint32(len(header)))
+		info = append(info, header...)
+		verifier := []byte("fedcba9876543210")
+		sum := sha1.Sum(verifier)
+		info = binary.LittleEndian.AppendUint32(info, 16)
+		info = append(append(info, testSalt...), ecb(verifier)...)
+		info = binary.LittleEndian.AppendUint32(info, 20)
+		info = append(info, ecb(append(sum[:], make([]byte, 12)...))...)
+		return buildCompoundFile(t, map[string][]byte{"EncryptionInfo": info, "EncryptedPackage": append(pkg, ecb(data)...)})
+	}
+
+	hashOf := func(data ...[]byte) []byte {
+		h := sha512.New()
+		for _, d := range data {
+			h.Write(d)
+		}
+		return h.Sum(nil)
+	}
+	cbc := func(key, iv, data []byte) []byte {
+		block, err := aes.NewCipher(key)
+		require.NoError(t, err)
+		out := make([]byte, len(data))
+		cipher.NewCBCEncrypter(block, iv[:16]).CryptBlocks(out, data)
+		return out
+	}
+	const spinCount = 1000
+	sum := hashOf(testSalt, utf16Password(password))
+	for i := 0; i < spinCount; i++ {
+		sum = hashOf(binary.LittleEndian.AppendUint32(nil, uint32(i)), sum)
+	}
+	keyFor := func(block ...byte) []byte { return hashOf(sum, block)[:32] }
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keySalt := []byte("abcdef0123456789")
+	input := []byte("fedcba9876543210")
+	encode := base64.StdEncoding.EncodeToString
+	descriptor := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<encryption xmlns="http://schemas.microsoft.com/office/2006/encryption" xmlns:p="http://schemas.microsoft.com/office/2006/keyEncryptor/password">` +
+		`<keyData saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="` + encode(keySalt) + `"/>` +
+		`<keyEncryptors><keyEncryptor uri="http://schemas.microsoft.com/office/2006/keyEncryptor/password">` +
+		`<p:encryptedKey spinCount="1000" saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="` + encode(testSalt) +
+		`" encryptedVerifierHashInput="` + encode(cbc(keyFor(0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79), testSalt, input)) +
+		`" encryptedVerifierHashValue="` + encode(cbc(keyFor(0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e), testSalt, hashOf(input))) +
+		`" encryptedKeyValue="` + encode(cbc(keyFor(0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6), testSalt, secret)) +
+		`"/></keyEncryptor></keyEncryptors></encryption>`
+	info := append([]byte{4, 0, 4, 0, 0x40, 0, 0, 0}, descriptor...)
+
+	for segment := 0; segment*4096 < len(data); segment++ {
+		start := segment * 4096
+		end := min(start+4096, len(data))
+		iv := hashOf(keySalt, binary.LittleEndian.AppendUint32(nil, uint32(segment)))
+		pkg = append(pkg, cbc(secret, iv, data[start:end])...)
+	}
+	return buildCompoundFile(t, map[string][]byte{"EncryptionInfo": info, "EncryptedPackage": pkg})
+}
+
+func TestEncryption(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		file      string
+		encrypted func(t *testing.T) []byte
+	}{
+		{"RC4 encrypted .doc files", "test01.doc", func(t *testing.T) []byte { return encryptDoc(t, "test01.doc", "secret", false) }},
+		{"RC4 CryptoAPI encrypted .doc files", "test01.doc", func(t *testing.T) []byte { return encryptDoc(t, "test01.doc", "secret", true) }},
+		{"agile encrypted .docx files", "test01.docx", func(t *testing.T) []byte { return encryptPackage(t, "test01.docx", "secret", true) }},
+		{"standard encrypted .docx files", "test01.docx", func(t *testing.T) []byte { return encryptPackage(t, "test01.docx", "secret", false) }},
+	} {
+		t.Run("should decrypt "+test.name, func(t *testing.T) {
+			data := test.encrypted(t)
+			extractor := word_extractor.NewWordExtractor()
+
+			_, err := extractor.Extract(data)
+			assert.ErrorIs(t, err, word_extractor.ErrEncrypted)
+
+			extractor.Options.Password = "wrong"
+			_, err = extractor.Extract(data)
+			assert.ErrorIs(t, err, word_extractor.ErrWrongPassword)
+
+			expected, err := extractor.Extract(filepath.Join("data", test.file))
+			require.NoError(t, err)
+			extractor.Options.Password = "secret"
+			doc, err := extractor.Extract(data)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Body, doc.Body)
+			assert.Equal(t, expected.Revisions(), doc.Revisions())
+		})
+	}
+	// Packages saved by Office applications, rather than encrypted by the
+	// tests, as those could share a mistake with the decryption
+	for _, test := range []struct {
+		name        string
+		file        string
+		application string
+		created     time.Time
+	}{
+		{"agile encrypted packages saved by Excel", "encryptSHA1.xlsx", "Microsoft Excel", time.Date(2020, 8, 31, 8, 45, 49, 0, time.UTC)},
+		{"standard encrypted packages saved by LibreOffice", "encryptAES.xlsx", "LibreOffice/7.0.0.3$MacOSX_X86_64 LibreOffice_project/8061b3e9204bef6b321a21033174034a5e2ea88e", time.Date(2020, 9, 1, 13, 57, 5, 0, time.UTC)},
+	} {
+		t.Run("should decrypt "+test.name, func(t *testing.T) {
+			path := filepath.Join("testdata", test.file)
+			extractor := word_extractor.NewWordExtractor()
+
+			_, err := extractor.Extract(path)
+			assert.ErrorIs(t, err, word_extractor.ErrEncrypted)
+
+			extractor.Options.Password = "wrong"
+			_, err = extractor.Extract(path)
+			assert.ErrorIs(t, err, word_extractor.ErrWrongPassword)
+
+			extractor.Options.Password = "password"
+			doc, err := extractor.Extract(path)
+			require.NoError(t, err)
+			assert.Equal(t, test.application, doc.Metadata.Application)
+			assert.Equal(t, test.created, doc.Metadata.Created)
+		})
+	}
+	t.Run("should not derive keys of too large a spin count", func(t *testing.T) {
+		// The attributes keep their length so that the stream does too
+		data := bytes.Replace(encryptPackage(t, "test01.docx", "secret", true),
+			[]byte(`spinCount="1000" saltSize="16"`), []byte(`spinCount="20000000"          `), 1)
+		extractor := word_extractor.NewWordExtractor()
+		extractor.Options.Password = "secret"
+		_, err := extractor.Extract(data)
+		assert.ErrorIs(t, err, word_extractor.ErrEncrypted)
+	})
+
+	t.Run("should read encrypted packages within the limits", func(t *testing.T) {
+		data := encryptPackage(t, "test01.docx", "secret", true)
+		extractor := word_extractor.NewWordExtractor()
+		extractor.Options.Password = "secret"
+		extractor.Options.Limits.MaxPartSize = 5000
+		_, err := extractor.Extract(data)
+		var limitErr *word_extractor.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, "MaxPartSize", limitErr.Limit)
+		var extractErr *word_extractor.ExtractError
+		require.ErrorAs(t, err, &extractErr)
+		assert.Equal(t, "EncryptedPackage", extractErr.Part)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		extractor.Options.Limits.MaxPartSize = 0
+		_, err = extractor.ExtractContext(ctx, data)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("should report encryption info that cannot be read", func(t *testing.T) {
+		// Each change keeps the length of the encryption info
+		for name, change := range map[string]func(data []byte) []byte{
+			"XML": func(data []byte) []byte {
+				return bytes.Replace(data, []byte(`<encryption `), []byte(`<encryption<`), 1)
+			},
+			"key size": func(data []byte) []byte {
+				return bytes.Replace(data, []byte(`<keyData saltSize="16" blockSize="16" keyBits="256"`), []byte(`<keyData saltSize="16" blockSize="16" keyBits="248"`), 1)
+			},
+			"base64": func(data []byte) []byte {
+				data[bytes.Index(data, []byte(`encryptedKeyValue="`))+len(`encryptedKeyValue="`)] = '!'
+				return data
+			},
+		} {
+			data := encryptPackage(t, "test01.docx", "secret", true)
+			changed := change(append([]byte(nil), data...))
+			require.NotEqual(t, data, changed, name)
+			extractor := word_extractor.NewWordExtractor()
+			extractor.Options.Password = "secret"
+			_, err := extractor.Extract(changed)
+			assert.ErrorIs(t, err, word_extractor.ErrCorrupt, name)
+			var extractErr *word_extractor.ExtractError
+			require.ErrorAs(t, err, &extractErr, name)
+			assert.Equal(t, "EncryptionInfo", extractErr.Part, name)
+		}
+	})
+	t.Run("should report key and block sizes that are not valid", func(t *testing.T) {
+		// setUint32 changes a field of the encryption header that starts
+		// with a version, which is kept in the compound file as it is
+		setUint32 := func(data, start []byte, offset int, value uint32) []byte {
+			i := bytes.Index(data, start)
+			require.GreaterOrEqual(t, i, 0)
+			binary.LittleEndian.PutUint32(data[i+offset:], value)
+			return data
+		}
+		for _, test := range []struct {
+			name string
+			part string
+			data func(t *testing.T) []byte
+		}{
+			{"agile block size", "EncryptionInfo", func(t *testing.T) []byte {
+				return bytes.Replace(encryptPackage(t, "test01.docx", "secret", true), []byte(`<keyData saltSize="16" blockSize="16"`), []byte(`<keyData saltSize="16" blockSize="-1"`), 1)
+			}},
+			{"agile key size", "EncryptionInfo", func(t *testing.T) []byte {
+				return bytes.Replace(encryptPackage(t, "test01.docx", "secret", true), []byte(`spinCount="1000" saltSize="16" blockSize="16" keyBits="256"`), []byte(`spinCount="1000" saltSize="16" blockSize="16" keyBits="-64"`), 1)
+			}},
+			{"standard key size", "EncryptionInfo", func(t *testing.T) []byte {
+				return setUint32(encryptPackage(t, "test01.docx", "secret", false), []byte{4, 0, 2, 0, 0x24, 0, 0, 0}, 12+16, 0xFFFFFFF8)
+			}},
+			{"RC4 CryptoAPI key size", "1Table", func(t *testing.T) []byte {
+				return setUint32(encryptDoc(t, "test01.doc", "secret", true), []byte{4, 0, 2, 0, 4, 0, 0, 0}, 12+16, 256)
+			}},
+		} {
+			extractor := word_extractor.NewWordExtractor()
+			extractor.Options.Password = "secret"
+			_, err := extractor.Extract(test.data(t))
+			assert.ErrorIs(t, err, word_extractor.ErrCorrupt, test.name)
+			assert.ErrorContains(t, err, "size", test.name)
+			var extractErr *word_extractor.ExtractError
+			require.ErrorAs(t, err, &extractErr, test.name)
+			assert.Equal(t, test.part, extractErr.Part, test.name)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	t.Run("should report unsupported formats", func(t *testing.T) {
+		for _, data := range [][]byte{nil, []byte("plain text, not a document"), []byte(`<?xml version="1.0"?><html/>`)} {
+			_, err := extractor.Extract(data)
+			assert.ErrorIs(t, err, word_extractor.ErrUnsupportedFormat)
+		}
+
+		// A compound file without a WordDocument stream, such as a workbook
+		_, err := extractor.Extract(buildCompoundFile(t, map[string][]byte{"Workbook": []byte("cells")}))
+		assert.ErrorIs(t, err, word_extractor.ErrUnsupportedFormat)
+		var extractErr *word_extractor.ExtractError
+		require.ErrorAs(t, err, &extractErr)
+		assert.Equal(t, "WordDocument", extractErr.Part)
+	})
+
+	t.Run("should report corrupt compound files", func(t *testing.T) {
+		_, err := extractor.Extract(filepath.Join("data", "badfile-01-bad-header.doc"))
+		assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+	})
+
+	t.Run("should report corrupt structures with their stream and offset", func(t *testing.T) {
+		d := newWord6Document(0x0409)
+		d.put32(0x18, 0x300)
+		d.put32(0x1C, 0x2000)
+		_, err := extractor.Extract(buildCompoundFile(t, map[string][]byte{"WordDocument": d.data}))
+		assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+		var extractErr *word_extractor.ExtractError
+		require.ErrorAs(t, err, &extractErr)
+		assert.Equal(t, "WordDocument", extractErr.Part)
+		assert.Equal(t, int64(0x1C), extractErr.Offset)
+		assert.Equal(t, "WordDocument at offset 28: corrupt document: text out of range", err.Error())
+	})
+
+	t.Run("should report XML errors with their part", func(t *testing.T) {
+		data := buildDocx(t, map[string]string{"word/document.xml": wordBody(`<w:p><w:r><w:t>Broken</w:r></w:p>`)})
+		_, err := extractor.Extract(data)
+		assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+		var extractErr *word_extractor.ExtractError
+		require.ErrorAs(t, err, &extractErr)
+		assert.Equal(t, "word/document.xml", extractErr.Part)
+		assert.Greater(t, extractErr.Offset, int64(0))
+		var syntaxErr *xml.SyntaxError
+		assert.ErrorAs(t, err, &syntaxErr)
+	})
+
+	t.Run("should report missing parts", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("word/document.xml")
+		require.NoError(t, err)
+		_, err = w.Write([]byte(wordBody("")))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		_, err = extractor.Extract(buf.Bytes())
+		assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+		var extractErr *word_extractor.ExtractError
+		require.ErrorAs(t, err, &extractErr)
+		assert.Equal(t, "[Content_Types].xml", extractErr.Part)
+	})
+
+	t.Run("should keep file errors", func(t *testing.T) {
+		_, err := extractor.Extract(filepath.Join("data", "missing.docx"))
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
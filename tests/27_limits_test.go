@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// extractWithLimits extracts a document within limits, and returns the
+// limit it went over, if any
+func extractWithLimits(t *testing.T, source interface{}, limits word_extractor.Limits) (*word_extractor.Document, *word_extractor.LimitError) {
+	t.Helper()
+	extractor := &word_extractor.WordExtractor{Options: word_extractor.ExtractOptions{Limits: limits}}
+	doc, err := extractor.Extract(source)
+	if err == nil {
+		return doc, nil
+	}
+	assert.ErrorIs(t, err, word_extractor.ErrTooLarge)
+	var limitErr *word_extractor.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	return nil, limitErr
+}
+
+func TestLimits(t *testing.T) {
+	doc := filepath.Join("data", "test01.doc")
+	docx := filepath.Join("data", "test01.docx")
+
+	t.Run("should extract documents within the limits", func(t *testing.T) {
+		limits := word_extractor.Limits{
+			MaxInputSize:        1 << 20,
+			MaxPartSize:         1 << 20,
+			MaxTotalSize:        1 << 22,
+			MaxCompressionRatio: 100,
+			MaxXMLDepth:         100,
+			MaxXMLTokens:        100000,
+			MaxNesting:          100,
+			MaxOutputChars:      100000,
+			Timeout:             time.Minute,
+		}
+		for _, file := range []string{doc, docx} {
+			expected, err := word_extractor.NewWordExtractor().Extract(file)
+			require.NoError(t, err)
+			actual, limitErr := extractWithLimits(t, file, limits)
+			require.Nil(t, limitErr)
+			assert.Equal(t, expected.Body, actual.Body)
+		}
+	})
+
+	t.Run("should limit the size of the input", func(t *testing.T) {
+		for _, file := range []string{doc, docx} {
+			_, err := extractWithLimits(t, file, word_extractor.Limits{MaxInputSize: 1000})
+			require.NotNil(t, err)
+			assert.Equal(t, "MaxInputSize", err.Limit)
+		}
+	})
+
+	t.Run("should limit the size of streams and parts", func(t *testing.T) {
+		_, err := extractWithLimits(t, doc, word_extractor.Limits{MaxPartSize: 1000})
+		require.NotNil(t, err)
+		assert.Equal(t, "MaxPartSize", err.Limit)
+
+		_, err = extractWithLimits(t, docx, word_extractor.Limits{MaxPartSize: 1000})
+		require.NotNil(t, err)
+		assert.Equal(t, "MaxPartSize", err.Limit)
+
+		_, err = extractWithLimits(t, doc, word_extractor.Limits{MaxTotalSize: 5000})
+		require.NotNil(t, err)
+		assert.Equal(t, "MaxTotalSize", err.Limit)
+	})
+
+	t.Run("should report the part that goes over a limit", func(t *testing.T) {
+		extractor := &word_extractor.WordExtractor{Options: word_extractor.ExtractOptions{Limits: word_extractor.Limits{MaxPartSize: 1000}}}
+		_, err := extractor.Extract(doc)
+		var extractErr *word_extractor.ExtractError
+		require.ErrorAs(t, err, &extractErr)
+		assert.Equal(t, "WordDocument", extractErr.Part)
+		assert.Equal(t, "WordDocument: document too large: over MaxPartSize", err.Error())
+	})
+
+	t.Run("should limit the compression ratio of parts", func(t *testing.T) {
+		body := strings.Repeat(`<w:p><w:r><w:t>All work and no play</w:t></w:r></w:p>`, 20000)
+		data := buildDocx(t, map[string]string{"word/document.xml": wordBody(body)})
+		_, err := extractWithLimits(t, data, word_extractor.Limits{MaxCompressionRatio: 50})
+		require.NotNil(t, err)
+		assert.Equal(t, "MaxCompressionRatio", err.Limit)
+	})
+
+	t.Run("should limit the depth and tokens of XML", func(t *testing.T) {
+		nested := strings.Repeat(`<w:sdt><w:sdtContent>`, 50) + `<w:p><w:r><w:t>Deep</w:t></w:r></w:p>` + strings.Repeat(`</w:sdtContent></w:sdt>`, 50)
+		data := buildDocx(t, map[string]string{"word/document.xml": wordBody(nested)})
+		_, err := extractWithLimits(t, data, word_extractor.Limits{MaxXMLDepth: 64})
+		require.NotNil(t, err)
+		assert.Equal(t, "MaxXMLDepth", err.Limit)
+
+		_, err = extractWithLimits(t, data, word_extractor.Limits{MaxXMLTokens: 100})
+		require.NotNil(t, err)
+		assert.Equal(t, "MaxXMLTokens", err.Limit)
+	})
+
+	t.Run("should limit how deep RTF groups are nested", func(t *testing.T) {
+		data := []byte(`{\rtf1\ansi ` + strings.Repeat("{", 200) + `Deep` + strings.Repeat("}", 200) + `}`)
+		doc, limitErr := extractWithLimits(t, data, word_extractor.Limits{MaxNesting: 201})
+		require.Nil(t, limitErr)
+		assert.Equal(t, "Deep", doc.Body)
+
+		_, limitErr = extractWithLimits(t, data, word_extractor.Limits{MaxNesting: 200})
+		require.NotNil(t, limitErr)
+		assert.Equal(t, "MaxNesting", limitErr.Limit)
+	})
+
+	t.Run("should limit the text extracted", func(t *testing.T) {
+		for _, file := range []string{doc, docx} {
+			_, err := extractWithLimits(t, file, word_extractor.Limits{MaxOutputChars: 10})
+			require.NotNil(t, err)
+			assert.Equal(t, "MaxOutputChars", err.Limit)
+		}
+	})
+
+	t.Run("should count the text as it is extracted", func(t *testing.T) {
+		files, err := filepath.Glob(filepath.Join("data", "*"))
+		require.NoError(t, err)
+		for _, file := range files {
+			expected, err := word_extractor.NewWordExtractor().Extract(file)
+			if err != nil {
+				continue
+			}
+			chars := 0
+			for _, text := range []string{expected.Body, expected.Footnotes, expected.Endnotes, expected.Headers, expected.Footers, expected.Annotations, expected.Textboxes, expected.HeaderTextboxes} {
+				chars += utf8.RuneCountInString(text)
+			}
+			if chars == 0 {
+				continue
+			}
+			_, limitErr := extractWithLimits(t, file, word_extractor.Limits{MaxOutputChars: chars})
+			assert.Nil(t, limitErr, file)
+			_, limitErr = extractWithLimits(t, file, word_extractor.Limits{MaxOutputChars: chars - 1})
+			if assert.NotNil(t, limitErr, file) {
+				assert.Equal(t, "MaxOutputChars", limitErr.Limit, file)
+			}
+		}
+	})
+
+	t.Run("should stop at the deadline", func(t *testing.T) {
+		for _, file := range []string{doc, docx} {
+			_, err := extractWithLimits(t, file, word_extractor.Limits{Timeout: time.Nanosecond})
+			require.NotNil(t, err)
+			assert.Equal(t, "Timeout", err.Limit)
+		}
+	})
+	t.Run("should limit embedded documents with the document", func(t *testing.T) {
+		// Each document is within the limits, but not both together
+		body := strings.Repeat(`<w:p><w:r><w:t>Text</w:t></w:r></w:p>`, 200)
+		inner := buildDocx(t, map[string]string{"word/document.xml": wordBody(body)})
+		data := buildDocx(t, map[string]string{
+			"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/package" Target="embeddings/Microsoft_Word_Document.docx"/>
+</Relationships>`,
+			"word/embeddings/Microsoft_Word_Document.docx": string(inner),
+			"word/document.xml": wordBody(body + `<w:p><w:r><w:object xmlns:o="urn:schemas-microsoft-com:office:office" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+				`<o:OLEObject Type="Embed" ProgID="Word.Document.12" r:id="rId1"/></w:object></w:r></w:p>`),
+		})
+
+		for limit, limits := range map[string]word_extractor.Limits{
+			"MaxXMLTokens":   {MaxXMLTokens: 2000},
+			"MaxOutputChars": {MaxOutputChars: 1500},
+			"MaxTotalSize":   {MaxTotalSize: 14000},
+		} {
+			for _, source := range [][]byte{inner, data} {
+				_, limitErr := extractWithLimits(t, source, limits)
+				require.Nil(t, limitErr, limit)
+			}
+
+			extractor := &word_extractor.WordExtractor{Options: word_extractor.ExtractOptions{Limits: limits, EmbeddedDocuments: true}}
+			_, err := extractor.Extract(data)
+			var limitErr *word_extractor.LimitError
+			require.ErrorAs(t, err, &limitErr, limit)
+			assert.Equal(t, limit, limitErr.Limit)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readStreams returns the streams at the root of a compound file
+func readStreams(t *testing.T, file string) map[string][]byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("data", file))
+	require.NoError(t, err)
+	cfb, err := mscfb.New(bytes.NewReader(data))
+	require.NoError(t, err)
+	streams := map[string][]byte{}
+	for entry, err := cfb.Next(); err == nil; entry, err = cfb.Next() {
+		if len(entry.Path) == 0 && !entry.FileInfo().IsDir() {
+			buf := new(bytes.Buffer)
+			_, err := buf.ReadFrom(cfb)
+			require.NoError(t, err)
+			streams[entry.Name] = buf.Bytes()
+		}
+	}
+	return streams
+}
+
+func TestCorruptDocuments(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+
+	// corrupt extracts test01.doc after changing its WordDocument stream
+	corrupt := func(t *testing.T, change func(doc []byte) []byte) error {
+		streams := readStreams(t, "test01.doc")
+		streams["WordDocument"] = change(append([]byte(nil), streams["WordDocument"]...))
+		var err error
+		assert.NotPanics(t, func() {
+			_, err = extractor.Extract(buildCompoundFile(t, streams))
+		})
+		return err
+	}
+
+	t.Run("should report tables of the FIB out of range", func(t *testing.T) {
+		for offset, name := range map[int]string{0x01A2: "Clx", 0x00FA: "PlcBteChpx", 0x0102: "PlcBtePapx", 0x0142: "SttbfBkmk"} {
+			err := corrupt(t, func(doc []byte) []byte {
+				binary.LittleEndian.PutUint32(doc[offset:], 0x7FFFFFF0)
+				binary.LittleEndian.PutUint32(doc[offset+4:], 0x100)
+				return doc
+			})
+			assert.ErrorIs(t, err, word_extractor.ErrCorrupt, name)
+			assert.ErrorContains(t, err, "invalid "+name)
+			var extractErr *word_extractor.ExtractError
+			require.ErrorAs(t, err, &extractErr)
+			assert.Equal(t, "1Table", extractErr.Part)
+		}
+	})
+
+	t.Run("should report FKP pages out of range", func(t *testing.T) {
+		for _, offset := range []int{0x00FA, 0x0102} {
+			streams := readStreams(t, "test01.doc")
+			doc := streams["WordDocument"]
+			fc := binary.LittleEndian.Uint32(doc[offset:])
+			lcb := binary.LittleEndian.Uint32(doc[offset+4:])
+			plcBte := streams["1Table"][fc : fc+lcb]
+			binary.LittleEndian.PutUint32(plcBte[len(plcBte)-4:], 0xFFFF)
+
+			_, err := extractor.Extract(buildCompoundFile(t, streams))
+			assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+			assert.ErrorContains(t, err, "FKP")
+			var extractErr *word_extractor.ExtractError
+			require.ErrorAs(t, err, &extractErr)
+			assert.Equal(t, "WordDocument", extractErr.Part)
+			assert.Equal(t, int64(0xFFFF*512), extractErr.Offset)
+		}
+	})
+
+	t.Run("should report truncated FIBs", func(t *testing.T) {
+		for _, size := range []int{1, 0x20, 0x60, 0x100, 0x1A4} {
+			err := corrupt(t, func(doc []byte) []byte {
+				return doc[:size]
+			})
+			assert.ErrorIs(t, err, word_extractor.ErrCorrupt, size)
+		}
+	})
+
+	t.Run("should report pieces out of range", func(t *testing.T) {
+		streams := readStreams(t, "test01.doc")
+		doc := streams["WordDocument"]
+		fcClx := binary.LittleEndian.Uint32(doc[0x01A2:])
+		lcbClx := binary.LittleEndian.Uint32(doc[0x01A6:])
+		table := streams["1Table"]
+		clx := table[fcClx : fcClx+lcbClx]
+		require.Equal(t, byte(2), clx[0])
+		count := (int(binary.LittleEndian.Uint32(clx[1:])) - 4) / 12
+		binary.LittleEndian.PutUint32(clx[5+(count+1)*4+2:], 0x3FFFFFF0)
+
+		_, err := extractor.Extract(buildCompoundFile(t, streams))
+		assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+		assert.ErrorContains(t, err, "invalid piece text")
+	})
+
+	t.Run("should report pieces longer than the text", func(t *testing.T) {
+		err := corrupt(t, func(doc []byte) []byte {
+			binary.LittleEndian.PutUint32(doc[0x004C:], 1)
+			return doc
+		})
+		assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+		assert.ErrorContains(t, err, "invalid piece table")
+	})
+
+	t.Run("should report overlapping pieces", func(t *testing.T) {
+		streams := readStreams(t, "test04.doc")
+		doc := streams["WordDocument"]
+		fcClx := binary.LittleEndian.Uint32(doc[0x01A2:])
+		lcbClx := binary.LittleEndian.Uint32(doc[0x01A6:])
+		clx := streams["1Table"][fcClx : fcClx+lcbClx]
+		count := (int(binary.LittleEndian.Uint32(clx[1:])) - 4) / 12
+		require.Greater(t, count, 1)
+		pcd := clx[5+(count+1)*4:]
+		copy(pcd[8+2:8+6], pcd[2:6])
+
+		_, err := extractor.Extract(buildCompoundFile(t, streams))
+		assert.ErrorIs(t, err, word_extractor.ErrCorrupt)
+		assert.ErrorContains(t, err, "overlapping pieces")
+		var extractErr *word_extractor.ExtractError
+		require.ErrorAs(t, err, &extractErr)
+		assert.Equal(t, "WordDocument", extractErr.Part)
+	})
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// onlyReader hides the other methods of a reader, as an HTTP body would
+type onlyReader struct {
+	io.Reader
+}
+
+func TestReaders(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+	files := []string{"test01.doc", "test01.docx", "test04-strict.docx"}
+
+	for _, file := range files {
+		path := filepath.Join("data", file)
+		expected, err := extractor.Extract(path)
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		t.Run("should extract from an open file "+file, func(t *testing.T) {
+			f, err := os.Open(path)
+			require.NoError(t, err)
+			defer f.Close()
+
+			// The file is read from its start, wherever it was left
+			_, err = f.Seek(100, io.SeekStart)
+			require.NoError(t, err)
+			doc, err := extractor.ExtractFile(f)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Body, doc.Body)
+		})
+
+		t.Run("should extract from a reader "+file, func(t *testing.T) {
+			doc, err := extractor.ExtractReader(onlyReader{bytes.NewReader(data)})
+			require.NoError(t, err)
+			assert.Equal(t, expected.Body, doc.Body)
+
+			doc, err = extractor.ExtractReader(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, expected.Body, doc.Body)
+		})
+
+		t.Run("should extract from a reader at offsets "+file, func(t *testing.T) {
+			// The document is followed by other data, which is not read
+			padded := append(append([]byte(nil), data...), make([]byte, 1000)...)
+			doc, err := extractor.ExtractReaderAt(bytes.NewReader(padded), int64(len(data)))
+			require.NoError(t, err)
+			assert.Equal(t, expected.Body, doc.Body)
+		})
+	}
+
+	t.Run("should extract from a pipe", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("data", "test01.docx"))
+		require.NoError(t, err)
+		expected, err := extractor.Extract(data)
+		require.NoError(t, err)
+
+		// A pipe is an io.ReadSeeker that cannot seek
+		for name, extract := range map[string]func(r *os.File) (*word_extractor.Document, error){
+			"ExtractFile":   extractor.ExtractFile,
+			"ExtractReader": func(r *os.File) (*word_extractor.Document, error) { return extractor.ExtractReader(r) },
+		} {
+			r, w, err := os.Pipe()
+			require.NoError(t, err)
+			go func() {
+				w.Write(data)
+				w.Close()
+			}()
+			doc, err := extract(r)
+			r.Close()
+			require.NoError(t, err, name)
+			assert.Equal(t, expected.Body, doc.Body, name)
+		}
+	})
+
+	t.Run("should limit the size of readers that are copied", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("data", "test01.docx"))
+		require.NoError(t, err)
+		extractor := &word_extractor.WordExtractor{Options: word_extractor.ExtractOptions{Limits: word_extractor.Limits{MaxInputSize: 1000}}}
+		_, err = extractor.ExtractReader(onlyReader{bytes.NewReader(data)})
+		assert.ErrorIs(t, err, word_extractor.ErrTooLarge)
+		var limitErr *word_extractor.LimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, "MaxInputSize", limitErr.Limit)
+	})
+
+	t.Run("should report readers that fail", func(t *testing.T) {
+		_, err := extractor.ExtractReader(io.MultiReader(bytes.NewReader([]byte("PK\x03\x04")), iotest.ErrReader(io.ErrClosedPipe)))
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+}
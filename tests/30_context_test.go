@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	word_extractor "word-extractor/pkg/word-extractor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countdownContext is done once its error has been checked a number of
+// times, so that an extraction is stopped part way through
+type countdownContext struct {
+	context.Context
+	done   chan struct{}
+	checks int
+}
+
+func newCountdownContext(checks int) *countdownContext {
+	return &countdownContext{Context: context.Background(), done: make(chan struct{}), checks: checks}
+}
+
+func (c *countdownContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *countdownContext) Err() error {
+	if c.checks--; c.checks < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestContext(t *testing.T) {
+	extractor := word_extractor.NewWordExtractor()
+	files := []string{"bigfile-01.doc", "bigfile-01.docx", "test01.doc", "test01.docx"}
+
+	t.Run("should extract with a context that is not done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for _, file := range files {
+			path := filepath.Join("data", file)
+			expected, err := extractor.Extract(path)
+			require.NoError(t, err)
+			doc, err := extractor.ExtractContext(ctx, path)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Body, doc.Body)
+		}
+	})
+
+	t.Run("should stop when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for _, file := range files {
+			_, err := extractor.ExtractContext(ctx, filepath.Join("data", file))
+			assert.ErrorIs(t, err, context.Canceled, file)
+			assert.NotErrorIs(t, err, word_extractor.ErrCorrupt, file)
+		}
+
+		_, err := extractor.ExtractContext(ctx, []byte(`{\rtf1\ansi Text\par}`))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should stop part way through when the context is done", func(t *testing.T) {
+		for _, file := range []string{"bigfile-01.doc", "bigfile-01.docx"} {
+			_, err := extractor.ExtractContext(newCountdownContext(3), filepath.Join("data", file))
+			assert.ErrorIs(t, err, context.Canceled, file)
+			assert.NotErrorIs(t, err, word_extractor.ErrCorrupt, file)
+		}
+	})
+
+	t.Run("should stop when the deadline of the context passes", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		_, err := extractor.ExtractContext(ctx, filepath.Join("data", "bigfile-01.doc"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("should stop the typed extractions when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		data, err := os.ReadFile(filepath.Join("data", "test01.docx"))
+		require.NoError(t, err)
+
+		f, err := os.Open(filepath.Join("data", "test01.docx"))
+		require.NoError(t, err)
+		defer f.Close()
+		_, err = extractor.ExtractFileContext(ctx, f)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = extractor.ExtractReaderContext(ctx, bytes.NewReader(data))
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = extractor.ExtractReaderContext(ctx, onlyReader{bytes.NewReader(data)})
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = extractor.ExtractReaderAtContext(ctx, bytes.NewReader(data), int64(len(data)))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should stop at every check of the context", func(t *testing.T) {
+		// The context is done at each check in turn, until there are enough
+		// checks for the extraction to finish
+		doc, err := os.ReadFile(filepath.Join("data", "test01.doc"))
+		require.NoError(t, err)
+		docx, err := os.ReadFile(filepath.Join("data", "test01.docx"))
+		require.NoError(t, err)
+		extractor := word_extractor.NewWordExtractor()
+		extractor.Options.Password = "secret"
+		for name, data := range map[string][]byte{
+			"test01.doc":      doc,
+			"test01.docx":     docx,
+			"agile encrypted": encryptPackage(t, "test01.docx", "secret", true),
+			"RC4 encrypted":   encryptDoc(t, "test01.doc", "secret", true),
+		} {
+			checks := 0
+			for ; ; checks++ {
+				_, err := extractor.ExtractContext(newCountdownContext(checks), data)
+				if err == nil {
+					break
+				}
+				require.ErrorIs(t, err, context.Canceled, name)
+				require.NotErrorIs(t, err, word_extractor.ErrCorrupt, name)
+			}
+			assert.Positive(t, checks, name)
+		}
+	})
+}